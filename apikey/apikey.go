@@ -0,0 +1,85 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"time"
+)
+
+const (
+	ScopeWalletsRead  = "wallets:read"
+	ScopeWalletsWrite = "wallets:write"
+	ScopeAdmin        = "admin"
+
+	keyPrefix = "wk_"
+)
+
+var ErrNotFound = errors.New("api key not found")
+
+type APIKey struct {
+	ID         int        `json:"id" example:"1"`
+	Name       string     `json:"name" example:"nightly-batch"`
+	Prefix     string     `json:"prefix" example:"wk_3f9a1c2e"`
+	Scopes     []string   `json:"scopes" example:"wallets:read"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" example:"2025-03-25T14:19:00Z"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" example:"2024-03-25T14:19:00Z"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" example:"2024-03-25T14:19:00Z"`
+	CreatedAt  time.Time  `json:"created_at" example:"2024-03-25T14:19:00.729237Z"`
+}
+
+// IssuedAPIKey is returned only when a key is created or rotated; the
+// plaintext key is never stored and cannot be retrieved again.
+type IssuedAPIKey struct {
+	APIKey
+	Key string `json:"key" example:"wk_3f9a1c2e..."`
+}
+
+type CreateAPIKey struct {
+	Name      string     `json:"name" example:"nightly-batch"`
+	Scopes    []string   `json:"scopes" example:"wallets:read"`
+	ExpiresAt *time.Time `json:"expires_at" example:"2025-03-25T14:19:00Z"`
+}
+
+// Secret is the stored form of a key: a short display prefix and the
+// SHA-256 hash of the full key.
+type Secret struct {
+	Prefix string
+	Hash   string
+}
+
+func (k APIKey) HasScope(scope string) bool {
+	return slices.Contains(k.Scopes, ScopeAdmin) || slices.Contains(k.Scopes, scope)
+}
+
+func (k APIKey) Active(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Generate returns a new random plaintext key and its stored form.
+func Generate() (string, Secret, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", Secret{}, err
+	}
+	key := keyPrefix + hex.EncodeToString(buf)
+	return key, Secret{Prefix: key[:len(keyPrefix)+8], Hash: Hash(key)}, nil
+}
+
+func validScope(scope string) bool {
+	switch scope {
+	case ScopeWalletsRead, ScopeWalletsWrite, ScopeAdmin:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,203 @@
+package apikey
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+type StubStorer struct {
+	keys    map[string]APIKey
+	touched []int
+	err     error
+}
+
+func (s *StubStorer) APIKeys() ([]APIKey, error) {
+	var result []APIKey
+	for _, k := range s.keys {
+		result = append(result, k)
+	}
+	return result, s.err
+}
+
+func (s *StubStorer) APIKeyByHash(hash string) (APIKey, error) {
+	if s.err != nil {
+		return APIKey{}, s.err
+	}
+	k, ok := s.keys[hash]
+	if !ok {
+		return APIKey{}, ErrNotFound
+	}
+	return k, nil
+}
+
+func (s *StubStorer) CreateAPIKey(createAPIKey CreateAPIKey, secret Secret) (APIKey, error) {
+	k := APIKey{
+		ID:        len(s.keys) + 1,
+		Name:      createAPIKey.Name,
+		Prefix:    secret.Prefix,
+		Scopes:    createAPIKey.Scopes,
+		ExpiresAt: createAPIKey.ExpiresAt,
+		CreatedAt: time.Date(2024, 04, 12, 10, 45, 16, 0, time.UTC),
+	}
+	s.keys[secret.Hash] = k
+	return k, s.err
+}
+
+func (s *StubStorer) RotateAPIKey(id int, secret Secret) (APIKey, error) {
+	for hash, k := range s.keys {
+		if k.ID == id {
+			delete(s.keys, hash)
+			k.Prefix = secret.Prefix
+			s.keys[secret.Hash] = k
+			return k, nil
+		}
+	}
+	return APIKey{}, ErrNotFound
+}
+
+func (s *StubStorer) RevokeAPIKey(id int) error {
+	for hash, k := range s.keys {
+		if k.ID == id {
+			now := time.Now()
+			k.RevokedAt = &now
+			s.keys[hash] = k
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *StubStorer) TouchAPIKey(id int, usedAt time.Time) error {
+	s.touched = append(s.touched, id)
+	for hash, k := range s.keys {
+		if k.ID == id {
+			k.LastUsedAt = &usedAt
+			s.keys[hash] = k
+		}
+	}
+	return nil
+}
+
+func serve(store Storer, scope string, key string) *httptest.ResponseRecorder {
+	e := echo.New()
+	e.GET("/", func(c echo.Context) error {
+		return c.String(http.StatusOK, "ok")
+	}, Middleware(store, "bootstrap-secret"), RequireScope(scope))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if key != "" {
+		req.Header.Set(HeaderAPIKey, key)
+	}
+	res := httptest.NewRecorder()
+	e.ServeHTTP(res, req)
+	return res
+}
+
+func TestMiddleware(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	store := &StubStorer{keys: map[string]APIKey{
+		Hash("reader"):  {ID: 1, Name: "reader", Scopes: []string{ScopeWalletsRead}},
+		Hash("revoked"): {ID: 2, Name: "revoked", Scopes: []string{ScopeWalletsRead}, RevokedAt: &past},
+		Hash("expired"): {ID: 3, Name: "expired", Scopes: []string{ScopeWalletsRead}, ExpiresAt: &past},
+	}}
+
+	tests := []struct {
+		name  string
+		key   string
+		scope string
+		want  int
+	}{
+		{"given no key should return 401", "", ScopeWalletsRead, http.StatusUnauthorized},
+		{"given unknown key should return 401", "nope", ScopeWalletsRead, http.StatusUnauthorized},
+		{"given revoked key should return 401", "revoked", ScopeWalletsRead, http.StatusUnauthorized},
+		{"given expired key should return 401", "expired", ScopeWalletsRead, http.StatusUnauthorized},
+		{"given key with scope should return 200", "reader", ScopeWalletsRead, http.StatusOK},
+		{"given key without scope should return 403", "reader", ScopeWalletsWrite, http.StatusForbidden},
+		{"given bootstrap key should be admin", "bootstrap-secret", ScopeWalletsWrite, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := serve(store, tt.scope, tt.key)
+			if res.Code != tt.want {
+				t.Errorf("expected status code %d but got %d", tt.want, res.Code)
+			}
+		})
+	}
+
+	if len(store.touched) != 1 || store.touched[0] != 1 {
+		t.Errorf("expected last_used_at to be touched for key 1 only, got %v", store.touched)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	t.Run("given valid request should issue a key that authenticates", func(t *testing.T) {
+		store := &StubStorer{keys: map[string]APIKey{}}
+		body, _ := json.Marshal(CreateAPIKey{Name: "batch", Scopes: []string{ScopeWalletsRead}})
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		res := httptest.NewRecorder()
+		c := echo.New().NewContext(req, res)
+
+		New(store).CreateAPIKey(c)
+
+		if res.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d but got %d", http.StatusCreated, res.Code)
+		}
+		var got IssuedAPIKey
+		if err := json.Unmarshal(res.Body.Bytes(), &got); err != nil {
+			t.Fatalf("Unable to unmarshal json: %v", err)
+		}
+		if _, ok := store.keys[Hash(got.Key)]; !ok {
+			t.Errorf("expected hash of issued key to be stored")
+		}
+		if got.Prefix == "" || got.Key[:len(got.Prefix)] != got.Prefix {
+			t.Errorf("expected prefix %q to be a prefix of the key", got.Prefix)
+		}
+		if res := serve(store, ScopeWalletsRead, got.Key); res.Code != http.StatusOK {
+			t.Errorf("expected issued key to authenticate, got %d", res.Code)
+		}
+	})
+
+	t.Run("given unknown scope should return 400", func(t *testing.T) {
+		body, _ := json.Marshal(CreateAPIKey{Name: "batch", Scopes: []string{"root"}})
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		res := httptest.NewRecorder()
+		c := echo.New().NewContext(req, res)
+
+		New(&StubStorer{keys: map[string]APIKey{}}).CreateAPIKey(c)
+
+		if res.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d but got %d", http.StatusBadRequest, res.Code)
+		}
+	})
+
+	t.Run("given rotated key should reject the old secret", func(t *testing.T) {
+		store := &StubStorer{keys: map[string]APIKey{
+			Hash("old"): {ID: 7, Name: "batch", Scopes: []string{ScopeWalletsRead}},
+		}}
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		res := httptest.NewRecorder()
+		c := echo.New().NewContext(req, res)
+		c.SetParamNames("id")
+		c.SetParamValues("7")
+
+		New(store).RotateAPIKey(c)
+
+		var got IssuedAPIKey
+		if err := json.Unmarshal(res.Body.Bytes(), &got); err != nil {
+			t.Fatalf("Unable to unmarshal json: %v", err)
+		}
+		if res := serve(store, ScopeWalletsRead, "old"); res.Code != http.StatusUnauthorized {
+			t.Errorf("expected old key to be rejected, got %d", res.Code)
+		}
+		if res := serve(store, ScopeWalletsRead, got.Key); res.Code != http.StatusOK {
+			t.Errorf("expected new key to authenticate, got %d", res.Code)
+		}
+	})
+}
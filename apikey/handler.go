@@ -0,0 +1,151 @@
+package apikey
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+type Handler struct {
+	store Storer
+}
+
+type Storer interface {
+	APIKeys() ([]APIKey, error)
+	APIKeyByHash(hash string) (APIKey, error)
+	CreateAPIKey(createAPIKey CreateAPIKey, secret Secret) (APIKey, error)
+	RotateAPIKey(id int, secret Secret) (APIKey, error)
+	RevokeAPIKey(id int) error
+	TouchAPIKey(id int, usedAt time.Time) error
+}
+
+func New(db Storer) *Handler {
+	return &Handler{store: db}
+}
+
+type Err struct {
+	Message string `json:"message"`
+}
+
+// APIKeysHandler
+//
+//	@Summary		List API keys
+//	@Description	List API keys, including revoked and expired ones. Hashes are never returned.
+//	@Tags			api-key
+//	@Produce		json
+//	@Success		200	{array}		APIKey
+//	@Router			/api/v1/admin/api-keys [get]
+//	@Failure		500	{object}	Err
+//	@Security		ApiKeyAuth
+func (h *Handler) APIKeysHandler(c echo.Context) error {
+	keys, err := h.store.APIKeys()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, Err{Message: err.Error()})
+	}
+	return c.JSON(http.StatusOK, keys)
+}
+
+// CreateAPIKey
+//
+//	@Summary		Create API key
+//	@Description	Create API key. The plaintext key is only returned in this response.
+//	@Tags			api-key
+//	@Accept			json
+//	@Produce		json
+//	@Success		201	{object}	IssuedAPIKey
+//	@Router			/api/v1/admin/api-keys [post]
+//	@Failure		400	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Param			CreateAPIKey body CreateAPIKey true "Body for create API key"
+//	@Security		ApiKeyAuth
+func (h *Handler) CreateAPIKey(c echo.Context) error {
+	var createAPIKey CreateAPIKey
+	if err := c.Bind(&createAPIKey); err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+	if createAPIKey.Name == "" {
+		return c.JSON(http.StatusBadRequest, Err{Message: "name is required"})
+	}
+	if len(createAPIKey.Scopes) == 0 {
+		return c.JSON(http.StatusBadRequest, Err{Message: "at least one scope is required"})
+	}
+	for _, scope := range createAPIKey.Scopes {
+		if !validScope(scope) {
+			return c.JSON(http.StatusBadRequest, Err{Message: "unknown scope " + strconv.Quote(scope)})
+		}
+	}
+	if createAPIKey.ExpiresAt != nil && !createAPIKey.ExpiresAt.After(time.Now()) {
+		return c.JSON(http.StatusBadRequest, Err{Message: "expires_at must be in the future"})
+	}
+
+	key, secret, err := Generate()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, Err{Message: err.Error()})
+	}
+	result, err := h.store.CreateAPIKey(createAPIKey, secret)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, Err{Message: err.Error()})
+	}
+	return c.JSON(http.StatusCreated, IssuedAPIKey{APIKey: result, Key: key})
+}
+
+// RotateAPIKey
+//
+//	@Summary		Rotate API key
+//	@Description	Replace the secret of an API key, keeping its name, scopes and expiry. The old key stops working immediately.
+//	@Tags			api-key
+//	@Produce		json
+//	@Success		200	{object}	IssuedAPIKey
+//	@Router			/api/v1/admin/api-keys/{id}/rotate [post]
+//	@Failure		404	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Param			id path int true "API key ID"
+//	@Security		ApiKeyAuth
+func (h *Handler) RotateAPIKey(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: "invalid api key id"})
+	}
+	key, secret, err := Generate()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, Err{Message: err.Error()})
+	}
+	result, err := h.store.RotateAPIKey(id, secret)
+	if errors.Is(err, ErrNotFound) {
+		return c.JSON(http.StatusNotFound, Err{Message: err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, Err{Message: err.Error()})
+	}
+	return c.JSON(http.StatusOK, IssuedAPIKey{APIKey: result, Key: key})
+}
+
+// RevokeAPIKey
+//
+//	@Summary		Revoke API key
+//	@Description	Revoke API key. Revoked keys are kept for auditing but can no longer authenticate.
+//	@Tags			api-key
+//	@Produce		plain
+//	@Success		200	{string}	string
+//	@Router			/api/v1/admin/api-keys/{id} [delete]
+//	@Failure		404	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Param			id path int true "API key ID"
+//	@Security		ApiKeyAuth
+func (h *Handler) RevokeAPIKey(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: "invalid api key id"})
+	}
+	err = h.store.RevokeAPIKey(id)
+	if errors.Is(err, ErrNotFound) {
+		return c.JSON(http.StatusNotFound, Err{Message: err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, Err{Message: err.Error()})
+	}
+	return c.String(http.StatusOK, "Revoke Success")
+}
@@ -0,0 +1,102 @@
+package apikey
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	HeaderAPIKey = "X-API-Key"
+
+	contextKey = "api_key"
+
+	// touchInterval limits how often last_used_at is written for a busy key.
+	touchInterval = time.Minute
+)
+
+var (
+	ErrMissingKey  = errors.New("missing API key")
+	ErrInvalidKey  = errors.New("invalid API key")
+	ErrInactiveKey = errors.New("API key is revoked or expired")
+)
+
+// Authenticate resolves a raw API key. The bootstrap key, when
+// non-empty, is accepted with admin scope so the first real keys can be
+// created; it is never stored. Failing to record that a key was used
+// does not fail authentication and is reported to warnf instead.
+func Authenticate(store Storer, bootstrapKey, raw string, warnf func(format string, args ...any)) (APIKey, error) {
+	if raw == "" {
+		return APIKey{}, ErrMissingKey
+	}
+	if bootstrapKey != "" && subtle.ConstantTimeCompare([]byte(raw), []byte(bootstrapKey)) == 1 {
+		return APIKey{Name: "bootstrap", Scopes: []string{ScopeAdmin}}, nil
+	}
+
+	key, err := store.APIKeyByHash(Hash(raw))
+	if errors.Is(err, ErrNotFound) {
+		return APIKey{}, ErrInvalidKey
+	}
+	if err != nil {
+		return APIKey{}, err
+	}
+	now := time.Now()
+	if !key.Active(now) {
+		return APIKey{}, ErrInactiveKey
+	}
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > touchInterval {
+		if err := store.TouchAPIKey(key.ID, now); err != nil {
+			warnf("unable to update last_used_at for api key %d: %v", key.ID, err)
+		}
+	}
+	return key, nil
+}
+
+// Middleware authenticates requests by the X-API-Key header; see
+// Authenticate.
+func Middleware(store Storer, bootstrapKey string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key, err := Authenticate(store, bootstrapKey, c.Request().Header.Get(HeaderAPIKey), c.Logger().Warnf)
+			switch {
+			case errors.Is(err, ErrMissingKey), errors.Is(err, ErrInvalidKey), errors.Is(err, ErrInactiveKey):
+				return c.JSON(http.StatusUnauthorized, Err{Message: err.Error()})
+			case err != nil:
+				return c.JSON(http.StatusInternalServerError, Err{Message: err.Error()})
+			}
+			SetKey(c, key)
+			return next(c)
+		}
+	}
+}
+
+// RequireScope rejects requests whose API key lacks the given scope.
+// It must be mounted after Middleware.
+func RequireScope(scope string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key, ok := FromContext(c)
+			if !ok {
+				return c.JSON(http.StatusUnauthorized, Err{Message: "missing API key"})
+			}
+			if !key.HasScope(scope) {
+				return c.JSON(http.StatusForbidden, Err{Message: "API key lacks scope " + scope})
+			}
+			return next(c)
+		}
+	}
+}
+
+// SetKey records the API key that authenticated the request.
+func SetKey(c echo.Context, key APIKey) {
+	c.Set(contextKey, key)
+}
+
+// FromContext returns the API key that authenticated the request.
+func FromContext(c echo.Context) (APIKey, bool) {
+	key, ok := c.Get(contextKey).(APIKey)
+	return key, ok
+}
@@ -0,0 +1,171 @@
+package asset
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet"
+)
+
+var (
+	ErrNotFound       = errors.New("holding not found")
+	ErrUnknownAsset   = errors.New("unknown asset")
+	ErrInvalidAddress = errors.New("invalid deposit address")
+	ErrInvalidAmount  = errors.New("invalid amount")
+	ErrNotCrypto      = errors.New("assets can only be held by Crypto Wallets")
+	ErrDuplicate      = errors.New("wallet already holds asset")
+)
+
+// Asset is a currency a Crypto Wallet can hold. Decimals is the number of
+// decimal places of its smallest unit, e.g. 8 for BTC (satoshi).
+type Asset struct {
+	Code     string `json:"code" example:"BTC"`
+	Name     string `json:"name" example:"Bitcoin"`
+	Decimals int    `json:"decimals" example:"8"`
+
+	address *regexp.Regexp
+}
+
+var assets = map[string]Asset{
+	"BTC": {Code: "BTC", Name: "Bitcoin", Decimals: 8,
+		// Legacy base58 (P2PKH, P2SH) and bech32 (SegWit, Taproot) mainnet addresses.
+		address: regexp.MustCompile(`^([13][a-km-zA-HJ-NP-Z1-9]{25,34}|bc1[ac-hj-np-z02-9]{11,71})$`)},
+	"ETH": {Code: "ETH", Name: "Ether", Decimals: 18,
+		address: regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`)},
+	"USDT": {Code: "USDT", Name: "Tether USD (ERC-20)", Decimals: 6,
+		address: regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`)},
+	"SOL": {Code: "SOL", Name: "Solana", Decimals: 9,
+		address: regexp.MustCompile(`^[1-9A-HJ-NP-Za-km-z]{32,44}$`)},
+}
+
+// Assets returns the supported assets ordered by code.
+func Assets() []Asset {
+	result := make([]Asset, 0, len(assets))
+	for _, a := range assets {
+		result = append(result, a)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Code < result[j].Code })
+	return result
+}
+
+// Lookup returns the asset with the given code, case-insensitively.
+func Lookup(code string) (Asset, error) {
+	a, ok := assets[strings.ToUpper(code)]
+	if !ok {
+		return Asset{}, fmt.Errorf("%w %q", ErrUnknownAsset, code)
+	}
+	return a, nil
+}
+
+// ValidateAddress checks the format of a deposit address. It does not
+// verify checksums.
+func (a Asset) ValidateAddress(address string) error {
+	if !a.address.MatchString(address) {
+		return fmt.Errorf("%w for %s", ErrInvalidAddress, a.Code)
+	}
+	return nil
+}
+
+var amountPattern = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+
+// Parse converts a decimal string such as "0.00012345" to an amount in
+// the asset's smallest unit. More decimals than the asset has are
+// rejected rather than rounded.
+func (a Asset) Parse(s string) (*big.Int, error) {
+	if !amountPattern.MatchString(s) {
+		return nil, fmt.Errorf("%w %q", ErrInvalidAmount, s)
+	}
+	whole, fraction, _ := strings.Cut(s, ".")
+	if len(fraction) > a.Decimals {
+		return nil, fmt.Errorf("%w: %s has %d decimals", ErrInvalidAmount, a.Code, a.Decimals)
+	}
+	units, _ := new(big.Int).SetString(whole+fraction+strings.Repeat("0", a.Decimals-len(fraction)), 10)
+	return units, nil
+}
+
+// Format converts an amount in the asset's smallest unit to a decimal
+// string with all of the asset's decimals.
+func (a Asset) Format(units *big.Int) string {
+	digits := new(big.Int).Abs(units).String()
+	if len(digits) <= a.Decimals {
+		digits = strings.Repeat("0", a.Decimals-len(digits)+1) + digits
+	}
+	sign := ""
+	if units.Sign() < 0 {
+		sign = "-"
+	}
+	if a.Decimals == 0 {
+		return sign + digits
+	}
+	return sign + digits[:len(digits)-a.Decimals] + "." + digits[len(digits)-a.Decimals:]
+}
+
+// Holding is the balance of one asset in a Crypto Wallet. Balance is a
+// decimal string so no precision is lost in JSON.
+type Holding struct {
+	WalletID  int       `json:"wallet_id" example:"3"`
+	Asset     string    `json:"asset" example:"BTC"`
+	Decimals  int       `json:"decimals" example:"8"`
+	Address   string    `json:"address" example:"bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq"`
+	Balance   string    `json:"balance" example:"0.00012345"`
+	Units     *big.Int  `json:"-"`
+	CreatedAt time.Time `json:"created_at" example:"2024-03-25T14:19:00.729237Z"`
+}
+
+type CreateHolding struct {
+	Asset   string `json:"asset" example:"BTC"`
+	Address string `json:"address" example:"bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq"`
+}
+
+// Validate checks the asset and the address, and normalizes the asset code.
+func (c *CreateHolding) Validate() (Asset, error) {
+	a, err := Lookup(c.Asset)
+	if err != nil {
+		return a, err
+	}
+	c.Asset = a.Code
+	return a, a.ValidateAddress(c.Address)
+}
+
+// Adjustment credits (positive) or debits (negative) a holding.
+type Adjustment struct {
+	Amount      string `json:"amount" example:"-0.0001"`
+	Description string `json:"description" example:"Withdrawal to cold storage"`
+}
+
+// Apply returns the balance of h after adding delta to it, checking that
+// the wallet's status allows the change and that the balance does not go
+// below zero. The wallet row must be locked.
+func Apply(w wallet.Wallet, h Holding, delta *big.Int) (*big.Int, error) {
+	switch {
+	case delta.Sign() == 0:
+		return h.Units, nil
+	case w.Status == wallet.StatusClosed:
+		return nil, wallet.ErrWalletClosed
+	case w.Status == wallet.StatusFrozen && delta.Sign() < 0:
+		return nil, wallet.ErrWalletFrozen
+	}
+	balance := new(big.Int).Add(h.Units, delta)
+	if balance.Sign() < 0 {
+		return nil, wallet.ErrInsufficientFunds
+	}
+	return balance, nil
+}
+
+// SetUnits sets the balance of h from an amount in the smallest unit of
+// its asset.
+func (h *Holding) SetUnits(units *big.Int) error {
+	a, err := Lookup(h.Asset)
+	if err != nil {
+		return err
+	}
+	h.Decimals = a.Decimals
+	h.Units = units
+	h.Balance = a.Format(units)
+	return nil
+}
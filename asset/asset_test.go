@@ -0,0 +1,166 @@
+package asset
+
+import (
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet"
+	"github.com/labstack/echo/v4"
+)
+
+type StubStorer struct {
+	holdings []Holding
+}
+
+func (s *StubStorer) Holdings(walletID int) ([]Holding, error) {
+	return s.holdings, nil
+}
+
+func (s *StubStorer) Holding(walletID int, code string) (Holding, error) {
+	for _, h := range s.holdings {
+		if h.WalletID == walletID && h.Asset == code {
+			return h, nil
+		}
+	}
+	return Holding{}, ErrNotFound
+}
+
+func (s *StubStorer) CreateHolding(walletID int, createHolding CreateHolding, actor wallet.Actor) (Holding, error) {
+	h := Holding{WalletID: walletID, Asset: createHolding.Asset, Address: createHolding.Address}
+	err := h.SetUnits(new(big.Int))
+	s.holdings = append(s.holdings, h)
+	return h, err
+}
+
+func (s *StubStorer) AdjustHolding(walletID int, code string, delta *big.Int, description string, actor wallet.Actor) (Holding, error) {
+	for i, h := range s.holdings {
+		if h.WalletID == walletID && h.Asset == code {
+			balance, err := Apply(wallet.Wallet{Status: wallet.StatusActive}, h, delta)
+			if err != nil {
+				return h, err
+			}
+			return s.holdings[i], s.holdings[i].SetUnits(balance)
+		}
+	}
+	return Holding{}, ErrNotFound
+}
+
+func TestParseAndFormat(t *testing.T) {
+	btc, _ := Lookup("btc")
+	eth, _ := Lookup("ETH")
+	tests := []struct {
+		asset Asset
+		in    string
+		units string
+		out   string
+	}{
+		{btc, "0.00012345", "12345", "0.00012345"},
+		{btc, "21000000", "2100000000000000", "21000000.00000000"},
+		{btc, "-1.5", "-150000000", "-1.50000000"},
+		{eth, "1.000000000000000001", "1000000000000000001", "1.000000000000000001"},
+	}
+	for _, tt := range tests {
+		units, err := tt.asset.Parse(tt.in)
+		if err != nil {
+			t.Errorf("%s %s: unexpected error: %v", tt.asset.Code, tt.in, err)
+			continue
+		}
+		if units.String() != tt.units {
+			t.Errorf("%s %s: expected %s units but got %s", tt.asset.Code, tt.in, tt.units, units)
+		}
+		if got := tt.asset.Format(units); got != tt.out {
+			t.Errorf("%s %s: expected %s but got %s", tt.asset.Code, tt.in, tt.out, got)
+		}
+	}
+
+	for _, invalid := range []string{"0.000000001", "1e-8", "", ".5", "1,000"} {
+		if _, err := btc.Parse(invalid); !errors.Is(err, ErrInvalidAmount) {
+			t.Errorf("%q: expected ErrInvalidAmount but got %v", invalid, err)
+		}
+	}
+}
+
+func TestValidateAddress(t *testing.T) {
+	tests := []struct {
+		asset   string
+		address string
+		valid   bool
+	}{
+		{"BTC", "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa", true},
+		{"BTC", "bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq", true},
+		{"BTC", "0x71C7656EC7ab88b098defB751B7401B5f6d8976F", false},
+		{"ETH", "0x71C7656EC7ab88b098defB751B7401B5f6d8976F", true},
+		{"ETH", "71C7656EC7ab88b098defB751B7401B5f6d8976F", false},
+		{"SOL", "7EcDhSYGxXyscszYEp35KHN8vvw3svAuLKTzXwCFLtV", true},
+		{"SOL", "0OIl0OIl0OIl0OIl0OIl0OIl0OIl0OIl", false},
+	}
+	for _, tt := range tests {
+		a, _ := Lookup(tt.asset)
+		if err := a.ValidateAddress(tt.address); (err == nil) != tt.valid {
+			t.Errorf("%s %s: expected valid=%v but got %v", tt.asset, tt.address, tt.valid, err)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	h := Holding{Units: big.NewInt(100)}
+	tests := []struct {
+		status wallet.Status
+		delta  int64
+		want   error
+	}{
+		{wallet.StatusActive, -100, nil},
+		{wallet.StatusActive, -101, wallet.ErrInsufficientFunds},
+		{wallet.StatusFrozen, 5, nil},
+		{wallet.StatusFrozen, -5, wallet.ErrWalletFrozen},
+		{wallet.StatusClosed, 5, wallet.ErrWalletClosed},
+	}
+	for _, tt := range tests {
+		_, err := Apply(wallet.Wallet{Status: tt.status}, h, big.NewInt(tt.delta))
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s %+d: expected %v but got %v", tt.status, tt.delta, tt.want, err)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	store := &StubStorer{}
+	h := New(store)
+	do := func(handler echo.HandlerFunc, body string, names ...string) *httptest.ResponseRecorder {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id", "asset")
+		c.SetParamValues(names...)
+		_ = handler(c)
+		return rec
+	}
+
+	rec := do(h.CreateHolding, `{"asset":"btc","address":"bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq"}`, "3", "")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201 but got %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(h.CreateHolding, `{"asset":"ETH","address":"bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq"}`, "3", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for an invalid address but got %d", rec.Code)
+	}
+
+	rec = do(h.AdjustHolding, `{"amount":"0.00012345"}`, "3", "BTC")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"balance":"0.00012345"`) {
+		t.Errorf("expected balance 0.00012345 but got %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(h.AdjustHolding, `{"amount":"0.000000001"}`, "3", "BTC"); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for too many decimals but got %d", rec.Code)
+	}
+	if rec := do(h.AdjustHolding, `{"amount":"-1"}`, "3", "BTC"); rec.Code != http.StatusConflict {
+		t.Errorf("expected status 409 for insufficient funds but got %d", rec.Code)
+	}
+	if rec := do(h.HoldingHandler, ``, "3", "ETH"); rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 but got %d", rec.Code)
+	}
+}
@@ -0,0 +1,189 @@
+package asset
+
+import (
+	"errors"
+	"math/big"
+	"net/http"
+	"strconv"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet"
+	"github.com/labstack/echo/v4"
+)
+
+type Handler struct {
+	store Storer
+}
+
+type Storer interface {
+	Holdings(walletID int) ([]Holding, error)
+	Holding(walletID int, asset string) (Holding, error)
+	CreateHolding(walletID int, createHolding CreateHolding, actor wallet.Actor) (Holding, error)
+	AdjustHolding(walletID int, asset string, delta *big.Int, description string, actor wallet.Actor) (Holding, error)
+}
+
+func New(db Storer) *Handler {
+	return &Handler{store: db}
+}
+
+type Err struct {
+	Message string `json:"message"`
+}
+
+func errStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrNotFound), errors.Is(err, wallet.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrUnknownAsset), errors.Is(err, ErrInvalidAddress), errors.Is(err, ErrInvalidAmount):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrNotCrypto),
+		errors.Is(err, ErrDuplicate),
+		errors.Is(err, wallet.ErrWalletFrozen),
+		errors.Is(err, wallet.ErrWalletClosed),
+		errors.Is(err, wallet.ErrInsufficientFunds):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// AssetsHandler
+//
+//	@Summary		List assets
+//	@Description	List the assets Crypto Wallets can hold, with their precision
+//	@Tags			asset
+//	@Produce		json
+//	@Success		200	{array}	Asset
+//	@Router			/api/v1/assets [get]
+//	@Security		ApiKeyAuth
+func (h *Handler) AssetsHandler(c echo.Context) error {
+	return c.JSON(http.StatusOK, Assets())
+}
+
+// HoldingsHandler
+//
+//	@Summary		List wallet holdings
+//	@Description	List the balance of every asset held by a Crypto Wallet
+//	@Tags			asset
+//	@Produce		json
+//	@Success		200	{array}		Holding
+//	@Router			/api/v1/wallets/{id}/holdings [get]
+//	@Failure		400	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Param			id path int true "Wallet ID"
+//	@Security		ApiKeyAuth
+func (h *Handler) HoldingsHandler(c echo.Context) error {
+	walletID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: "invalid wallet id"})
+	}
+	holdings, err := h.store.Holdings(walletID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, Err{Message: err.Error()})
+	}
+	return c.JSON(http.StatusOK, holdings)
+}
+
+// HoldingHandler
+//
+//	@Summary		Get wallet holding
+//	@Description	Get the balance of one asset held by a Crypto Wallet
+//	@Tags			asset
+//	@Produce		json
+//	@Success		200	{object}	Holding
+//	@Router			/api/v1/wallets/{id}/holdings/{asset} [get]
+//	@Failure		400	{object}	Err
+//	@Failure		404	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Param			id path int true "Wallet ID"
+//	@Param			asset path string true "Asset code" example(BTC)
+//	@Security		ApiKeyAuth
+func (h *Handler) HoldingHandler(c echo.Context) error {
+	walletID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: "invalid wallet id"})
+	}
+	a, err := Lookup(c.Param("asset"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+	holding, err := h.store.Holding(walletID, a.Code)
+	if err != nil {
+		return c.JSON(errStatus(err), Err{Message: err.Error()})
+	}
+	return c.JSON(http.StatusOK, holding)
+}
+
+// CreateHolding
+//
+//	@Summary		Add an asset to a wallet
+//	@Description	Start holding an asset in a Crypto Wallet, with a zero balance and a deposit address
+//	@Tags			asset
+//	@Accept			json
+//	@Produce		json
+//	@Param			id path int true "Wallet ID"
+//	@Param			request	body		CreateHolding	true	"Request Body"
+//	@Success		201		{object}	Holding
+//	@Router			/api/v1/wallets/{id}/holdings [post]
+//	@Failure		400		{object}	Err
+//	@Failure		404		{object}	Err
+//	@Failure		409		{object}	Err
+//	@Failure		500		{object}	Err
+//	@Security		ApiKeyAuth
+func (h *Handler) CreateHolding(c echo.Context) error {
+	walletID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: "invalid wallet id"})
+	}
+	var createHolding CreateHolding
+	if err := c.Bind(&createHolding); err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+	if _, err := createHolding.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+	holding, err := h.store.CreateHolding(walletID, createHolding, wallet.ActorFrom(c))
+	if err != nil {
+		return c.JSON(errStatus(err), Err{Message: err.Error()})
+	}
+	return c.JSON(http.StatusCreated, holding)
+}
+
+// AdjustHolding
+//
+//	@Summary		Adjust a holding
+//	@Description	Credit (positive amount) or debit (negative amount) one asset of a Crypto Wallet. The amount may have at most as many decimals as the asset.
+//	@Tags			asset
+//	@Accept			json
+//	@Produce		json
+//	@Param			id path int true "Wallet ID"
+//	@Param			asset path string true "Asset code" example(BTC)
+//	@Param			request	body		Adjustment	true	"Request Body"
+//	@Success		200		{object}	Holding
+//	@Router			/api/v1/wallets/{id}/holdings/{asset}/adjustments [post]
+//	@Failure		400		{object}	Err
+//	@Failure		404		{object}	Err
+//	@Failure		409		{object}	Err
+//	@Failure		500		{object}	Err
+//	@Security		ApiKeyAuth
+func (h *Handler) AdjustHolding(c echo.Context) error {
+	walletID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: "invalid wallet id"})
+	}
+	a, err := Lookup(c.Param("asset"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+	var adjustment Adjustment
+	if err := c.Bind(&adjustment); err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+	delta, err := a.Parse(adjustment.Amount)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+	holding, err := h.store.AdjustHolding(walletID, a.Code, delta, adjustment.Description, wallet.ActorFrom(c))
+	if err != nil {
+		return c.JSON(errStatus(err), Err{Message: err.Error()})
+	}
+	return c.JSON(http.StatusOK, holding)
+}
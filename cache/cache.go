@@ -0,0 +1,289 @@
+// Package cache puts a read-through cache in front of a wallet.Storer.
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/outbox"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet"
+)
+
+// listingsVersion versions every cached listing, which any change to
+// any wallet can affect.
+const listingsVersion = "wallets:version"
+
+// Backend holds cached entries. Rather than deleting entries, the store
+// bumps the version their keys are built from, so that a read racing an
+// invalidation caches its result under the old version, where it is
+// never read again.
+type Backend interface {
+	// Get returns the value of key, if cached and not expired.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Version returns the version of key, 0 until it is bumped.
+	Version(ctx context.Context, key string) (int64, error)
+	Bump(ctx context.Context, key string) error
+}
+
+// Stats counts the reads served by a Store.
+type Stats struct {
+	Hits   int64 `json:"hits" example:"9500"`
+	Misses int64 `json:"misses" example:"500"`
+	// Errors counts the failures of the backend, after which reads go
+	// to the database.
+	Errors int64 `json:"errors" example:"0"`
+}
+
+// Store caches the wallet listings and lookups by user of a
+// wallet.Storer, and invalidates them when wallets change through it.
+// Changes made elsewhere, such as transactions, are only seen once
+// InvalidateEvent is called with their events, or when entries expire.
+type Store struct {
+	wallet.Storer
+	backend Backend
+	ttl     time.Duration
+
+	hits, misses, errors atomic.Int64
+}
+
+func NewStore(store wallet.Storer, backend Backend, ttl time.Duration) *Store {
+	return &Store{Storer: store, backend: backend, ttl: ttl}
+}
+
+func (s *Store) Stats() Stats {
+	return Stats{Hits: s.hits.Load(), Misses: s.misses.Load(), Errors: s.errors.Load()}
+}
+
+func (s *Store) Wallets(filter wallet.Filter) ([]wallet.Wallet, error) {
+	key := fmt.Sprintf("wallets:%q:%t:%d:%d", filter.WalletType, filter.IncludeDeleted, filter.AfterID, filter.Limit)
+	return listing(readThrough(s, listingsVersion, key, func() ([]wallet.Wallet, error) {
+		return s.Storer.Wallets(filter)
+	}))
+}
+
+// listing keeps an empty listing from being listed as null, since gob
+// decodes it as nil.
+func listing(wallets []wallet.Wallet, err error) ([]wallet.Wallet, error) {
+	if wallets == nil && err == nil {
+		wallets = []wallet.Wallet{}
+	}
+	return wallets, err
+}
+
+func (s *Store) WalletByUser(userID int) (wallet.Wallet, error) {
+	key := userKey(userID)
+	return readThrough(s, key+":version", key, func() (wallet.Wallet, error) {
+		return s.Storer.WalletByUser(userID)
+	})
+}
+
+func (s *Store) CreateWallet(createWallet wallet.CreateWallet, actor wallet.Actor) (wallet.Wallet, error) {
+	result, err := s.Storer.CreateWallet(createWallet, actor)
+	s.invalidate(createWallet.UserID)
+	return result, err
+}
+
+func (s *Store) ImportWallets(createWallets []wallet.CreateWallet, actor wallet.Actor) ([]wallet.Wallet, error) {
+	created, err := s.Storer.ImportWallets(createWallets, actor)
+	userIDs := make([]int, len(createWallets))
+	for i, c := range createWallets {
+		userIDs[i] = c.UserID
+	}
+	s.invalidate(userIDs...)
+	return created, err
+}
+
+func (s *Store) DeleteWallet(userID int, actor wallet.Actor) error {
+	err := s.Storer.DeleteWallet(userID, actor)
+	s.invalidate(userID)
+	return err
+}
+
+func (s *Store) UpdateWallet(updateWallet wallet.UpdateWallet, actor wallet.Actor) (wallet.Wallet, error) {
+	result, err := s.Storer.UpdateWallet(updateWallet, actor)
+	// A wallet moved to another user is only invalidated for its former
+	// user by the wallet.updated event.
+	s.invalidate(updateWallet.UserID)
+	return result, err
+}
+
+func (s *Store) RestoreWallet(walletID int, actor wallet.Actor) (wallet.Wallet, error) {
+	result, err := s.Storer.RestoreWallet(walletID, actor)
+	if err == nil {
+		s.invalidate(result.UserID)
+	}
+	return result, err
+}
+
+func (s *Store) ChangeWalletStatus(walletID int, changeStatus wallet.ChangeStatus, actor wallet.Actor) (wallet.Wallet, error) {
+	result, err := s.Storer.ChangeWalletStatus(walletID, changeStatus, actor)
+	if err == nil {
+		s.invalidate(result.UserID)
+	}
+	return result, err
+}
+
+// WithTx runs fn in a transaction of the wrapped store, which must be a
+// wallet.Transactor. Calls through tx bypass the cache, which must never
+// hold what the transaction has not committed, and the users whose
+// wallets they changed are invalidated once it ends.
+func (s *Store) WithTx(ctx context.Context, fn func(tx wallet.Storer) error) error {
+	transactor, ok := s.Storer.(wallet.Transactor)
+	if !ok {
+		return errors.New("cache: the wrapped store has no transactions")
+	}
+	users := map[int]bool{}
+	err := transactor.WithTx(ctx, func(tx wallet.Storer) error {
+		return fn(&recorder{Storer: tx, users: users})
+	})
+	userIDs := make([]int, 0, len(users))
+	for userID := range users {
+		userIDs = append(userIDs, userID)
+	}
+	s.invalidate(userIDs...)
+	return err
+}
+
+// recorder notes the users whose wallets are changed through a
+// transaction, including by retries that rolled back, which only makes
+// the invalidation wider than needed.
+type recorder struct {
+	wallet.Storer
+	users map[int]bool
+}
+
+func (r *recorder) WithTx(ctx context.Context, fn func(tx wallet.Storer) error) error {
+	return r.Storer.(wallet.Transactor).WithTx(ctx, func(tx wallet.Storer) error {
+		return fn(&recorder{Storer: tx, users: r.users})
+	})
+}
+
+func (r *recorder) CreateWallet(createWallet wallet.CreateWallet, actor wallet.Actor) (wallet.Wallet, error) {
+	r.users[createWallet.UserID] = true
+	return r.Storer.CreateWallet(createWallet, actor)
+}
+
+func (r *recorder) ImportWallets(createWallets []wallet.CreateWallet, actor wallet.Actor) ([]wallet.Wallet, error) {
+	for _, c := range createWallets {
+		r.users[c.UserID] = true
+	}
+	return r.Storer.ImportWallets(createWallets, actor)
+}
+
+func (r *recorder) DeleteWallet(userID int, actor wallet.Actor) error {
+	r.users[userID] = true
+	return r.Storer.DeleteWallet(userID, actor)
+}
+
+func (r *recorder) UpdateWallet(updateWallet wallet.UpdateWallet, actor wallet.Actor) (wallet.Wallet, error) {
+	r.users[updateWallet.UserID] = true
+	return r.Storer.UpdateWallet(updateWallet, actor)
+}
+
+func (r *recorder) RestoreWallet(walletID int, actor wallet.Actor) (wallet.Wallet, error) {
+	result, err := r.Storer.RestoreWallet(walletID, actor)
+	if err == nil {
+		r.users[result.UserID] = true
+	}
+	return result, err
+}
+
+func (r *recorder) ChangeWalletStatus(walletID int, changeStatus wallet.ChangeStatus, actor wallet.Actor) (wallet.Wallet, error) {
+	result, err := r.Storer.ChangeWalletStatus(walletID, changeStatus, actor)
+	if err == nil {
+		r.users[result.UserID] = true
+	}
+	return result, err
+}
+
+// InvalidateEvent invalidates the wallets an outbox event is about, for
+// changes made outside the Store or by other replicas.
+func (s *Store) InvalidateEvent(message outbox.Message) {
+	var event struct {
+		Data struct {
+			Wallet   wallet.Wallet  `json:"wallet"`
+			Previous *wallet.Wallet `json:"previous"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(message.Payload, &event); err != nil {
+		s.errors.Add(1)
+		return
+	}
+	userIDs := []int{event.Data.Wallet.UserID}
+	if event.Data.Previous != nil && event.Data.Previous.UserID != event.Data.Wallet.UserID {
+		userIDs = append(userIDs, event.Data.Previous.UserID)
+	}
+	s.invalidate(userIDs...)
+}
+
+// invalidate is called whether or not a change succeeded, since a change
+// can commit and still fail, such as when the connection drops.
+func (s *Store) invalidate(userIDs ...int) {
+	ctx := context.Background()
+	if err := s.backend.Bump(ctx, listingsVersion); err != nil {
+		s.errors.Add(1)
+	}
+	seen := map[int]bool{}
+	for _, userID := range userIDs {
+		if seen[userID] {
+			continue
+		}
+		seen[userID] = true
+		if err := s.backend.Bump(ctx, userKey(userID)+":version"); err != nil {
+			s.errors.Add(1)
+		}
+	}
+}
+
+func userKey(userID int) string {
+	return fmt.Sprintf("user:%d", userID)
+}
+
+// readThrough returns the cached value of key at the version of
+// versionKey, or reads it with read and caches it. Errors are not cached.
+func readThrough[T any](s *Store, versionKey, key string, read func() (T, error)) (T, error) {
+	ctx := context.Background()
+	version, err := s.backend.Version(ctx, versionKey)
+	if err != nil {
+		s.errors.Add(1)
+		return read()
+	}
+	key = fmt.Sprintf("%s@%d", key, version)
+
+	value, ok, err := s.backend.Get(ctx, key)
+	if err != nil {
+		s.errors.Add(1)
+	}
+	if ok {
+		var result T
+		if err := gob.NewDecoder(bytes.NewReader(value)).Decode(&result); err == nil {
+			s.hits.Add(1)
+			return result, nil
+		}
+		s.errors.Add(1)
+	}
+
+	s.misses.Add(1)
+	result, err := read()
+	if err != nil {
+		return result, err
+	}
+	// gob, unlike JSON, keeps the fields hidden from the API, such as
+	// Held.
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(result); err != nil {
+		s.errors.Add(1)
+		return result, nil
+	}
+	if err := s.backend.Set(ctx, key, buf.Bytes(), s.ttl); err != nil {
+		s.errors.Add(1)
+	}
+	return result, nil
+}
@@ -0,0 +1,267 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/outbox"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/v9"
+)
+
+// StubStorer counts the reads that reach it; the methods it does not
+// override are not called by the tests.
+type StubStorer struct {
+	wallet.Storer
+	wallets []wallet.Wallet
+	reads   int
+	err     error
+}
+
+func (s *StubStorer) Wallets(filter wallet.Filter) ([]wallet.Wallet, error) {
+	s.reads++
+	var result []wallet.Wallet
+	for _, w := range s.wallets {
+		if filter.WalletType == "" || w.WalletType == filter.WalletType {
+			result = append(result, w)
+		}
+	}
+	return result, s.err
+}
+
+func (s *StubStorer) WalletByUser(userID int) (wallet.Wallet, error) {
+	s.reads++
+	if s.err != nil {
+		return wallet.Wallet{}, s.err
+	}
+	for _, w := range s.wallets {
+		if w.UserID == userID {
+			return w, nil
+		}
+	}
+	return wallet.Wallet{}, wallet.ErrNotFound
+}
+
+func (s *StubStorer) CreateWallet(createWallet wallet.CreateWallet, actor wallet.Actor) (wallet.Wallet, error) {
+	w := wallet.Wallet{ID: len(s.wallets) + 1, UserID: createWallet.UserID, WalletName: createWallet.WalletName,
+		WalletType: createWallet.WalletType, Balance: createWallet.Balance}
+	s.wallets = append(s.wallets, w)
+	return w, nil
+}
+
+func (s *StubStorer) UpdateWallet(updateWallet wallet.UpdateWallet, actor wallet.Actor) (wallet.Wallet, error) {
+	for i, w := range s.wallets {
+		if w.ID == updateWallet.ID {
+			s.wallets[i].UserID, s.wallets[i].Balance = updateWallet.UserID, updateWallet.Balance
+			return s.wallets[i], nil
+		}
+	}
+	return wallet.Wallet{}, wallet.ErrNotFound
+}
+
+// WithTx has no transaction to roll back; it only lets the tests see
+// which calls reach the stub.
+func (s *StubStorer) WithTx(ctx context.Context, fn func(tx wallet.Storer) error) error {
+	return fn(s)
+}
+
+func backends(t *testing.T) map[string]Backend {
+	server := miniredis.RunT(t)
+	return map[string]Backend{
+		"memory": NewMemory(100),
+		"redis":  NewRedis(redis.NewClient(&redis.Options{Addr: server.Addr()}), "test:"),
+	}
+}
+
+func TestStore(t *testing.T) {
+	for name, backend := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			stub := &StubStorer{wallets: []wallet.Wallet{
+				{ID: 1, UserID: 1, WalletName: "John's Savings", WalletType: "Savings", Balance: 100},
+			}}
+			store := NewStore(stub, backend, time.Minute)
+
+			for i := 0; i < 3; i++ {
+				if w, err := store.WalletByUser(1); err != nil || w.Balance != 100 {
+					t.Fatalf("expected wallet of user 1 but got %+v, %v", w, err)
+				}
+			}
+			if stub.reads != 1 {
+				t.Errorf("expected 1 read of user 1 but got %d", stub.reads)
+			}
+
+			store.UpdateWallet(wallet.UpdateWallet{ID: 1, UserID: 1, Balance: 150}, wallet.Actor{})
+			if w, _ := store.WalletByUser(1); w.Balance != 150 {
+				t.Errorf("expected updated balance 150 but got %v", w.Balance)
+			}
+
+			stub.reads = 0
+			store.Wallets(wallet.Filter{})
+			store.Wallets(wallet.Filter{})
+			store.Wallets(wallet.Filter{WalletType: "Savings"})
+			if stub.reads != 2 {
+				t.Errorf("expected 1 read per filter but got %d", stub.reads)
+			}
+
+			store.CreateWallet(wallet.CreateWallet{UserID: 2, WalletName: "Jane's Wallet", WalletType: "Savings"}, wallet.Actor{})
+			if wallets, _ := store.Wallets(wallet.Filter{}); len(wallets) != 2 {
+				t.Errorf("expected created wallet to be listed but got %+v", wallets)
+			}
+
+			if got, want := store.Stats(), (Stats{Hits: 3, Misses: 5}); got != want {
+				t.Errorf("expected stats %+v but got %+v", want, got)
+			}
+		})
+	}
+}
+
+func TestStoreInvalidateEvent(t *testing.T) {
+	stub := &StubStorer{wallets: []wallet.Wallet{{ID: 1, UserID: 1, Balance: 100}}}
+	store := NewStore(stub, NewMemory(100), time.Minute)
+	store.WalletByUser(1)
+
+	// A transaction posted outside the store.
+	stub.wallets[0].Balance = 80
+	payload, _ := json.Marshal(map[string]any{"data": map[string]any{
+		"wallet":   stub.wallets[0],
+		"previous": wallet.Wallet{ID: 1, UserID: 1, Balance: 100},
+	}})
+	store.InvalidateEvent(outbox.Message{Type: "wallet.balance_changed", Payload: payload})
+
+	if w, _ := store.WalletByUser(1); w.Balance != 80 {
+		t.Errorf("expected balance 80 after the event but got %v", w.Balance)
+	}
+}
+
+func TestStoreWithTx(t *testing.T) {
+	stub := &StubStorer{wallets: []wallet.Wallet{{ID: 1, UserID: 1, Balance: 100}}}
+	store := NewStore(stub, NewMemory(100), time.Minute)
+	store.WalletByUser(1)
+
+	err := store.WithTx(context.Background(), func(tx wallet.Storer) error {
+		if _, err := tx.UpdateWallet(wallet.UpdateWallet{ID: 1, UserID: 1, Balance: 150}, wallet.Actor{}); err != nil {
+			return err
+		}
+		tx.WalletByUser(1)
+		tx.WalletByUser(1)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unable to run transaction: %v", err)
+	}
+	if stub.reads != 3 {
+		t.Errorf("expected reads in the transaction to bypass the cache but got %d reads", stub.reads)
+	}
+	if w, _ := store.WalletByUser(1); w.Balance != 150 || stub.reads != 4 {
+		t.Errorf("expected user 1 to be invalidated after the transaction but got %+v after %d reads", w, stub.reads)
+	}
+}
+
+func TestStoreErrors(t *testing.T) {
+	stub := &StubStorer{err: errors.New("connection refused")}
+	store := NewStore(stub, NewMemory(100), time.Minute)
+
+	store.WalletByUser(1)
+	stub.err = nil
+	stub.wallets = []wallet.Wallet{{ID: 1, UserID: 1}}
+	if _, err := store.WalletByUser(1); err != nil {
+		t.Errorf("expected errors not to be cached but got %v", err)
+	}
+
+	wallets, err := store.Wallets(wallet.Filter{WalletType: "Credit Card"})
+	if err != nil || wallets == nil {
+		t.Errorf("expected an empty listing but got %#v, %v", wallets, err)
+	}
+	if wallets, _ = store.Wallets(wallet.Filter{WalletType: "Credit Card"}); wallets == nil {
+		t.Errorf("expected a cached empty listing to stay empty, not nil")
+	}
+}
+
+func TestMemory(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	m := NewMemory(2)
+	m.now = func() time.Time { return now }
+
+	m.Set(ctx, "a", []byte("1"), time.Minute)
+	m.Set(ctx, "b", []byte("2"), time.Minute)
+	m.Get(ctx, "a")
+	m.Set(ctx, "c", []byte("3"), time.Minute)
+
+	if _, ok, _ := m.Get(ctx, "b"); ok {
+		t.Errorf("expected least recently used b to be evicted")
+	}
+	if _, ok, _ := m.Get(ctx, "a"); !ok {
+		t.Errorf("expected a to be kept")
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok, _ := m.Get(ctx, "a"); ok {
+		t.Errorf("expected a to expire")
+	}
+	if m.Len() != 1 {
+		t.Errorf("expected expired a to be removed, got %d entries", m.Len())
+	}
+}
+
+func TestMemoryVersions(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	m := NewMemory(2)
+	m.now = func() time.Time { return now }
+	m.Set(ctx, "wallets:0:a", []byte("1"), time.Minute)
+
+	for _, key := range []string{"user:1", "user:2", "user:1"} {
+		m.Bump(ctx, key)
+	}
+	first, _ := m.Version(ctx, "user:1")
+	if second, _ := m.Version(ctx, "user:2"); first == 0 || first == second {
+		t.Errorf("expected distinct versions but got %d and %d", first, second)
+	}
+
+	// Within the TTL, entries under older versions may still be cached,
+	// so no version is forgotten.
+	m.Bump(ctx, "user:3")
+	if len(m.versions) != 3 {
+		t.Errorf("expected 3 versions to be kept but got %v", m.versions)
+	}
+
+	now = now.Add(time.Minute)
+	m.Bump(ctx, "user:4")
+	if _, ok := m.versions["user:4"]; len(m.versions) != 1 || !ok {
+		t.Errorf("expected the versions bumped a TTL ago to be forgotten but got %v", m.versions)
+	}
+	if v, _ := m.Version(ctx, "user:1"); v != 0 {
+		t.Errorf("expected a forgotten version to be 0 but got %d", v)
+	}
+	m.Bump(ctx, "user:1")
+	if v, _ := m.Version(ctx, "user:1"); v <= first {
+		t.Errorf("expected a version never handed out before but got %d", v)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	store := NewStore(&StubStorer{wallets: []wallet.Wallet{{ID: 1, UserID: 1}}}, NewMemory(100), time.Minute)
+	store.WalletByUser(1)
+	store.WalletByUser(1)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	res := httptest.NewRecorder()
+	c := echo.New().NewContext(req, res)
+
+	New(store).StatsHandler(c)
+
+	var got Stats
+	if err := json.Unmarshal(res.Body.Bytes(), &got); err != nil {
+		t.Fatalf("Unable to unmarshal json: %v", err)
+	}
+	if got != (Stats{Hits: 1, Misses: 1}) {
+		t.Errorf("expected 1 hit and 1 miss but got %+v", got)
+	}
+}
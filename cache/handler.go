@@ -0,0 +1,32 @@
+package cache
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+type Handler struct {
+	store Storer
+}
+
+type Storer interface {
+	Stats() Stats
+}
+
+func New(db Storer) *Handler {
+	return &Handler{store: db}
+}
+
+// StatsHandler
+//
+//	@Summary		Wallet cache stats
+//	@Description	Count the wallet reads served from the cache (hits) and from the database (misses) since the replica started.
+//	@Tags			admin
+//	@Produce		json
+//	@Success		200	{object}	Stats
+//	@Router			/api/v1/admin/cache [get]
+//	@Security		ApiKeyAuth
+func (h *Handler) StatsHandler(c echo.Context) error {
+	return c.JSON(http.StatusOK, h.store.Stats())
+}
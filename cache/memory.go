@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory is an in-process LRU Backend. Entries expire after their TTL
+// and the least recently used are evicted beyond its size. Its
+// invalidations only reach other replicas through InvalidateEvent.
+type Memory struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	lru     *list.List
+	// versions are kept apart from the entries so that eviction cannot
+	// reset a version and bring back entries it invalidated. They are
+	// taken from lastVersion, shared by every key, so a version is never
+	// handed out twice, and a key's version is forgotten once every entry
+	// made before its last bump has expired, maxTTL after it.
+	versions    map[string]version
+	lastVersion int64
+	maxTTL      time.Duration
+	now         func() time.Time
+}
+
+type version struct {
+	n        int64
+	bumpedAt time.Time
+}
+
+type entry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+func NewMemory(size int) *Memory {
+	return &Memory{
+		size:     size,
+		entries:  map[string]*list.Element{},
+		lru:      list.New(),
+		versions: map[string]version{},
+		now:      time.Now,
+	}
+}
+
+func (m *Memory) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	el, ok := m.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	e := el.Value.(*entry)
+	if !m.now().Before(e.expiresAt) {
+		m.lru.Remove(el)
+		delete(m.entries, key)
+		return nil, false, nil
+	}
+	m.lru.MoveToFront(el)
+	return e.value, true, nil
+}
+
+func (m *Memory) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.maxTTL = max(m.maxTTL, ttl)
+	if el, ok := m.entries[key]; ok {
+		e := el.Value.(*entry)
+		e.value, e.expiresAt = value, m.now().Add(ttl)
+		m.lru.MoveToFront(el)
+		return nil
+	}
+	m.entries[key] = m.lru.PushFront(&entry{key: key, value: value, expiresAt: m.now().Add(ttl)})
+	for m.lru.Len() > m.size {
+		oldest := m.lru.Back()
+		m.lru.Remove(oldest)
+		delete(m.entries, oldest.Value.(*entry).key)
+	}
+	return nil
+}
+
+func (m *Memory) Version(ctx context.Context, key string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.versions[key].n, nil
+}
+
+func (m *Memory) Bump(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	m.lastVersion++
+	m.versions[key] = version{n: m.lastVersion, bumpedAt: now}
+	if len(m.versions) > m.size {
+		// A forgotten key is back at version 0, which only the entries
+		// made before its first bump, all expired by now, were under.
+		for key, v := range m.versions {
+			if !now.Before(v.bumpedAt.Add(m.maxTTL)) {
+				delete(m.versions, key)
+			}
+		}
+	}
+	return nil
+}
+
+// Len returns how many entries are cached, expired or not.
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lru.Len()
+}
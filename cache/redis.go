@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis is a Backend on Redis, or any server speaking its protocol,
+// shared by all replicas so that an invalidation reaches them at once.
+type Redis struct {
+	client redis.Cmdable
+	prefix string
+}
+
+// NewRedis stores entries under keys starting with prefix.
+func NewRedis(client redis.Cmdable, prefix string) *Redis {
+	return &Redis{client: client, prefix: prefix}
+}
+
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := r.client.Get(ctx, r.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	return value, err == nil, err
+}
+
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return r.client.Set(ctx, r.prefix+key, value, ttl).Err()
+}
+
+// Version keys have no TTL; eviction under maxmemory policies that also
+// evict keys without one can briefly serve invalidated entries again,
+// until their TTL.
+func (r *Redis) Version(ctx context.Context, key string) (int64, error) {
+	version, err := r.client.Get(ctx, r.prefix+key).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return version, err
+}
+
+func (r *Redis) Bump(ctx context.Context, key string) error {
+	return r.client.Incr(ctx, r.prefix+key).Err()
+}
@@ -0,0 +1,206 @@
+package gql
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/apikey"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/ledger"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet"
+	"github.com/labstack/echo/v4"
+)
+
+type StubStorer struct {
+	mu            sync.Mutex
+	wallets       []wallet.Wallet
+	transactions  []ledger.Transaction
+	userBatches   [][]int
+	walletBatches [][]int
+}
+
+func (s *StubStorer) Wallets(filter wallet.Filter) ([]wallet.Wallet, error) {
+	var wallets []wallet.Wallet
+	for _, w := range s.wallets {
+		if w.ID <= filter.AfterID || (filter.WalletType != "" && w.WalletType != filter.WalletType) {
+			continue
+		}
+		if filter.Limit != 0 && len(wallets) == filter.Limit {
+			break
+		}
+		wallets = append(wallets, w)
+	}
+	return wallets, nil
+}
+
+func (s *StubStorer) WalletsByUsers(userIDs []int) ([]wallet.Wallet, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.userBatches = append(s.userBatches, userIDs)
+	var wallets []wallet.Wallet
+	for _, w := range s.wallets {
+		for _, id := range userIDs {
+			if w.UserID == id {
+				wallets = append(wallets, w)
+			}
+		}
+	}
+	return wallets, nil
+}
+
+func (s *StubStorer) TransactionsByWallets(walletIDs []int, after int, limit int) ([]ledger.Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.walletBatches = append(s.walletBatches, walletIDs)
+	var transactions []ledger.Transaction
+	perWallet := map[int]int{}
+	for _, t := range s.transactions {
+		for _, id := range walletIDs {
+			if t.WalletID == id && t.ID > after && perWallet[id] < limit {
+				transactions = append(transactions, t)
+				perWallet[id]++
+			}
+		}
+	}
+	return transactions, nil
+}
+
+func (s *StubStorer) CreateWallet(createWallet wallet.CreateWallet, actor wallet.Actor) (wallet.Wallet, error) {
+	w := wallet.Wallet{ID: len(s.wallets) + 1, UserID: createWallet.UserID, UserName: createWallet.UserName,
+		WalletName: createWallet.WalletName, WalletType: createWallet.WalletType, Balance: createWallet.Balance}
+	s.wallets = append(s.wallets, w)
+	return w, nil
+}
+
+func (s *StubStorer) UpdateWallet(updateWallet wallet.UpdateWallet, actor wallet.Actor) (wallet.Wallet, error) {
+	return wallet.Wallet{}, wallet.ErrNotFound
+}
+
+func newStore() *StubStorer {
+	created := time.Date(2024, 4, 12, 10, 45, 16, 0, time.UTC)
+	return &StubStorer{
+		wallets: []wallet.Wallet{
+			{ID: 1, UserID: 1, UserName: "Ann", WalletName: "Ann's Savings", WalletType: wallet.TypeSavings, Balance: 100, CreatedAt: created},
+			{ID: 2, UserID: 2, UserName: "Bob", WalletName: "Bob's Savings", WalletType: wallet.TypeSavings, Balance: 50, CreatedAt: created},
+			{ID: 3, UserID: 1, UserName: "Ann", WalletName: "Ann's Card", WalletType: wallet.TypeCreditCard, CreatedAt: created},
+		},
+		transactions: []ledger.Transaction{
+			{ID: 1, WalletID: 1, Amount: 100, Kind: ledger.KindAdjustment},
+			{ID: 2, WalletID: 2, Amount: 50, Kind: ledger.KindAdjustment},
+			{ID: 3, WalletID: 1, Amount: -20, Kind: ledger.KindTransfer, Reference: "invoice-9"},
+		},
+	}
+}
+
+type response struct {
+	Data   map[string]any
+	Errors []struct {
+		Message    string
+		Extensions map[string]any
+	}
+}
+
+func query(t *testing.T, h *Handler, scopes []string, q string) response {
+	t.Helper()
+	body, _ := json.Marshal(Request{Query: q})
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body)))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	apikey.SetKey(c, apikey.APIKey{ID: 1, Name: "test", Scopes: scopes})
+	if err := h.GraphQL(c); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("unexpected response %d: %v", rec.Code, err)
+	}
+	var res response
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatalf("unable to unmarshal %q: %v", rec.Body, err)
+	}
+	return res
+}
+
+var readScope = []string{apikey.ScopeWalletsRead}
+
+func TestUsers(t *testing.T) {
+	store := newStore()
+	res := query(t, New(store), readScope, `{ users(ids: ["1", "2", "9"]) { name wallets { walletName } } }`)
+
+	users := res.Data["users"].([]any)
+	if len(users) != 3 || users[2] != nil {
+		t.Fatalf("expected 2 users and null but got %v", users)
+	}
+	ann := users[0].(map[string]any)
+	if ann["name"] != "Ann" || len(ann["wallets"].([]any)) != 2 {
+		t.Errorf("unexpected user %v", ann)
+	}
+	if want := [][]int{{1, 2, 9}}; !reflect.DeepEqual(store.userBatches, want) {
+		t.Errorf("expected the users' wallets to be loaded in one batch %v but got %v", want, store.userBatches)
+	}
+}
+
+func TestWallets(t *testing.T) {
+	store := newStore()
+	h := New(store)
+
+	res := query(t, h, readScope, `{ wallets(first: 2) {
+		nodes { id user { id wallets { id } } transactions(first: 1) { nodes { amount } pageInfo { hasNextPage } } }
+		pageInfo { endCursor hasNextPage } } }`)
+	conn := res.Data["wallets"].(map[string]any)
+	if page := conn["pageInfo"].(map[string]any); page["endCursor"] != "2" || page["hasNextPage"] != true {
+		t.Errorf("unexpected page %v", page)
+	}
+	first := conn["nodes"].([]any)[0].(map[string]any)
+	transactions := first["transactions"].(map[string]any)
+	if len(transactions["nodes"].([]any)) != 1 || transactions["pageInfo"].(map[string]any)["hasNextPage"] != true {
+		t.Errorf("unexpected transactions %v", transactions)
+	}
+	if len(store.userBatches) != 1 || len(store.walletBatches) != 1 {
+		t.Errorf("expected one batch of users and of wallets but got %v and %v", store.userBatches, store.walletBatches)
+	}
+
+	res = query(t, h, readScope, `{ wallets(first: 1) { nodes { transactions(first: 1, after: "1") {
+		nodes { id } pageInfo { hasNextPage } } } } }`)
+	page := res.Data["wallets"].(map[string]any)["nodes"].([]any)[0].(map[string]any)["transactions"].(map[string]any)
+	if nodes := page["nodes"].([]any); len(nodes) != 1 || nodes[0].(map[string]any)["id"] != "3" || page["pageInfo"].(map[string]any)["hasNextPage"] != false {
+		t.Errorf("expected the last transaction after 1 but got %v", page)
+	}
+
+	res = query(t, h, readScope, `{ wallets(after: "2") { nodes { walletName } pageInfo { hasNextPage } } }`)
+	nodes := res.Data["wallets"].(map[string]any)["nodes"].([]any)
+	if len(nodes) != 1 || nodes[0].(map[string]any)["walletName"] != "Ann's Card" {
+		t.Errorf("unexpected second page %v", nodes)
+	}
+
+	res = query(t, h, readScope, `{ wallets(first: 500) { nodes { id } } }`)
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "BAD_USER_INPUT" {
+		t.Errorf("expected BAD_USER_INPUT but got %+v", res.Errors)
+	}
+}
+
+func TestMutations(t *testing.T) {
+	store := newStore()
+	h := New(store)
+	create := `mutation { createWallet(input: {userId: "4", userName: "Cat", walletName: "Cat's Savings", walletType: "Savings", balance: 10}) {
+		id user { name } } }`
+
+	res := query(t, h, readScope, create)
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "FORBIDDEN" {
+		t.Errorf("expected FORBIDDEN without the write scope but got %+v", res.Errors)
+	}
+
+	res = query(t, h, []string{apikey.ScopeWalletsWrite}, create)
+	created, _ := res.Data["createWallet"].(map[string]any)
+	if len(res.Errors) != 0 || created["id"] != "4" || created["user"].(map[string]any)["name"] != "Cat" {
+		t.Errorf("unexpected result %+v", res)
+	}
+
+	res = query(t, h, []string{apikey.ScopeWalletsWrite}, `mutation { updateWallet(input: {id: "9", userId: "4",
+		userName: "Cat", walletName: "Cat's Savings", walletType: "Savings", balance: 10}) { id } }`)
+	if len(res.Errors) != 1 || res.Errors[0].Extensions["code"] != "NOT_FOUND" {
+		t.Errorf("expected NOT_FOUND but got %+v", res.Errors)
+	}
+}
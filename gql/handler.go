@@ -0,0 +1,136 @@
+// Package gql serves wallets, their users and transactions over GraphQL.
+package gql
+
+import (
+	"context"
+	_ "embed"
+	"net/http"
+	"sync"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/apikey"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/ledger"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet"
+	"github.com/graph-gophers/graphql-go"
+	"github.com/labstack/echo/v4"
+)
+
+//go:embed schema.graphql
+var schema string
+
+// maxParallelism bounds how many resolvers run at once, and so how many
+// keys a loader can batch.
+const maxParallelism = 100
+
+type Handler struct {
+	schema *graphql.Schema
+	store  Storer
+}
+
+type Storer interface {
+	Wallets(filter wallet.Filter) ([]wallet.Wallet, error)
+	// WalletsByUsers and TransactionsByWallets back the loaders.
+	// TransactionsByWallets returns up to limit transactions of each
+	// wallet after the id after, in id order.
+	WalletsByUsers(userIDs []int) ([]wallet.Wallet, error)
+	TransactionsByWallets(walletIDs []int, after int, limit int) ([]ledger.Transaction, error)
+	CreateWallet(createWallet wallet.CreateWallet, actor wallet.Actor) (wallet.Wallet, error)
+	UpdateWallet(updateWallet wallet.UpdateWallet, actor wallet.Actor) (wallet.Wallet, error)
+}
+
+func New(db Storer) *Handler {
+	return &Handler{
+		schema: graphql.MustParseSchema(schema, &resolver{store: db}, graphql.MaxParallelism(maxParallelism)),
+		store:  db,
+	}
+}
+
+type Err struct {
+	Message string `json:"message"`
+}
+
+type Request struct {
+	Query         string         `json:"query" example:"{ users(ids: [\"1\"]) { name wallets { walletName balance } } }"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+// requestState is what the resolvers of one request share.
+type requestState struct {
+	key           apikey.APIKey
+	actor         wallet.Actor
+	store         Storer
+	walletsByUser *loader[int, []wallet.Wallet]
+	// transactionPages has a loader per page of transactions asked for,
+	// which usually is the same for every wallet of a query.
+	mu               sync.Mutex
+	transactionPages map[transactionPage]*loader[int, []ledger.Transaction]
+}
+
+// transactionPage is up to limit transactions after the id after.
+type transactionPage struct {
+	after, limit int
+}
+
+type stateKey struct{}
+
+func state(ctx context.Context) *requestState {
+	return ctx.Value(stateKey{}).(*requestState)
+}
+
+func (h *Handler) newState(c echo.Context) *requestState {
+	key, _ := apikey.FromContext(c)
+	return &requestState{
+		key:   key,
+		actor: wallet.ActorFrom(c),
+		walletsByUser: newLoader(func(userIDs []int) (map[int][]wallet.Wallet, error) {
+			wallets, err := h.store.WalletsByUsers(userIDs)
+			byUser := map[int][]wallet.Wallet{}
+			for _, w := range wallets {
+				byUser[w.UserID] = append(byUser[w.UserID], w)
+			}
+			return byUser, err
+		}),
+		store:            h.store,
+		transactionPages: map[transactionPage]*loader[int, []ledger.Transaction]{},
+	}
+}
+
+// transactionsByWallet returns the loader of page for the wallets.
+func (s *requestState) transactionsByWallet(page transactionPage) *loader[int, []ledger.Transaction] {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, ok := s.transactionPages[page]
+	if !ok {
+		l = newLoader(func(walletIDs []int) (map[int][]ledger.Transaction, error) {
+			transactions, err := s.store.TransactionsByWallets(walletIDs, page.after, page.limit)
+			byWallet := map[int][]ledger.Transaction{}
+			for _, t := range transactions {
+				byWallet[t.WalletID] = append(byWallet[t.WalletID], t)
+			}
+			return byWallet, err
+		})
+		s.transactionPages[page] = l
+	}
+	return l
+}
+
+// GraphQL
+//
+//	@Summary		GraphQL endpoint
+//	@Description	Query users, wallets and transactions, or create and update wallets, in one round trip. The schema is in gql/schema.graphql. Mutations need the wallets:write scope.
+//	@Tags			graphql
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		Request	true	"GraphQL request"
+//	@Success		200		{object}	map[string]interface{}
+//	@Router			/graphql [post]
+//	@Failure		400		{object}	Err
+//	@Security		ApiKeyAuth
+func (h *Handler) GraphQL(c echo.Context) error {
+	var req Request
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: "invalid GraphQL request"})
+	}
+	ctx := context.WithValue(c.Request().Context(), stateKey{}, h.newState(c))
+	return c.JSON(http.StatusOK, h.schema.Exec(ctx, req.Query, req.OperationName, req.Variables))
+}
@@ -0,0 +1,85 @@
+package gql
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"time"
+)
+
+// batchWait is how long a loader collects keys before fetching them.
+const batchWait = 2 * time.Millisecond
+
+// loader batches the keys loaded within batchWait of each other into one
+// call of fetch, like a dataloader, and caches the values for the rest
+// of the request. Resolvers run concurrently, so loading the wallets of
+// N users costs one query rather than N.
+type loader[K cmp.Ordered, V any] struct {
+	fetch   func(keys []K) (map[K]V, error)
+	mu      sync.Mutex
+	calls   map[K]*call[V]
+	pending map[K]*call[V]
+}
+
+type call[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+func newLoader[K cmp.Ordered, V any](fetch func(keys []K) (map[K]V, error)) *loader[K, V] {
+	return &loader[K, V]{fetch: fetch, calls: map[K]*call[V]{}, pending: map[K]*call[V]{}}
+}
+
+func (l *loader[K, V]) Load(key K) (V, error) {
+	values, err := l.LoadMany([]K{key})
+	return values[0], err
+}
+
+// LoadMany loads the keys in one batch and returns their values in
+// order, or the first error.
+func (l *loader[K, V]) LoadMany(keys []K) ([]V, error) {
+	l.mu.Lock()
+	calls := make([]*call[V], len(keys))
+	for i, key := range keys {
+		c, ok := l.calls[key]
+		if !ok {
+			c = &call[V]{done: make(chan struct{})}
+			l.calls[key] = c
+			if len(l.pending) == 0 {
+				time.AfterFunc(batchWait, l.dispatch)
+			}
+			l.pending[key] = c
+		}
+		calls[i] = c
+	}
+	l.mu.Unlock()
+
+	values := make([]V, len(keys))
+	for i, c := range calls {
+		<-c.done
+		if c.err != nil {
+			return nil, c.err
+		}
+		values[i] = c.value
+	}
+	return values, nil
+}
+
+func (l *loader[K, V]) dispatch() {
+	l.mu.Lock()
+	batch := l.pending
+	l.pending = map[K]*call[V]{}
+	l.mu.Unlock()
+
+	keys := make([]K, 0, len(batch))
+	for key := range batch {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	values, err := l.fetch(keys)
+	for key, c := range batch {
+		c.value, c.err = values[key], err
+		close(c.done)
+	}
+}
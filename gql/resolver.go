@@ -0,0 +1,408 @@
+package gql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/apikey"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/ledger"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet"
+	"github.com/graph-gophers/graphql-go"
+)
+
+const maxPageSize = 100
+
+var (
+	errInvalidID   = errors.New("invalid id")
+	errInvalidPage = fmt.Errorf("first must be between 1 and %d", maxPageSize)
+)
+
+// Error is a resolver error whose code is reported in the extensions of
+// the GraphQL error, as an HTTP status would be by the REST API.
+type Error struct {
+	Err  error
+	Code string
+}
+
+func (e Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e Error) Unwrap() error {
+	return e.Err
+}
+
+func (e Error) Extensions() map[string]any {
+	return map[string]any{"code": e.Code}
+}
+
+// errCode maps errors from the store to an Error.
+func errCode(err error) error {
+	code := "INTERNAL"
+	switch {
+	case errors.Is(err, wallet.ErrNotFound):
+		code = "NOT_FOUND"
+	case errors.Is(err, wallet.ErrInvalidWallet), errors.Is(err, errInvalidID), errors.Is(err, errInvalidPage):
+		code = "BAD_USER_INPUT"
+	case errors.Is(err, wallet.ErrInvalidTransition),
+		errors.Is(err, wallet.ErrBalanceNotZero),
+		errors.Is(err, wallet.ErrWalletFrozen),
+		errors.Is(err, wallet.ErrWalletClosed),
+		errors.Is(err, wallet.ErrInsufficientFunds),
+		errors.Is(err, wallet.ErrCreditLimitExceeded):
+		code = "CONFLICT"
+	}
+	return Error{Err: err, Code: code}
+}
+
+func parseID(id graphql.ID) (int, error) {
+	n, err := strconv.Atoi(string(id))
+	if err != nil || n <= 0 {
+		return 0, errCode(fmt.Errorf("%w %q", errInvalidID, id))
+	}
+	return n, nil
+}
+
+func toID(id int) graphql.ID {
+	return graphql.ID(strconv.Itoa(id))
+}
+
+// page reads the first and after arguments of a connection.
+func page(first int32, after *graphql.ID) (int, int, error) {
+	n := int(first)
+	if n < 1 || n > maxPageSize {
+		return 0, 0, errCode(errInvalidPage)
+	}
+	if after == nil {
+		return n, 0, nil
+	}
+	afterID, err := parseID(*after)
+	return n, afterID, err
+}
+
+type resolver struct {
+	store Storer
+}
+
+func (r *resolver) Wallets(ctx context.Context, args struct {
+	WalletType *string
+	First      int32
+	After      *graphql.ID
+}) (*walletConnection, error) {
+	first, after, err := page(args.First, args.After)
+	if err != nil {
+		return nil, err
+	}
+	filter := wallet.Filter{AfterID: after, Limit: first + 1}
+	if args.WalletType != nil {
+		filter.WalletType = *args.WalletType
+	}
+	wallets, err := r.store.Wallets(filter)
+	if err != nil {
+		return nil, errCode(err)
+	}
+	conn := &walletConnection{}
+	if len(wallets) > first {
+		wallets, conn.page.hasNextPage = wallets[:first], true
+	}
+	for _, w := range wallets {
+		conn.nodes = append(conn.nodes, &walletResolver{w})
+	}
+	if len(wallets) > 0 {
+		conn.page.endCursor = wallets[len(wallets)-1].ID
+	}
+	return conn, nil
+}
+
+func (r *resolver) User(ctx context.Context, args struct{ ID graphql.ID }) (*userResolver, error) {
+	users, err := r.Users(ctx, struct{ IDs []graphql.ID }{[]graphql.ID{args.ID}})
+	if err != nil {
+		return nil, err
+	}
+	return users[0], nil
+}
+
+// Users resolves to nil for the users without wallets.
+func (r *resolver) Users(ctx context.Context, args struct{ IDs []graphql.ID }) ([]*userResolver, error) {
+	ids := make([]int, len(args.IDs))
+	for i, id := range args.IDs {
+		var err error
+		if ids[i], err = parseID(id); err != nil {
+			return nil, err
+		}
+	}
+	wallets, err := state(ctx).walletsByUser.LoadMany(ids)
+	if err != nil {
+		return nil, errCode(err)
+	}
+	users := make([]*userResolver, len(ids))
+	for i, userWallets := range wallets {
+		if len(userWallets) > 0 {
+			users[i] = &userResolver{id: ids[i], name: userWallets[0].UserName}
+		}
+	}
+	return users, nil
+}
+
+// requireScope rejects calls whose API key lacks scope, for mutations
+// served from a route that only requires reading.
+func requireScope(ctx context.Context, scope string) error {
+	if !state(ctx).key.HasScope(scope) {
+		return Error{Err: errors.New("API key lacks scope " + scope), Code: "FORBIDDEN"}
+	}
+	return nil
+}
+
+type createWalletInput struct {
+	UserID      graphql.ID
+	UserName    string
+	WalletName  string
+	WalletType  string
+	Balance     float64
+	CreditLimit *float64
+}
+
+func (r *resolver) CreateWallet(ctx context.Context, args struct{ Input createWalletInput }) (*walletResolver, error) {
+	if err := requireScope(ctx, apikey.ScopeWalletsWrite); err != nil {
+		return nil, err
+	}
+	userID, err := parseID(args.Input.UserID)
+	if err != nil {
+		return nil, err
+	}
+	createWallet := wallet.CreateWallet{
+		UserID:      userID,
+		UserName:    args.Input.UserName,
+		WalletName:  args.Input.WalletName,
+		WalletType:  args.Input.WalletType,
+		Balance:     args.Input.Balance,
+		CreditLimit: args.Input.CreditLimit,
+	}
+	if err := createWallet.Validate(); err != nil {
+		return nil, errCode(err)
+	}
+	w, err := r.store.CreateWallet(createWallet, state(ctx).actor)
+	if err != nil {
+		return nil, errCode(err)
+	}
+	return &walletResolver{w}, nil
+}
+
+type updateWalletInput struct {
+	ID          graphql.ID
+	UserID      graphql.ID
+	UserName    string
+	WalletName  string
+	WalletType  string
+	Balance     float64
+	CreditLimit *float64
+}
+
+func (r *resolver) UpdateWallet(ctx context.Context, args struct{ Input updateWalletInput }) (*walletResolver, error) {
+	if err := requireScope(ctx, apikey.ScopeWalletsWrite); err != nil {
+		return nil, err
+	}
+	id, err := parseID(args.Input.ID)
+	if err != nil {
+		return nil, err
+	}
+	userID, err := parseID(args.Input.UserID)
+	if err != nil {
+		return nil, err
+	}
+	updateWallet := wallet.UpdateWallet{
+		ID:          id,
+		UserID:      userID,
+		UserName:    args.Input.UserName,
+		WalletName:  args.Input.WalletName,
+		WalletType:  args.Input.WalletType,
+		Balance:     args.Input.Balance,
+		CreditLimit: args.Input.CreditLimit,
+	}
+	if err := updateWallet.Validate(); err != nil {
+		return nil, errCode(err)
+	}
+	w, err := r.store.UpdateWallet(updateWallet, state(ctx).actor)
+	if err != nil {
+		return nil, errCode(err)
+	}
+	return &walletResolver{w}, nil
+}
+
+type userResolver struct {
+	id   int
+	name string
+}
+
+func (u *userResolver) ID() graphql.ID {
+	return toID(u.id)
+}
+
+func (u *userResolver) Name() string {
+	return u.name
+}
+
+func (u *userResolver) Wallets(ctx context.Context) ([]*walletResolver, error) {
+	wallets, err := state(ctx).walletsByUser.Load(u.id)
+	if err != nil {
+		return nil, errCode(err)
+	}
+	resolvers := make([]*walletResolver, len(wallets))
+	for i, w := range wallets {
+		resolvers[i] = &walletResolver{w}
+	}
+	return resolvers, nil
+}
+
+type walletResolver struct {
+	w wallet.Wallet
+}
+
+func (r *walletResolver) ID() graphql.ID {
+	return toID(r.w.ID)
+}
+
+func (r *walletResolver) User() *userResolver {
+	return &userResolver{id: r.w.UserID, name: r.w.UserName}
+}
+
+func (r *walletResolver) WalletName() string {
+	return r.w.WalletName
+}
+
+func (r *walletResolver) WalletType() string {
+	return r.w.WalletType
+}
+
+func (r *walletResolver) Balance() float64 {
+	return r.w.Balance
+}
+
+func (r *walletResolver) AvailableBalance() float64 {
+	return r.w.AvailableBalance
+}
+
+func (r *walletResolver) Status() string {
+	return string(r.w.Status)
+}
+
+func (r *walletResolver) CreditLimit() *float64 {
+	return r.w.CreditLimit
+}
+
+func (r *walletResolver) AvailableCredit() *float64 {
+	return r.w.AvailableCredit
+}
+
+func (r *walletResolver) OutstandingBalance() *float64 {
+	return r.w.OutstandingBalance
+}
+
+func (r *walletResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: r.w.CreatedAt}
+}
+
+func (r *walletResolver) Transactions(ctx context.Context, args struct {
+	First int32
+	After *graphql.ID
+}) (*transactionConnection, error) {
+	first, after, err := page(args.First, args.After)
+	if err != nil {
+		return nil, err
+	}
+	// One more than asked for tells whether there is a next page.
+	transactions, err := state(ctx).transactionsByWallet(transactionPage{after: after, limit: first + 1}).Load(r.w.ID)
+	if err != nil {
+		return nil, errCode(err)
+	}
+	conn := &transactionConnection{}
+	for _, t := range transactions {
+		if len(conn.nodes) == first {
+			conn.page.hasNextPage = true
+			break
+		}
+		conn.nodes = append(conn.nodes, &transactionResolver{t})
+		conn.page.endCursor = t.ID
+	}
+	return conn, nil
+}
+
+type transactionResolver struct {
+	t ledger.Transaction
+}
+
+func (r *transactionResolver) ID() graphql.ID {
+	return toID(r.t.ID)
+}
+
+func (r *transactionResolver) Amount() float64 {
+	return r.t.Amount
+}
+
+func (r *transactionResolver) BalanceAfter() float64 {
+	return r.t.BalanceAfter
+}
+
+func (r *transactionResolver) Kind() string {
+	return r.t.Kind
+}
+
+func (r *transactionResolver) Description() string {
+	return r.t.Description
+}
+
+func (r *transactionResolver) Reference() *string {
+	if r.t.Reference == "" {
+		return nil
+	}
+	return &r.t.Reference
+}
+
+func (r *transactionResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: r.t.CreatedAt}
+}
+
+type pageInfo struct {
+	endCursor   int
+	hasNextPage bool
+}
+
+func (p pageInfo) EndCursor() *graphql.ID {
+	if p.endCursor == 0 {
+		return nil
+	}
+	id := toID(p.endCursor)
+	return &id
+}
+
+func (p pageInfo) HasNextPage() bool {
+	return p.hasNextPage
+}
+
+type walletConnection struct {
+	nodes []*walletResolver
+	page  pageInfo
+}
+
+func (c *walletConnection) Nodes() []*walletResolver {
+	return c.nodes
+}
+
+func (c *walletConnection) PageInfo() pageInfo {
+	return c.page
+}
+
+type transactionConnection struct {
+	nodes []*transactionResolver
+	page  pageInfo
+}
+
+func (c *transactionConnection) Nodes() []*transactionResolver {
+	return c.nodes
+}
+
+func (c *transactionConnection) PageInfo() pageInfo {
+	return c.page
+}
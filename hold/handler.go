@@ -0,0 +1,165 @@
+package hold
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet"
+	"github.com/labstack/echo/v4"
+)
+
+type Handler struct {
+	store Storer
+}
+
+type Storer interface {
+	WalletHolds(walletID int) ([]Hold, error)
+	PlaceHold(walletID int, placeHold PlaceHold, expiresAt time.Time, actor wallet.Actor) (Hold, error)
+	CaptureHold(holdID int, captureHold CaptureHold, actor wallet.Actor) (Hold, error)
+	VoidHold(holdID int, actor wallet.Actor) (Hold, error)
+}
+
+func New(db Storer) *Handler {
+	return &Handler{store: db}
+}
+
+type Err struct {
+	Message string `json:"message"`
+}
+
+func errStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrNotFound), errors.Is(err, wallet.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrInvalidHold):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrNotActive),
+		errors.Is(err, ErrOverCapture),
+		errors.Is(err, wallet.ErrWalletFrozen),
+		errors.Is(err, wallet.ErrWalletClosed),
+		errors.Is(err, wallet.ErrInsufficientFunds),
+		errors.Is(err, wallet.ErrCreditLimitExceeded):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// WalletHoldsHandler
+//
+//	@Summary		List wallet holds
+//	@Description	List the authorization holds of a wallet, newest first
+//	@Tags			hold
+//	@Produce		json
+//	@Success		200	{array}		Hold
+//	@Router			/api/v1/wallets/{id}/holds [get]
+//	@Failure		400	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Param			id path int true "Wallet ID"
+//	@Security		ApiKeyAuth
+func (h *Handler) WalletHoldsHandler(c echo.Context) error {
+	walletID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: "invalid wallet id"})
+	}
+	holds, err := h.store.WalletHolds(walletID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, Err{Message: err.Error()})
+	}
+	return c.JSON(http.StatusOK, holds)
+}
+
+// PlaceHold
+//
+//	@Summary		Place a hold
+//	@Description	Reserve funds in a wallet. The available balance drops by the amount, the balance only once the hold is captured.
+//	@Tags			hold
+//	@Accept			json
+//	@Produce		json
+//	@Param			id path int true "Wallet ID"
+//	@Param			request	body		PlaceHold	true	"Request Body"
+//	@Success		201		{object}	Hold
+//	@Router			/api/v1/wallets/{id}/holds [post]
+//	@Failure		400		{object}	Err
+//	@Failure		404		{object}	Err
+//	@Failure		409		{object}	Err
+//	@Failure		500		{object}	Err
+//	@Security		ApiKeyAuth
+func (h *Handler) PlaceHold(c echo.Context) error {
+	walletID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: "invalid wallet id"})
+	}
+	var placeHold PlaceHold
+	if err := c.Bind(&placeHold); err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+	ttl, err := placeHold.Validate()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+	result, err := h.store.PlaceHold(walletID, placeHold, time.Now().Add(ttl), wallet.ActorFrom(c))
+	if err != nil {
+		return c.JSON(errStatus(err), Err{Message: err.Error()})
+	}
+	return c.JSON(http.StatusCreated, result)
+}
+
+// CaptureHold
+//
+//	@Summary		Capture a hold
+//	@Description	Debit the wallet by all or part of an active hold. Whatever is not captured is released.
+//	@Tags			hold
+//	@Accept			json
+//	@Produce		json
+//	@Param			id path int true "Hold ID"
+//	@Param			request	body		CaptureHold	false	"Request Body"
+//	@Success		200		{object}	Hold
+//	@Router			/api/v1/holds/{id}/capture [post]
+//	@Failure		400		{object}	Err
+//	@Failure		404		{object}	Err
+//	@Failure		409		{object}	Err
+//	@Failure		500		{object}	Err
+//	@Security		ApiKeyAuth
+func (h *Handler) CaptureHold(c echo.Context) error {
+	holdID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: "invalid hold id"})
+	}
+	var captureHold CaptureHold
+	if err := c.Bind(&captureHold); err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+	result, err := h.store.CaptureHold(holdID, captureHold, wallet.ActorFrom(c))
+	if err != nil {
+		return c.JSON(errStatus(err), Err{Message: err.Error()})
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+// VoidHold
+//
+//	@Summary		Void a hold
+//	@Description	Release an active hold without moving money
+//	@Tags			hold
+//	@Produce		json
+//	@Param			id path int true "Hold ID"
+//	@Success		200	{object}	Hold
+//	@Router			/api/v1/holds/{id}/void [post]
+//	@Failure		400	{object}	Err
+//	@Failure		404	{object}	Err
+//	@Failure		409	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Security		ApiKeyAuth
+func (h *Handler) VoidHold(c echo.Context) error {
+	holdID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: "invalid hold id"})
+	}
+	result, err := h.store.VoidHold(holdID, wallet.ActorFrom(c))
+	if err != nil {
+		return c.JSON(errStatus(err), Err{Message: err.Error()})
+	}
+	return c.JSON(http.StatusOK, result)
+}
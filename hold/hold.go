@@ -0,0 +1,103 @@
+package hold
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusActive   Status = "active"
+	StatusCaptured Status = "captured"
+	StatusVoided   Status = "voided"
+	StatusExpired  Status = "expired"
+)
+
+const (
+	DefaultTTL = 7 * 24 * time.Hour
+	MaxTTL     = 30 * 24 * time.Hour
+)
+
+var (
+	ErrNotFound    = errors.New("hold not found")
+	ErrInvalidHold = errors.New("invalid hold")
+	ErrNotActive   = errors.New("hold is no longer active")
+	ErrOverCapture = errors.New("capture exceeds the held amount")
+)
+
+// Hold reserves funds in a wallet: it lowers the available balance but
+// not the balance until it is captured. A hold that is neither captured
+// nor voided before ExpiresAt releases its funds.
+type Hold struct {
+	ID          int       `json:"id" example:"1"`
+	WalletID    int       `json:"wallet_id" example:"1"`
+	Amount      float64   `json:"amount" example:"25.00"`
+	Captured    float64   `json:"captured" example:"0"`
+	Status      Status    `json:"status" example:"active"`
+	Description string    `json:"description" example:"Order #1001"`
+	ExpiresAt   time.Time `json:"expires_at" example:"2024-04-01T14:19:00Z"`
+	CreatedAt   time.Time `json:"created_at" example:"2024-03-25T14:19:00.729237Z"`
+	UpdatedAt   time.Time `json:"updated_at" example:"2024-03-25T14:19:00.729237Z"`
+}
+
+type PlaceHold struct {
+	Amount      float64 `json:"amount" example:"25.00"`
+	Description string  `json:"description" example:"Order #1001"`
+	// TTL is a duration such as "15m" or "72h", DefaultTTL when empty.
+	TTL string `json:"ttl,omitempty" example:"72h"`
+}
+
+// Validate checks the hold and returns how long it lasts.
+func (p PlaceHold) Validate() (time.Duration, error) {
+	if p.Amount <= 0 || math.Round(p.Amount*100) != p.Amount*100 {
+		return 0, fmt.Errorf("%w: amount must be positive with at most two decimals", ErrInvalidHold)
+	}
+	if p.TTL == "" {
+		return DefaultTTL, nil
+	}
+	ttl, err := time.ParseDuration(p.TTL)
+	if err != nil || ttl <= 0 || ttl > MaxTTL {
+		return 0, fmt.Errorf("%w: ttl must be a duration up to %s", ErrInvalidHold, MaxTTL)
+	}
+	return ttl, nil
+}
+
+// CaptureHold captures Amount of a hold, or all of it when Amount is
+// nil. The rest of a partially captured hold is released.
+type CaptureHold struct {
+	Amount *float64 `json:"amount,omitempty" example:"20.00"`
+}
+
+// Capture returns the amount to capture from h.
+func (h Hold) Capture(capture CaptureHold, now time.Time) (float64, error) {
+	if err := h.CheckActive(now); err != nil {
+		return 0, err
+	}
+	if capture.Amount == nil {
+		return h.Amount, nil
+	}
+	amount := *capture.Amount
+	if amount <= 0 || math.Round(amount*100) != amount*100 {
+		return 0, fmt.Errorf("%w: amount must be positive with at most two decimals", ErrInvalidHold)
+	}
+	if amount > h.Amount {
+		return 0, ErrOverCapture
+	}
+	return amount, nil
+}
+
+// CheckActive checks that h may still be captured or voided.
+func (h Hold) CheckActive(now time.Time) error {
+	if h.Status != StatusActive || !now.Before(h.ExpiresAt) {
+		return ErrNotActive
+	}
+	return nil
+}
+
+// Reference is the ledger reference of the capture of a hold.
+func Reference(holdID int) string {
+	return fmt.Sprintf("hold:%d", holdID)
+}
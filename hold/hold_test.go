@@ -0,0 +1,159 @@
+package hold
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet"
+	"github.com/labstack/echo/v4"
+)
+
+type StubStorer struct {
+	holds []Hold
+	err   error
+}
+
+func (s *StubStorer) WalletHolds(walletID int) ([]Hold, error) {
+	return s.holds, s.err
+}
+
+func (s *StubStorer) PlaceHold(walletID int, placeHold PlaceHold, expiresAt time.Time, actor wallet.Actor) (Hold, error) {
+	if s.err != nil {
+		return Hold{}, s.err
+	}
+	h := Hold{ID: len(s.holds) + 1, WalletID: walletID, Amount: placeHold.Amount, Status: StatusActive, ExpiresAt: expiresAt}
+	s.holds = append(s.holds, h)
+	return h, nil
+}
+
+func (s *StubStorer) find(holdID int) (*Hold, error) {
+	for i := range s.holds {
+		if s.holds[i].ID == holdID {
+			return &s.holds[i], nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (s *StubStorer) CaptureHold(holdID int, captureHold CaptureHold, actor wallet.Actor) (Hold, error) {
+	h, err := s.find(holdID)
+	if err != nil {
+		return Hold{}, err
+	}
+	amount, err := h.Capture(captureHold, time.Now())
+	if err != nil {
+		return Hold{}, err
+	}
+	h.Status, h.Captured = StatusCaptured, amount
+	return *h, nil
+}
+
+func (s *StubStorer) VoidHold(holdID int, actor wallet.Actor) (Hold, error) {
+	h, err := s.find(holdID)
+	if err != nil {
+		return Hold{}, err
+	}
+	if err := h.CheckActive(time.Now()); err != nil {
+		return Hold{}, err
+	}
+	h.Status = StatusVoided
+	return *h, nil
+}
+
+func TestPlaceHoldValidate(t *testing.T) {
+	tests := []struct {
+		placeHold PlaceHold
+		ttl       time.Duration
+		valid     bool
+	}{
+		{PlaceHold{Amount: 25}, DefaultTTL, true},
+		{PlaceHold{Amount: 25, TTL: "15m"}, 15 * time.Minute, true},
+		{PlaceHold{Amount: 0}, 0, false},
+		{PlaceHold{Amount: 1.005}, 0, false},
+		{PlaceHold{Amount: 25, TTL: "forever"}, 0, false},
+		{PlaceHold{Amount: 25, TTL: "1000h"}, 0, false},
+	}
+	for _, tt := range tests {
+		ttl, err := tt.placeHold.Validate()
+		if (err == nil) != tt.valid || ttl != tt.ttl {
+			t.Errorf("%+v: expected ttl %v valid=%v but got %v %v", tt.placeHold, tt.ttl, tt.valid, ttl, err)
+		}
+	}
+}
+
+func TestCapture(t *testing.T) {
+	now := time.Date(2024, 4, 1, 12, 0, 0, 0, time.UTC)
+	h := Hold{Amount: 25, Status: StatusActive, ExpiresAt: now.Add(time.Hour)}
+	amount := func(f float64) *float64 { return &f }
+
+	tests := []struct {
+		hold    Hold
+		capture CaptureHold
+		want    float64
+		err     error
+	}{
+		{h, CaptureHold{}, 25, nil},
+		{h, CaptureHold{Amount: amount(20)}, 20, nil},
+		{h, CaptureHold{Amount: amount(25.01)}, 0, ErrOverCapture},
+		{h, CaptureHold{Amount: amount(-1)}, 0, ErrInvalidHold},
+		{Hold{Amount: 25, Status: StatusVoided, ExpiresAt: now.Add(time.Hour)}, CaptureHold{}, 0, ErrNotActive},
+		{Hold{Amount: 25, Status: StatusActive, ExpiresAt: now}, CaptureHold{}, 0, ErrNotActive},
+	}
+	for _, tt := range tests {
+		got, err := tt.hold.Capture(tt.capture, now)
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("%+v %+v: expected %v %v but got %v %v", tt.hold, tt.capture, tt.want, tt.err, got, err)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	do := func(handler echo.HandlerFunc, id, body string) *httptest.ResponseRecorder {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+		_ = handler(c)
+		return rec
+	}
+
+	t.Run("given hold should place, capture and refuse a second capture", func(t *testing.T) {
+		h := New(&StubStorer{})
+		if rec := do(h.PlaceHold, "1", `{"amount": 25, "ttl": "1h"}`); rec.Code != http.StatusCreated {
+			t.Fatalf("expected status 201 but got %d %s", rec.Code, rec.Body)
+		}
+		if rec := do(h.CaptureHold, "1", `{"amount": 20}`); rec.Code != http.StatusOK {
+			t.Errorf("expected status 200 but got %d %s", rec.Code, rec.Body)
+		}
+		if rec := do(h.CaptureHold, "1", ``); rec.Code != http.StatusConflict {
+			t.Errorf("expected status 409 but got %d", rec.Code)
+		}
+		if rec := do(h.VoidHold, "1", ``); rec.Code != http.StatusConflict {
+			t.Errorf("expected status 409 but got %d", rec.Code)
+		}
+		if rec := do(h.VoidHold, "2", ``); rec.Code != http.StatusNotFound {
+			t.Errorf("expected status 404 but got %d", rec.Code)
+		}
+	})
+
+	t.Run("given insufficient funds should respond 409", func(t *testing.T) {
+		h := New(&StubStorer{err: wallet.ErrInsufficientFunds})
+		if rec := do(h.PlaceHold, "1", `{"amount": 25}`); rec.Code != http.StatusConflict {
+			t.Errorf("expected status 409 but got %d", rec.Code)
+		}
+	})
+
+	t.Run("given invalid amount should respond 400", func(t *testing.T) {
+		h := New(&StubStorer{})
+		if rec := do(h.PlaceHold, "1", `{"amount": -5}`); rec.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400 but got %d", rec.Code)
+		}
+	})
+}
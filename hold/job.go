@@ -0,0 +1,30 @@
+package hold
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet"
+)
+
+// Expirer marks the active holds that expired before now.
+type Expirer interface {
+	ExpireHolds(now time.Time, actor wallet.Actor) (int, error)
+}
+
+// ExpiryJob returns a job that marks expired holds. Expired holds stop
+// counting against the available balance as soon as they expire; the
+// job only brings their status up to date.
+func ExpiryJob(expirer Expirer) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		n, err := expirer.ExpireHolds(time.Now(), wallet.Actor{Name: "system:hold-expiry"})
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			log.Printf("expired %d holds", n)
+		}
+		return nil
+	}
+}
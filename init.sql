@@ -1,5 +1,6 @@
 -- Creation of product table
 CREATE TYPE wallet_type AS ENUM ('Savings', 'Credit Card', 'Crypto Wallet');
+CREATE TYPE wallet_status AS ENUM ('active', 'frozen', 'closed');
 
 CREATE TABLE IF NOT EXISTS user_wallet (
 	id SERIAL PRIMARY KEY,
@@ -8,14 +9,241 @@ CREATE TABLE IF NOT EXISTS user_wallet (
 	wallet_name VARCHAR(255) NOT NULL,
 	wallet_type wallet_type NOT NULL,
 	balance DECIMAL(10, 2) NOT NULL,
+	credit_limit DECIMAL(10, 2) CHECK (credit_limit >= 0),
+	status wallet_status NOT NULL DEFAULT 'active',
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	deleted_at TIMESTAMP,
+	CHECK (credit_limit IS NULL OR wallet_type = 'Credit Card')
+);
+
+CREATE INDEX IF NOT EXISTS user_wallet_deleted_at_idx ON user_wallet (deleted_at) WHERE deleted_at IS NOT NULL;
+
+INSERT INTO user_wallet (user_id, user_name, wallet_name, wallet_type, balance, credit_limit) VALUES
+(1, 'John Doe', 'John Savings', 'Savings', 1000.00, NULL),
+(1, 'John Doe', 'John Credit Card', 'Credit Card', 500.00, 5000.00),
+(1, 'John Doe', 'John Crypto Wallet', 'Crypto Wallet', 100.00, NULL),
+(2, 'Jane Doe', 'Jane Savings', 'Savings', 2000.00, NULL),
+(2, 'Jane Doe', 'Jane Credit Card', 'Credit Card', 1000.00, 10000.00),
+(2, 'Jane Doe', 'Jane Crypto Wallet', 'Crypto Wallet', 200.00, NULL);
+
+
+CREATE TABLE IF NOT EXISTS api_key (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	prefix VARCHAR(16) NOT NULL,
+	key_hash CHAR(64) NOT NULL UNIQUE,
+	scopes TEXT[] NOT NULL DEFAULT '{}',
+	expires_at TIMESTAMP,
+	last_used_at TIMESTAMP,
+	revoked_at TIMESTAMP,
 	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
-INSERT INTO user_wallet (user_id, user_name, wallet_name, wallet_type, balance) VALUES
-(1, 'John Doe', 'John Savings', 'Savings', 1000.00),
-(1, 'John Doe', 'John Credit Card', 'Credit Card', 500.00),
-(1, 'John Doe', 'John Crypto Wallet', 'Crypto Wallet', 100.00),
-(2, 'Jane Doe', 'Jane Savings', 'Savings', 2000.00),
-(2, 'Jane Doe', 'Jane Credit Card', 'Credit Card', 1000.00),
-(2, 'Jane Doe', 'Jane Crypto Wallet', 'Crypto Wallet', 200.00);
+-- A bucket is deleted once it has refilled, at full_at, since a missing
+-- bucket behaves exactly like a full one.
+CREATE TABLE IF NOT EXISTS rate_limit_bucket (
+	key VARCHAR(255) PRIMARY KEY,
+	tokens DOUBLE PRECISION NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL,
+	full_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS rate_limit_bucket_full_at_idx ON rate_limit_bucket (full_at);
+
+-- Append-only: rows can be inserted but never changed or removed.
+CREATE TABLE IF NOT EXISTS wallet_audit (
+	id BIGSERIAL PRIMARY KEY,
+	actor VARCHAR(255) NOT NULL,
+	action VARCHAR(32) NOT NULL,
+	wallet_id INT NOT NULL,
+	before JSONB,
+	after JSONB,
+	reason TEXT NOT NULL DEFAULT '',
+	request_id VARCHAR(255) NOT NULL DEFAULT '',
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS wallet_audit_wallet_id_idx ON wallet_audit (wallet_id, id);
+
+CREATE OR REPLACE FUNCTION wallet_audit_immutable() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'wallet_audit is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER wallet_audit_immutable
+	BEFORE UPDATE OR DELETE OR TRUNCATE ON wallet_audit
+	FOR EACH STATEMENT EXECUTE FUNCTION wallet_audit_immutable();
+
+-- Ledger of every balance change. amount is positive for credits and
+-- negative for debits; reference, when set, makes posting idempotent.
+CREATE TABLE IF NOT EXISTS wallet_transaction (
+	id BIGSERIAL PRIMARY KEY,
+	wallet_id INT NOT NULL,
+	amount DECIMAL(10, 2) NOT NULL,
+	balance_after DECIMAL(10, 2) NOT NULL,
+	kind VARCHAR(32) NOT NULL,
+	description TEXT NOT NULL DEFAULT '',
+	reference VARCHAR(255) UNIQUE,
+	reversal_of BIGINT REFERENCES wallet_transaction(id),
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS wallet_transaction_wallet_id_idx ON wallet_transaction (wallet_id, id);
+CREATE INDEX IF NOT EXISTS wallet_transaction_reversal_of_idx ON wallet_transaction (reversal_of) WHERE reversal_of IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS interest_product (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	annual_rate DOUBLE PRECISION NOT NULL CHECK (annual_rate >= 0),
+	compounding VARCHAR(16) NOT NULL CHECK (compounding IN ('daily', 'monthly', 'annually')),
+	day_count VARCHAR(16) NOT NULL CHECK (day_count IN ('ACT/365', 'ACT/360', '30/360')),
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Interest has been posted for every period before accrued_through.
+CREATE TABLE IF NOT EXISTS savings_interest (
+	wallet_id INT PRIMARY KEY REFERENCES user_wallet(id) ON DELETE CASCADE,
+	product_id INT NOT NULL REFERENCES interest_product(id),
+	accrued_through TIMESTAMPTZ NOT NULL
+);
+
+-- Monthly statements of Credit Card wallets. Balances are negative when
+-- the cardholder owes money.
+CREATE TABLE IF NOT EXISTS card_statement (
+	wallet_id INT NOT NULL REFERENCES user_wallet(id) ON DELETE CASCADE,
+	period CHAR(7) NOT NULL,
+	period_start TIMESTAMPTZ NOT NULL,
+	period_end TIMESTAMPTZ NOT NULL,
+	opening_balance DECIMAL(10, 2) NOT NULL,
+	closing_balance DECIMAL(10, 2) NOT NULL,
+	minimum_payment DECIMAL(10, 2) NOT NULL CHECK (minimum_payment >= 0),
+	due_date TIMESTAMPTZ NOT NULL,
+	late_fee DECIMAL(10, 2) NOT NULL DEFAULT 0,
+	late_fee_assessed_at TIMESTAMPTZ,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (wallet_id, period)
+);
+
+CREATE INDEX IF NOT EXISTS card_statement_due_idx ON card_statement (due_date) WHERE late_fee_assessed_at IS NULL;
+
+-- Assets held by Crypto Wallets. balance is in the asset's smallest unit
+-- (satoshi, wei), as its precision varies per asset.
+CREATE TABLE IF NOT EXISTS wallet_asset (
+	wallet_id INT NOT NULL REFERENCES user_wallet(id) ON DELETE CASCADE,
+	asset VARCHAR(16) NOT NULL,
+	address VARCHAR(128) NOT NULL,
+	balance NUMERIC(38, 0) NOT NULL DEFAULT 0 CHECK (balance >= 0),
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (wallet_id, asset)
+);
+
+-- Authorization holds. Active, unexpired holds are subtracted from the
+-- available balance of their wallet.
+CREATE TABLE IF NOT EXISTS wallet_hold (
+	id SERIAL PRIMARY KEY,
+	wallet_id INT NOT NULL REFERENCES user_wallet(id) ON DELETE CASCADE,
+	amount DECIMAL(10, 2) NOT NULL CHECK (amount > 0),
+	captured DECIMAL(10, 2) NOT NULL DEFAULT 0 CHECK (captured >= 0 AND captured <= amount),
+	status VARCHAR(16) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'captured', 'voided', 'expired')),
+	description TEXT NOT NULL DEFAULT '',
+	expires_at TIMESTAMPTZ NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS wallet_hold_active_idx ON wallet_hold (wallet_id, expires_at) WHERE status = 'active';
+
+-- Standing orders between wallets, one-off or recurring (RRULE).
+CREATE TABLE IF NOT EXISTS scheduled_transfer (
+	id SERIAL PRIMARY KEY,
+	from_wallet_id INT NOT NULL REFERENCES user_wallet(id) ON DELETE CASCADE,
+	to_wallet_id INT NOT NULL REFERENCES user_wallet(id) ON DELETE CASCADE,
+	amount DECIMAL(10, 2) NOT NULL CHECK (amount > 0),
+	description TEXT NOT NULL DEFAULT '',
+	recurrence TEXT NOT NULL DEFAULT '',
+	start_at TIMESTAMPTZ NOT NULL,
+	next_run_at TIMESTAMPTZ,
+	status VARCHAR(16) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'completed', 'failed', 'cancelled')),
+	attempts INT NOT NULL DEFAULT 0,
+	last_run_at TIMESTAMPTZ,
+	last_error TEXT NOT NULL DEFAULT '',
+	created_by VARCHAR(255) NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	CHECK (from_wallet_id <> to_wallet_id)
+);
+
+CREATE INDEX IF NOT EXISTS scheduled_transfer_due_idx ON scheduled_transfer (next_run_at) WHERE status = 'active';
+
+CREATE TABLE IF NOT EXISTS transfer_run (
+	id BIGSERIAL PRIMARY KEY,
+	transfer_id INT NOT NULL REFERENCES scheduled_transfer(id) ON DELETE CASCADE,
+	scheduled_for TIMESTAMPTZ NOT NULL,
+	attempt INT NOT NULL,
+	succeeded BOOLEAN NOT NULL,
+	error TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Outbound webhooks: subscriptions to wallet events and one delivery per
+-- event and subscription, retried until delivered or dead.
+CREATE TABLE IF NOT EXISTS webhook_subscription (
+	id SERIAL PRIMARY KEY,
+	url TEXT NOT NULL,
+	events TEXT[] NOT NULL,
+	secret TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS webhook_delivery (
+	id BIGSERIAL PRIMARY KEY,
+	subscription_id INT NOT NULL REFERENCES webhook_subscription(id) ON DELETE CASCADE,
+	event_id VARCHAR(64) NOT NULL,
+	event_type VARCHAR(64) NOT NULL,
+	payload JSONB NOT NULL,
+	status VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'dead')),
+	attempts INT NOT NULL DEFAULT 0,
+	next_attempt_at TIMESTAMPTZ DEFAULT now(),
+	last_error TEXT NOT NULL DEFAULT '',
+	last_status_code INT NOT NULL DEFAULT 0,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	delivered_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS webhook_delivery_due_idx ON webhook_delivery (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS webhook_delivery_status_idx ON webhook_delivery (status, id);
+
+-- Transactional outbox: domain events written in the transaction of the
+-- change they describe, and published in order by the relay.
+CREATE TABLE IF NOT EXISTS outbox (
+	id BIGSERIAL PRIMARY KEY,
+	event_id VARCHAR(64) NOT NULL UNIQUE,
+	wallet_id INT NOT NULL,
+	event_type VARCHAR(64) NOT NULL,
+	payload JSONB NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	attempts INT NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	sent_at TIMESTAMPTZ,
+	-- Set once the relay gave up on the message; see outbox.MaxAttempts.
+	dead_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS outbox_unsent_idx ON outbox (wallet_id, id) WHERE sent_at IS NULL AND dead_at IS NULL;
+CREATE INDEX IF NOT EXISTS outbox_sent_idx ON outbox (sent_at) WHERE sent_at IS NOT NULL;
+
+-- The webhook publisher may see an event more than once.
+CREATE UNIQUE INDEX IF NOT EXISTS webhook_delivery_event_idx ON webhook_delivery (subscription_id, event_id);
+
+-- Notifies the replicas streaming wallet changes of every outbox message
+-- as it commits.
+CREATE OR REPLACE FUNCTION outbox_notify() RETURNS trigger AS $$
+BEGIN
+	PERFORM pg_notify('wallet_events', NEW.id::text);
+	RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
 
+CREATE TRIGGER outbox_notify
+	AFTER INSERT ON outbox
+	FOR EACH ROW EXECUTE FUNCTION outbox_notify();
@@ -0,0 +1,116 @@
+package interest
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet"
+	"github.com/labstack/echo/v4"
+)
+
+type Handler struct {
+	store Storer
+}
+
+type Storer interface {
+	Products() ([]Product, error)
+	CreateProduct(createProduct CreateProduct) (Product, error)
+	AttachProduct(walletID int, productID int) error
+	Accounts() ([]Account, error)
+	// AccrueInterest posts the interest of account for the period from
+	// account.AccruedThrough to end and advances AccruedThrough to end.
+	AccrueInterest(account Account, end time.Time) error
+}
+
+func New(db Storer) *Handler {
+	return &Handler{store: db}
+}
+
+type Err struct {
+	Message string `json:"message"`
+}
+
+// ProductsHandler
+//
+//	@Summary		List interest products
+//	@Description	List interest products
+//	@Tags			interest
+//	@Produce		json
+//	@Success		200	{array}		Product
+//	@Router			/api/v1/admin/interest-products [get]
+//	@Failure		500	{object}	Err
+//	@Security		ApiKeyAuth
+func (h *Handler) ProductsHandler(c echo.Context) error {
+	products, err := h.store.Products()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, Err{Message: err.Error()})
+	}
+	return c.JSON(http.StatusOK, products)
+}
+
+// CreateProduct
+//
+//	@Summary		Create interest product
+//	@Description	Create interest product. annual_rate is a fraction, e.g. 0.025 for 2.5% a year.
+//	@Tags			interest
+//	@Accept			json
+//	@Produce		json
+//	@Success		201	{object}	Product
+//	@Router			/api/v1/admin/interest-products [post]
+//	@Failure		400	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Param			CreateProduct body CreateProduct true "Body for create interest product"
+//	@Security		ApiKeyAuth
+func (h *Handler) CreateProduct(c echo.Context) error {
+	var createProduct CreateProduct
+	if err := c.Bind(&createProduct); err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+	if err := createProduct.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+	result, err := h.store.CreateProduct(createProduct)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, Err{Message: err.Error()})
+	}
+	return c.JSON(http.StatusCreated, result)
+}
+
+// AttachProduct
+//
+//	@Summary		Attach interest product to wallet
+//	@Description	Attach an interest product to a Savings wallet. Interest accrues from today; replacing the product of a wallet keeps its accrual date.
+//	@Tags			interest
+//	@Accept			json
+//	@Produce		plain
+//	@Success		200	{string}	string
+//	@Router			/api/v1/wallets/{id}/interest-product [put]
+//	@Failure		400	{object}	Err
+//	@Failure		404	{object}	Err
+//	@Failure		409	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Param			id path int true "Wallet ID"
+//	@Param			AttachProduct body AttachProduct true "Interest product to attach"
+//	@Security		ApiKeyAuth
+func (h *Handler) AttachProduct(c echo.Context) error {
+	walletID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: "invalid wallet id"})
+	}
+	var attachProduct AttachProduct
+	if err := c.Bind(&attachProduct); err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+	err = h.store.AttachProduct(walletID, attachProduct.ProductID)
+	switch {
+	case errors.Is(err, ErrNotFound), errors.Is(err, wallet.ErrNotFound):
+		return c.JSON(http.StatusNotFound, Err{Message: err.Error()})
+	case errors.Is(err, ErrNotSavings):
+		return c.JSON(http.StatusConflict, Err{Message: err.Error()})
+	case err != nil:
+		return c.JSON(http.StatusInternalServerError, Err{Message: err.Error()})
+	}
+	return c.String(http.StatusOK, "Attach Success")
+}
@@ -0,0 +1,209 @@
+package interest
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/ledger"
+)
+
+type Compounding string
+
+// Interest is posted to the wallet at the end of every compounding
+// period, so it earns interest itself from the next period on.
+const (
+	Daily    Compounding = "daily"
+	Monthly  Compounding = "monthly"
+	Annually Compounding = "annually"
+)
+
+type DayCount string
+
+const (
+	Actual365 DayCount = "ACT/365"
+	Actual360 DayCount = "ACT/360"
+	Thirty360 DayCount = "30/360"
+)
+
+var (
+	ErrNotFound       = errors.New("interest product not found")
+	ErrInvalidProduct = errors.New("invalid interest product")
+	ErrNotSavings     = errors.New("interest products can only be attached to Savings wallets")
+	// ErrAlreadyAccrued is returned when a period was accrued by another
+	// run of the job in the meantime.
+	ErrAlreadyAccrued = errors.New("interest already accrued for period")
+)
+
+type Product struct {
+	ID          int         `json:"id" example:"1"`
+	Name        string      `json:"name" example:"Easy Saver"`
+	AnnualRate  float64     `json:"annual_rate" example:"0.025"`
+	Compounding Compounding `json:"compounding" example:"monthly"`
+	DayCount    DayCount    `json:"day_count" example:"ACT/365"`
+	CreatedAt   time.Time   `json:"created_at" example:"2024-03-25T14:19:00.729237Z"`
+}
+
+type CreateProduct struct {
+	Name        string      `json:"name" example:"Easy Saver"`
+	AnnualRate  float64     `json:"annual_rate" example:"0.025"`
+	Compounding Compounding `json:"compounding" example:"monthly"`
+	DayCount    DayCount    `json:"day_count" example:"ACT/365"`
+}
+
+func (c CreateProduct) Validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidProduct)
+	}
+	if c.AnnualRate < 0 || c.AnnualRate > 1 {
+		return fmt.Errorf("%w: annual_rate must be a fraction between 0 and 1", ErrInvalidProduct)
+	}
+	switch c.Compounding {
+	case Daily, Monthly, Annually:
+	default:
+		return fmt.Errorf("%w: compounding must be one of daily, monthly, annually", ErrInvalidProduct)
+	}
+	switch c.DayCount {
+	case Actual365, Actual360, Thirty360:
+	default:
+		return fmt.Errorf("%w: day_count must be one of ACT/365, ACT/360, 30/360", ErrInvalidProduct)
+	}
+	return nil
+}
+
+type AttachProduct struct {
+	ProductID int `json:"product_id" example:"1"`
+}
+
+// Account is a Savings wallet earning interest under a product.
+// Interest has been posted for every period before AccruedThrough.
+type Account struct {
+	WalletID       int
+	Product        Product
+	AccruedThrough time.Time
+}
+
+// PeriodEnd returns the end of the compounding period that contains t.
+// Periods are calendar days, months or years in UTC, so the first
+// period after a product is attached may be shorter.
+func (p Product) PeriodEnd(t time.Time) time.Time {
+	t = t.UTC()
+	switch p.Compounding {
+	case Daily:
+		return time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+	case Annually:
+		return time.Date(t.Year()+1, time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// Reference is the ledger reference of the interest posted for the
+// period ending at end, unique per wallet and period.
+func Reference(walletID int, end time.Time) string {
+	return fmt.Sprintf("interest:%d:%s", walletID, end.UTC().Format(time.DateOnly))
+}
+
+// referenceEnd returns the end of the period of the interest posted with
+// reference, as made by Reference.
+func referenceEnd(reference string) (time.Time, bool) {
+	parts := strings.Split(reference, ":")
+	if len(parts) != 3 || parts[0] != "interest" {
+		return time.Time{}, false
+	}
+	end, err := time.Parse(time.DateOnly, parts[2])
+	return end, err == nil
+}
+
+// BalanceChange is the balance of a wallet from At on, until its next
+// change.
+type BalanceChange struct {
+	At      time.Time
+	Balance float64
+}
+
+// BalanceHistory works out the balance of a wallet at start, and how it
+// changed until end, from its current balance and the ledger entries
+// posted since start. Interest posted late, when the job catches up,
+// counts from the end of the period it was earned in, so that it
+// compounds as if it had been posted on time.
+func BalanceHistory(current float64, since []ledger.Transaction, start, end time.Time) (float64, []BalanceChange) {
+	type posting struct {
+		at     time.Time
+		amount float64
+	}
+	postings := make([]posting, 0, len(since))
+	opening := current
+	for _, t := range since {
+		at := t.CreatedAt
+		if periodEnd, ok := referenceEnd(t.Reference); ok && t.Kind == ledger.KindInterest {
+			at = periodEnd
+		}
+		if !at.After(start) {
+			continue
+		}
+		opening -= t.Amount
+		postings = append(postings, posting{at, t.Amount})
+	}
+	sort.SliceStable(postings, func(i, j int) bool { return postings[i].at.Before(postings[j].at) })
+
+	var changes []BalanceChange
+	balance := opening
+	for _, posting := range postings {
+		if !posting.at.Before(end) {
+			break
+		}
+		balance += posting.amount
+		changes = append(changes, BalanceChange{At: posting.at, Balance: ledger.Round(balance)})
+	}
+	return ledger.Round(opening), changes
+}
+
+// InterestOn returns the interest earned over [start, end) by a wallet
+// whose balance was opening at start and then changed as changes, in
+// time order. Each day earns on its closing balance, so a deposit earns
+// from the day it lands on, and changes from end on earn nothing in the
+// period. Negative balances earn nothing.
+func (p Product) InterestOn(opening float64, changes []BalanceChange, start, end time.Time) float64 {
+	total, balance := 0.0, opening
+	for day := start; day.Before(end); {
+		next := time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, time.UTC)
+		if next.After(end) {
+			next = end
+		}
+		for len(changes) > 0 && changes[0].At.Before(next) {
+			balance = changes[0].Balance
+			changes = changes[1:]
+		}
+		if balance > 0 {
+			total += balance * p.AnnualRate * p.DayCount.YearFraction(day, next)
+		}
+		day = next
+	}
+	return ledger.Round(total)
+}
+
+// YearFraction returns the length of [start, end) in years under the
+// day count convention.
+func (d DayCount) YearFraction(start, end time.Time) float64 {
+	switch d {
+	case Actual360:
+		return days(start, end) / 360
+	case Thirty360:
+		y1, m1, d1 := start.UTC().Date()
+		y2, m2, d2 := end.UTC().Date()
+		if d1 == 31 {
+			d1 = 30
+		}
+		if d2 == 31 && d1 == 30 {
+			d2 = 30
+		}
+		return float64(360*(y2-y1)+30*(int(m2)-int(m1))+(d2-d1)) / 360
+	}
+	return days(start, end) / 365
+}
+
+func days(start, end time.Time) float64 {
+	return end.Sub(start).Hours() / 24
+}
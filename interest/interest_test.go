@@ -0,0 +1,159 @@
+package interest
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/ledger"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+type StubStorer struct {
+	accounts []Account
+	balance  float64
+	posted   map[string]float64
+}
+
+func (s *StubStorer) Products() ([]Product, error) { return nil, nil }
+
+func (s *StubStorer) CreateProduct(createProduct CreateProduct) (Product, error) {
+	return Product{}, nil
+}
+
+func (s *StubStorer) AttachProduct(walletID int, productID int) error { return nil }
+
+func (s *StubStorer) Accounts() ([]Account, error) {
+	return append([]Account(nil), s.accounts...), nil
+}
+
+func (s *StubStorer) AccrueInterest(account Account, end time.Time) error {
+	for i, a := range s.accounts {
+		if a.WalletID != account.WalletID {
+			continue
+		}
+		if !a.AccruedThrough.Equal(account.AccruedThrough) {
+			return ErrAlreadyAccrued
+		}
+		amount := a.Product.InterestOn(s.balance, nil, a.AccruedThrough, end)
+		s.posted[Reference(a.WalletID, end)] += amount
+		s.balance += amount
+		s.accounts[i].AccruedThrough = end
+	}
+	return nil
+}
+
+func TestYearFraction(t *testing.T) {
+	tests := []struct {
+		dayCount DayCount
+		start    time.Time
+		end      time.Time
+		want     float64
+	}{
+		{Actual365, date(2024, 1, 1), date(2024, 2, 1), 31.0 / 365},
+		{Actual360, date(2024, 1, 1), date(2024, 2, 1), 31.0 / 360},
+		{Thirty360, date(2024, 1, 1), date(2024, 2, 1), 30.0 / 360},
+		{Thirty360, date(2024, 1, 31), date(2024, 3, 31), 60.0 / 360},
+		{Thirty360, date(2024, 2, 1), date(2024, 3, 1), 30.0 / 360},
+	}
+	for _, tt := range tests {
+		got := tt.dayCount.YearFraction(tt.start, tt.end)
+		if math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("%s %v-%v: expected %v but got %v", tt.dayCount, tt.start, tt.end, tt.want, got)
+		}
+	}
+}
+
+func TestPeriodEnd(t *testing.T) {
+	at := time.Date(2024, 12, 31, 15, 4, 5, 0, time.UTC)
+	tests := map[Compounding]time.Time{
+		Daily:    date(2025, 1, 1),
+		Monthly:  date(2025, 1, 1),
+		Annually: date(2025, 1, 1),
+	}
+	for compounding, want := range tests {
+		if got := (Product{Compounding: compounding}).PeriodEnd(at); !got.Equal(want) {
+			t.Errorf("%s: expected %v but got %v", compounding, want, got)
+		}
+	}
+	if got := (Product{Compounding: Monthly}).PeriodEnd(date(2024, 4, 1)); !got.Equal(date(2024, 5, 1)) {
+		t.Errorf("expected a period starting on a boundary to end a month later, got %v", got)
+	}
+}
+
+func TestAccrue(t *testing.T) {
+	product := Product{ID: 1, AnnualRate: 0.0365, Compounding: Daily, DayCount: Actual365}
+	store := &StubStorer{
+		accounts: []Account{{WalletID: 7, Product: product, AccruedThrough: date(2024, 4, 1)}},
+		balance:  1000,
+		posted:   map[string]float64{},
+	}
+	now := time.Date(2024, 4, 4, 12, 0, 0, 0, time.UTC)
+
+	if err := Accrue(context.Background(), store, now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := Accrue(context.Background(), store, now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(store.posted) != 3 {
+		t.Fatalf("expected interest for the 3 complete days but got %v", store.posted)
+	}
+	for ref, amount := range store.posted {
+		if amount > 0.11 {
+			t.Errorf("expected %s to be paid once, got %v", ref, amount)
+		}
+	}
+	if got := store.posted[Reference(7, date(2024, 4, 2))]; got != 0.1 {
+		t.Errorf("expected 1000 * 3.65%% / 365 = 0.10 for the first day but got %v", got)
+	}
+	if !store.accounts[0].AccruedThrough.Equal(date(2024, 4, 4)) {
+		t.Errorf("expected accrual to stop at the current, incomplete day, got %v", store.accounts[0].AccruedThrough)
+	}
+}
+
+func TestInterestOnBalanceHistory(t *testing.T) {
+	product := Product{ID: 1, AnnualRate: 0.0365, Compounding: Monthly, DayCount: Actual365}
+	start, end := date(2024, 4, 1), date(2024, 5, 1)
+	since := []ledger.Transaction{
+		{Amount: 1000, Kind: ledger.KindAdjustment, CreatedAt: time.Date(2024, 4, 16, 10, 0, 0, 0, time.UTC)},
+		{Amount: 500, Kind: ledger.KindAdjustment, CreatedAt: time.Date(2024, 5, 3, 9, 0, 0, 0, time.UTC)},
+	}
+
+	opening, changes := BalanceHistory(2500, since, start, end)
+	if opening != 1000 {
+		t.Errorf("expected an opening balance of 1000 but got %v", opening)
+	}
+	if len(changes) != 1 || changes[0].Balance != 2000 {
+		t.Fatalf("expected only the deposit inside the period but got %v", changes)
+	}
+
+	// 15 days on 1000 and 15 days, from the deposit day on, on 2000.
+	if got := product.InterestOn(opening, changes, start, end); got != 4.5 {
+		t.Errorf("expected 1.50 + 3.00 = 4.50 but got %v", got)
+	}
+}
+
+func TestBalanceHistoryLateInterest(t *testing.T) {
+	// The job catches up April and May at once, on June 2: April's
+	// interest, posted then, earns from May 1 on.
+	posted := time.Date(2024, 6, 2, 0, 5, 0, 0, time.UTC)
+	since := []ledger.Transaction{
+		{Amount: 3, Kind: ledger.KindInterest, Reference: Reference(7, date(2024, 5, 1)), CreatedAt: posted},
+	}
+
+	opening, changes := BalanceHistory(1003, since, date(2024, 5, 1), date(2024, 6, 1))
+	if opening != 1003 || len(changes) != 0 {
+		t.Errorf("expected April's interest in May's opening balance, got %v and %v", opening, changes)
+	}
+
+	opening, changes = BalanceHistory(1003, since, date(2024, 4, 1), date(2024, 5, 1))
+	if opening != 1000 || len(changes) != 0 {
+		t.Errorf("expected April's interest to be left out of April, got %v and %v", opening, changes)
+	}
+}
@@ -0,0 +1,50 @@
+package interest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// AccrualJob returns a job that posts the interest of every period that
+// has ended since the last run.
+func AccrualJob(store Storer) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		return Accrue(ctx, store, time.Now())
+	}
+}
+
+// Accrue posts interest for every complete period before now. Each
+// period is posted at most once: the store only accrues a period that
+// starts at the account's AccruedThrough and advances it in the same
+// transaction, so re-running the job, or running it on several replicas
+// at once, never pays twice.
+func Accrue(ctx context.Context, store Storer, now time.Time) error {
+	accounts, err := store.Accounts()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, account := range accounts {
+		for {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			end := account.Product.PeriodEnd(account.AccruedThrough)
+			if end.After(now) {
+				break
+			}
+			err := store.AccrueInterest(account, end)
+			if errors.Is(err, ErrAlreadyAccrued) {
+				break
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("wallet %d: %w", account.WalletID, err))
+				break
+			}
+			account.AccruedThrough = end
+		}
+	}
+	return errors.Join(errs...)
+}
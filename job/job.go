@@ -0,0 +1,24 @@
+package job
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Run calls fn every interval until ctx is done. Failures are logged and
+// retried on the next tick, so a job must be safe to run again.
+func Run(ctx context.Context, name string, interval time.Duration, fn func(ctx context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := fn(ctx); err != nil {
+			log.Printf("job %s failed: %v", name, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package ledger
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet"
+	"github.com/labstack/echo/v4"
+)
+
+type Handler struct {
+	store Storer
+}
+
+type Storer interface {
+	WalletTransactions(walletID int) ([]Transaction, error)
+	ReverseTransaction(transactionID int, reverse Reverse, actor wallet.Actor) (Transaction, error)
+}
+
+func New(db Storer) *Handler {
+	return &Handler{store: db}
+}
+
+type Err struct {
+	Message string `json:"message"`
+}
+
+func errStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrNotFound), errors.Is(err, wallet.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrInvalidReversal):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrAlreadyReversed),
+		errors.Is(err, wallet.ErrWalletFrozen),
+		errors.Is(err, wallet.ErrWalletClosed),
+		errors.Is(err, wallet.ErrInsufficientFunds),
+		errors.Is(err, wallet.ErrCreditLimitExceeded):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// WalletTransactionsHandler
+//
+//	@Summary		Get wallet transactions
+//	@Description	Get the ledger of a wallet, oldest first
+//	@Tags			ledger
+//	@Produce		json
+//	@Success		200	{array}		Transaction
+//	@Router			/api/v1/wallets/{id}/transactions [get]
+//	@Failure		400	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Param			id path int true "Wallet ID"
+//	@Security		ApiKeyAuth
+func (h *Handler) WalletTransactionsHandler(c echo.Context) error {
+	walletID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: "invalid wallet id"})
+	}
+	transactions, err := h.store.WalletTransactions(walletID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, Err{Message: err.Error()})
+	}
+	return c.JSON(http.StatusOK, transactions)
+}
+
+// ReverseTransaction
+//
+//	@Summary		Reverse a transaction
+//	@Description	Post a compensating entry for all or part of a transaction, e.g. to correct a mistake or refund a charge. Both stay in the wallet's history, linked by reversal_of. Reversals and the legs of transfers cannot be reversed.
+//	@Tags			ledger
+//	@Accept			json
+//	@Produce		json
+//	@Param			id path int true "Transaction ID"
+//	@Param			request	body		Reverse	false	"Request Body"
+//	@Success		201		{object}	Transaction
+//	@Router			/api/v1/transactions/{id}/reverse [post]
+//	@Failure		400		{object}	Err
+//	@Failure		404		{object}	Err
+//	@Failure		409		{object}	Err
+//	@Failure		500		{object}	Err
+//	@Security		ApiKeyAuth
+func (h *Handler) ReverseTransaction(c echo.Context) error {
+	transactionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: "invalid transaction id"})
+	}
+	var reverse Reverse
+	if err := c.Bind(&reverse); err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+	result, err := h.store.ReverseTransaction(transactionID, reverse, wallet.ActorFrom(c))
+	if err != nil {
+		return c.JSON(errStatus(err), Err{Message: err.Error()})
+	}
+	return c.JSON(http.StatusCreated, result)
+}
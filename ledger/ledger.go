@@ -0,0 +1,145 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	KindAdjustment = "adjustment"
+	KindInterest   = "interest"
+	KindFee        = "fee"
+	KindCapture    = "capture"
+	KindTransfer   = "transfer"
+	KindReversal   = "reversal"
+)
+
+var (
+	ErrDuplicate       = errors.New("transaction already posted")
+	ErrNotFound        = errors.New("transaction not found")
+	ErrInvalidReversal = errors.New("invalid reversal")
+	ErrAlreadyReversed = errors.New("transaction already fully reversed")
+	ErrInvalidTransfer = errors.New("invalid transfer")
+)
+
+// Transaction is an entry in a wallet's ledger. Amount is positive for
+// credits and negative for debits. Reference makes posting idempotent: a
+// second transaction with the same reference is rejected with
+// ErrDuplicate.
+type Transaction struct {
+	ID           int     `json:"id" example:"1"`
+	WalletID     int     `json:"wallet_id" example:"1"`
+	Amount       float64 `json:"amount" example:"-25.50"`
+	BalanceAfter float64 `json:"balance_after" example:"974.50"`
+	Kind         string  `json:"kind" example:"adjustment"`
+	Description  string  `json:"description" example:"Balance corrected by support"`
+	Reference    string  `json:"reference,omitempty" example:"interest:1:2024-04"`
+	// ReversalOf links a reversal to the transaction it compensates;
+	// Reversed is how much of a transaction its reversals add up to.
+	ReversalOf *int      `json:"reversal_of,omitempty" example:"7"`
+	Reversed   float64   `json:"reversed,omitempty" example:"0"`
+	CreatedAt  time.Time `json:"created_at" example:"2024-03-25T14:19:00.729237Z"`
+}
+
+type PostTransaction struct {
+	WalletID    int
+	Amount      float64
+	Kind        string
+	Description string
+	Reference   string
+	// AllowOverLimit lets a debit take the balance below zero or past the
+	// credit limit, for fees the bank charges regardless of funds. The
+	// wallet's status is still checked.
+	AllowOverLimit bool
+	ReversalOf     *int
+}
+
+// Reverse reverses Amount of a transaction, or all that is left of it
+// when Amount is nil.
+type Reverse struct {
+	Amount *float64 `json:"amount,omitempty" example:"10.00"`
+	Reason string   `json:"reason" example:"Duplicate charge"`
+}
+
+// Reversal returns the compensating entry that reverses t as asked.
+// Reversals may be partial, as for refunds, but never add up to more
+// than the original amount. Reversals and transfer legs cannot be
+// reversed.
+func (t Transaction) Reversal(reverse Reverse) (PostTransaction, error) {
+	switch t.Kind {
+	case KindReversal:
+		return PostTransaction{}, fmt.Errorf("%w: a reversal cannot be reversed", ErrInvalidReversal)
+	case KindTransfer:
+		// Reversing one leg alone would refund the sender while the
+		// receiver keeps the money.
+		return PostTransaction{}, fmt.Errorf("%w: a transfer leg cannot be reversed on its own, transfer the money back instead", ErrInvalidReversal)
+	}
+	remaining := Round(math.Abs(t.Amount) - t.Reversed)
+	if remaining <= 0 {
+		return PostTransaction{}, ErrAlreadyReversed
+	}
+	amount := remaining
+	if reverse.Amount != nil {
+		amount = *reverse.Amount
+		if amount <= 0 || Round(amount) != amount {
+			return PostTransaction{}, fmt.Errorf("%w: amount must be positive with at most two decimals", ErrInvalidReversal)
+		}
+		if amount > remaining {
+			return PostTransaction{}, fmt.Errorf("%w: only %.2f of the transaction is left to reverse", ErrInvalidReversal, remaining)
+		}
+	}
+	if t.Amount > 0 {
+		amount = -amount
+	}
+	description := fmt.Sprintf("Reversal of transaction %d", t.ID)
+	if reverse.Reason != "" {
+		description += ": " + reverse.Reason
+	}
+	id := t.ID
+	return PostTransaction{
+		WalletID:    t.WalletID,
+		Amount:      amount,
+		Kind:        KindReversal,
+		Description: description,
+		ReversalOf:  &id,
+	}, nil
+}
+
+// Transfer moves Amount from one wallet to another at once. Reference,
+// when set, makes the transfer idempotent like a transaction's.
+type Transfer struct {
+	FromWalletID int
+	ToWalletID   int
+	Amount       float64
+	Description  string
+	Reference    string
+}
+
+func (t Transfer) Validate() error {
+	switch {
+	case t.FromWalletID == t.ToWalletID:
+		return fmt.Errorf("%w: from and to wallets must differ", ErrInvalidTransfer)
+	case t.Amount <= 0 || Round(t.Amount) != t.Amount:
+		return fmt.Errorf("%w: amount must be positive with at most two decimals", ErrInvalidTransfer)
+	}
+	return nil
+}
+
+// Legs returns the debit of the source wallet and the credit of the
+// destination wallet that make up t.
+func (t Transfer) Legs() (debit, credit PostTransaction) {
+	debit = PostTransaction{WalletID: t.FromWalletID, Amount: -t.Amount, Kind: KindTransfer, Description: t.Description}
+	credit = PostTransaction{WalletID: t.ToWalletID, Amount: t.Amount, Kind: KindTransfer, Description: t.Description}
+	if t.Reference != "" {
+		debit.Reference = t.Reference + ":debit"
+		credit.Reference = t.Reference + ":credit"
+	}
+	return debit, credit
+}
+
+// Round rounds an amount to the two decimals balances are stored with.
+func Round(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,145 @@
+package ledger
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet"
+	"github.com/labstack/echo/v4"
+)
+
+type StubStorer struct {
+	transactions []Transaction
+}
+
+func (s *StubStorer) WalletTransactions(walletID int) ([]Transaction, error) {
+	return s.transactions, nil
+}
+
+func (s *StubStorer) ReverseTransaction(transactionID int, reverse Reverse, actor wallet.Actor) (Transaction, error) {
+	for i, t := range s.transactions {
+		if t.ID != transactionID {
+			continue
+		}
+		post, err := t.Reversal(reverse)
+		if err != nil {
+			return Transaction{}, err
+		}
+		s.transactions[i].Reversed += -post.Amount * sign(t.Amount)
+		result := Transaction{ID: len(s.transactions) + 1, WalletID: post.WalletID, Amount: post.Amount,
+			Kind: post.Kind, Description: post.Description, ReversalOf: post.ReversalOf}
+		s.transactions = append(s.transactions, result)
+		return result, nil
+	}
+	return Transaction{}, ErrNotFound
+}
+
+func sign(f float64) float64 {
+	if f < 0 {
+		return -1
+	}
+	return 1
+}
+
+func TestReversal(t *testing.T) {
+	amount := func(f float64) *float64 { return &f }
+	debit := Transaction{ID: 7, WalletID: 1, Amount: -40, Kind: KindCapture}
+
+	tests := []struct {
+		name    string
+		t       Transaction
+		reverse Reverse
+		want    float64
+		err     error
+	}{
+		{"full debit", debit, Reverse{}, 40, nil},
+		{"partial refund", debit, Reverse{Amount: amount(15)}, 15, nil},
+		{"rest after partial refund", Transaction{ID: 7, Amount: -40, Reversed: 15}, Reverse{}, 25, nil},
+		{"credit", Transaction{ID: 8, Amount: 100, Kind: KindAdjustment}, Reverse{}, -100, nil},
+		{"more than left", Transaction{ID: 7, Amount: -40, Reversed: 30}, Reverse{Amount: amount(10.01)}, 0, ErrInvalidReversal},
+		{"twice", Transaction{ID: 7, Amount: -40, Reversed: 40}, Reverse{}, 0, ErrAlreadyReversed},
+		{"a reversal", Transaction{ID: 9, Amount: 40, Kind: KindReversal}, Reverse{}, 0, ErrInvalidReversal},
+		{"a transfer debit", Transaction{ID: 10, Amount: -40, Kind: KindTransfer}, Reverse{}, 0, ErrInvalidReversal},
+		{"a transfer credit", Transaction{ID: 11, Amount: 40, Kind: KindTransfer}, Reverse{Amount: amount(10)}, 0, ErrInvalidReversal},
+		{"fractional cents", debit, Reverse{Amount: amount(0.001)}, 0, ErrInvalidReversal},
+	}
+	for _, tt := range tests {
+		post, err := tt.t.Reversal(tt.reverse)
+		if !errors.Is(err, tt.err) || post.Amount != tt.want {
+			t.Errorf("%s: expected %v %v but got %v %v", tt.name, tt.want, tt.err, post.Amount, err)
+		}
+		if err == nil && (post.Kind != KindReversal || *post.ReversalOf != tt.t.ID) {
+			t.Errorf("%s: expected a reversal linked to %d but got %+v", tt.name, tt.t.ID, post)
+		}
+	}
+}
+
+func TestTransfer(t *testing.T) {
+	tests := []struct {
+		name     string
+		transfer Transfer
+		err      error
+	}{
+		{"valid", Transfer{FromWalletID: 1, ToWalletID: 2, Amount: 25.5}, nil},
+		{"same wallet", Transfer{FromWalletID: 1, ToWalletID: 1, Amount: 25}, ErrInvalidTransfer},
+		{"zero", Transfer{FromWalletID: 1, ToWalletID: 2}, ErrInvalidTransfer},
+		{"fractional cents", Transfer{FromWalletID: 1, ToWalletID: 2, Amount: 0.001}, ErrInvalidTransfer},
+	}
+	for _, tt := range tests {
+		if err := tt.transfer.Validate(); !errors.Is(err, tt.err) {
+			t.Errorf("%s: expected %v but got %v", tt.name, tt.err, err)
+		}
+	}
+
+	debit, credit := Transfer{FromWalletID: 1, ToWalletID: 2, Amount: 25, Reference: "invoice-9"}.Legs()
+	if debit.WalletID != 1 || debit.Amount != -25 || debit.Reference != "invoice-9:debit" {
+		t.Errorf("unexpected debit %+v", debit)
+	}
+	if credit.WalletID != 2 || credit.Amount != 25 || credit.Reference != "invoice-9:credit" {
+		t.Errorf("unexpected credit %+v", credit)
+	}
+	if debit, _ := (Transfer{FromWalletID: 1, ToWalletID: 2, Amount: 25}).Legs(); debit.Reference != "" {
+		t.Errorf("expected no reference but got %q", debit.Reference)
+	}
+}
+
+func TestReverseTransaction(t *testing.T) {
+	store := &StubStorer{transactions: []Transaction{{ID: 1, WalletID: 1, Amount: -40, Kind: KindCapture}}}
+	h := New(store)
+	reverse := func(id, body string) *httptest.ResponseRecorder {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+		_ = h.ReverseTransaction(c)
+		return rec
+	}
+
+	if rec := reverse("1", `{"amount": 15, "reason": "Partial refund"}`); rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201 but got %d %s", rec.Code, rec.Body)
+	}
+	if rec := reverse("1", `{"amount": 30}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for reversing more than is left but got %d", rec.Code)
+	}
+	if rec := reverse("1", `{}`); rec.Code != http.StatusCreated {
+		t.Errorf("expected status 201 but got %d %s", rec.Code, rec.Body)
+	}
+	if rec := reverse("1", `{}`); rec.Code != http.StatusConflict {
+		t.Errorf("expected status 409 for reversing twice but got %d", rec.Code)
+	}
+	if rec := reverse("2", `{}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for reversing a reversal but got %d", rec.Code)
+	}
+	if rec := reverse("99", `{}`); rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 but got %d", rec.Code)
+	}
+	if len(store.transactions) != 3 {
+		t.Errorf("expected the original and both reversals in history, got %+v", store.transactions)
+	}
+}
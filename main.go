@@ -1,9 +1,40 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/apikey"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/asset"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/cache"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/gql"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/hold"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/interest"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/job"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/ledger"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/memory"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/outbox"
 	"github.com/KKGo-Software-engineering/fun-exercise-api/postgres"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/ratelimit"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/sqlite"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/statement"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/stream"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/transfer"
 	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/walletgrpc"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/webhook"
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/nats-io/nats.go"
+	"github.com/redis/go-redis/v9"
 
 	_ "github.com/KKGo-Software-engineering/fun-exercise-api/docs"
 	echoSwagger "github.com/swaggo/echo-swagger"
@@ -13,19 +44,325 @@ import (
 // @version		1.0
 // @description	Sophisticated Wallet API
 // @host			localhost:1323
+//
+// @securityDefinitions.apikey	ApiKeyAuth
+// @in							header
+// @name						X-API-Key
 func main() {
+	switch os.Getenv("STORE_BACKEND") {
+	case "", "postgres":
+	case "memory":
+		serveStandalone(memory.New())
+		return
+	case "sqlite":
+		path := os.Getenv("SQLITE_PATH")
+		if path == "" {
+			path = "wallets.db"
+		}
+		db, err := sqlite.New(path)
+		if err != nil {
+			panic(err)
+		}
+		serveStandalone(db)
+		return
+	default:
+		panic("unknown store backend " + os.Getenv("STORE_BACKEND"))
+	}
+
 	p, err := postgres.New()
 	if err != nil {
 		panic(err)
 	}
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		if err := export(p, os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, "export:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	var limits ratelimit.Store = ratelimit.NewMemory()
+	if os.Getenv("RATE_LIMIT_BACKEND") == "postgres" {
+		limits = p
+	}
+	cached := walletCache(p)
+	s := newServer(cached, p, limits)
+	e, api, admin, read, write := s.e, s.api, s.admin, s.read, s.write
+
+	broker := stream.NewBroker()
+	streams := stream.New(p, broker)
+	api.GET("/wallets/stream", streams.WalletsStream, read...)
+	api.GET("/users/:id/wallets/stream", streams.UserWalletsStream, read...)
+
+	transactions := ledger.New(p)
+	api.GET("/wallets/:id/transactions", transactions.WalletTransactionsHandler, read...)
+	api.POST("/transactions/:id/reverse", transactions.ReverseTransaction, write...)
+
+	interests := interest.New(p)
+	api.PUT("/wallets/:id/interest-product", interests.AttachProduct, apikey.RequireScope(apikey.ScopeAdmin))
+
+	statements := statement.New(p)
+	api.GET("/wallets/:id/statements", statements.StatementsHandler, read...)
+	api.GET("/wallets/:id/statements/:period", statements.StatementHandler, read...)
+
+	holds := hold.New(p)
+	api.GET("/wallets/:id/holds", holds.WalletHoldsHandler, read...)
+	api.POST("/wallets/:id/holds", holds.PlaceHold, write...)
+	api.POST("/holds/:id/capture", holds.CaptureHold, write...)
+	api.POST("/holds/:id/void", holds.VoidHold, write...)
+
+	transfers := transfer.New(p)
+	api.GET("/wallets/:id/transfers", transfers.WalletTransfersHandler, read...)
+	api.GET("/transfers/:id", transfers.TransferHandler, read...)
+	api.GET("/transfers/:id/runs", transfers.TransferRunsHandler, read...)
+	api.POST("/transfers", transfers.CreateTransfer, write...)
+	api.DELETE("/transfers/:id", transfers.CancelTransfer, write...)
+
+	assets := asset.New(p)
+	api.GET("/assets", assets.AssetsHandler, read...)
+	api.GET("/wallets/:id/holdings", assets.HoldingsHandler, read...)
+	api.GET("/wallets/:id/holdings/:asset", assets.HoldingHandler, read...)
+	api.POST("/wallets/:id/holdings", assets.CreateHolding, write...)
+	api.POST("/wallets/:id/holdings/:asset/adjustments", assets.AdjustHolding, write...)
+
+	// Mutations check for the write scope themselves.
+	graphql := gql.New(p)
+	e.POST("/graphql", graphql.GraphQL, append(s.authenticate, read...)...)
+
+	admin.GET("/cache", cache.New(cached).StatsHandler)
+	admin.GET("/interest-products", interests.ProductsHandler)
+	admin.POST("/interest-products", interests.CreateProduct)
+
+	webhooks := webhook.New(p)
+	admin.GET("/webhooks", webhooks.SubscriptionsHandler)
+	admin.POST("/webhooks", webhooks.CreateSubscription)
+	admin.DELETE("/webhooks/:id", webhooks.DeleteSubscription)
+	admin.GET("/webhooks/deliveries", webhooks.DeliveriesHandler)
+	admin.POST("/webhooks/deliveries/:id/redeliver", webhooks.Redeliver)
+
+	ctx := context.Background()
+	go job.Run(ctx, "purge-deleted-wallets", time.Hour, wallet.PurgeJob(p, duration("SOFT_DELETE_RETENTION", 30*24*time.Hour)))
+	go job.Run(ctx, "accrue-interest", time.Hour, interest.AccrualJob(p))
+	go job.Run(ctx, "expire-holds", time.Minute, hold.ExpiryJob(p))
+	go job.Run(ctx, "scheduled-transfers", time.Minute, transfer.SchedulerJob(p))
+	go job.Run(ctx, "card-statements", time.Hour, statement.Job(p))
+	go job.Run(ctx, "deliver-webhooks", 5*time.Second, webhook.DeliveryJob(p))
+	go job.Run(ctx, "relay-outbox", time.Second, outbox.RelayJob(p, publisher(p)))
+	go job.Run(ctx, "purge-outbox", time.Hour, outbox.PurgeJob(p, duration("OUTBOX_RETENTION", 7*24*time.Hour)))
+	if sweeper, ok := limits.(ratelimit.Sweeper); ok {
+		go job.Run(ctx, "sweep-rate-limits", time.Minute, ratelimit.SweepJob(sweeper))
+	}
+	go func() {
+		e.Logger.Fatal(p.ListenWalletEvents(ctx, func(message outbox.Message) {
+			broker.Publish(message)
+			cached.InvalidateEvent(message)
+		}))
+	}()
+
+	grpcAddr := os.Getenv("GRPC_ADDR")
+	if grpcAddr == "" {
+		grpcAddr = ":50051"
+	}
+	lis, err := net.Listen("tcp", grpcAddr)
+	if err != nil {
+		panic(err)
+	}
+	grpcServer := walletgrpc.NewServer(p, p, os.Getenv("ADMIN_API_KEY"))
+	go func() {
+		e.Logger.Fatal(grpcServer.Serve(lis))
+	}()
+
+	e.Logger.Fatal(e.Start(":1323"))
+}
+
+// server holds the routes that every store backend serves, and what the
+// others are registered with.
+type server struct {
+	e                         *echo.Echo
+	api, admin                *echo.Group
+	authenticate, read, write []echo.MiddlewareFunc
+}
 
+// newServer registers the routes of the wallets, their audit and the API
+// keys.
+func newServer(wallets wallet.Storer, keys apikey.Storer, limits ratelimit.Store) *server {
 	e := echo.New()
+	e.Use(middleware.RequestID())
 	e.GET("/swagger/*", echoSwagger.WrapHandler)
-	handler := wallet.New(p)
-	e.GET("/api/v1/wallets", handler.WalletHandler)
-	e.GET("/api/v1/users/:id/wallets", handler.WalletHandlerByUser)
-	e.POST("/api/v1/wallets", handler.CreateWallet)
-	e.DELETE("/api/v1/users/:id/wallets", handler.DeleteWallet)
-	e.PATCH("/api/v1/wallets", handler.UpdateWallet)
-	e.Logger.Fatal(e.Start(":1323"))
+
+	s := &server{e: e}
+	s.authenticate = []echo.MiddlewareFunc{
+		rateLimit(limits, "ip", "RATE_LIMIT_IP", "1200/m", ratelimit.IPKey),
+		apikey.Middleware(keys, os.Getenv("ADMIN_API_KEY")),
+	}
+	s.api = e.Group("/api/v1", s.authenticate...)
+	s.read = []echo.MiddlewareFunc{
+		apikey.RequireScope(apikey.ScopeWalletsRead),
+		rateLimit(limits, "read", "RATE_LIMIT_READ", "600/m", nil),
+	}
+	s.write = []echo.MiddlewareFunc{
+		apikey.RequireScope(apikey.ScopeWalletsWrite),
+		rateLimit(limits, "write", "RATE_LIMIT_WRITE", "120/m", nil),
+	}
+	api, read, write := s.api, s.read, s.write
+
+	handler := wallet.New(wallets)
+	api.GET("/wallets", handler.WalletHandler, read...)
+	api.GET("/users/:id/wallets", handler.WalletHandlerByUser, read...)
+	api.POST("/wallets", handler.CreateWallet, write...)
+	api.GET("/wallets\\:export", handler.ExportWallets, read...)
+	api.POST("/wallets\\:import", handler.ImportWallets, append(write, middleware.BodyLimit("10M"))...)
+	api.POST("/wallets\\:batch", handler.BatchWallets, write...)
+	api.DELETE("/users/:id/wallets", handler.DeleteWallet, write...)
+	api.PATCH("/wallets", handler.UpdateWallet, write...)
+	api.GET("/wallets/:id/audit", handler.WalletAuditHandler, read...)
+	api.POST("/wallets/:id/restore", handler.RestoreWallet, apikey.RequireScope(apikey.ScopeAdmin))
+	api.POST("/wallets/:id/status", handler.ChangeWalletStatus, apikey.RequireScope(apikey.ScopeAdmin))
+
+	s.admin = api.Group("/admin",
+		apikey.RequireScope(apikey.ScopeAdmin),
+		rateLimit(limits, "admin", "RATE_LIMIT_ADMIN", "60/m", nil))
+	s.admin.GET("/audit", handler.AuditHandler)
+
+	apiKeys := apikey.New(keys)
+	s.admin.GET("/api-keys", apiKeys.APIKeysHandler)
+	s.admin.POST("/api-keys", apiKeys.CreateAPIKey)
+	s.admin.POST("/api-keys/:id/rotate", apiKeys.RotateAPIKey)
+	s.admin.DELETE("/api-keys/:id", apiKeys.RevokeAPIKey)
+	return s
+}
+
+// standaloneStore is what the store backends other than Postgres
+// implement.
+type standaloneStore interface {
+	wallet.Storer
+	wallet.Purger
+	apikey.Storer
+}
+
+// serveStandalone serves the routes of newServer from store alone, so
+// that the API runs without Postgres, e.g. for local development and
+// demos.
+func serveStandalone(store standaloneStore) {
+	s := newServer(store, store, ratelimit.NewMemory())
+	go job.Run(context.Background(), "purge-deleted-wallets", time.Hour,
+		wallet.PurgeJob(store, duration("SOFT_DELETE_RETENTION", 30*24*time.Hour)))
+	s.e.Logger.Fatal(s.e.Start(":1323"))
+}
+
+// export implements the export command, which writes wallets to a file
+// or stdout like GET /api/v1/wallets:export, e.g. for nightly dumps:
+//
+//	fun-exercise-api export -format parquet -o wallets.parquet
+func export(p *postgres.Postgres, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flags.String("format", wallet.ExportCSV, "csv, ndjson or parquet")
+	walletType := flags.String("wallet-type", "", "only export wallets of this type")
+	includeDeleted := flags.Bool("include-deleted", false, "also export soft-deleted wallets")
+	output := flags.String("o", "-", "output file, - for stdout")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *output == "-" {
+		return exportTo(p, os.Stdout, *format, *walletType, *includeDeleted)
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := exportTo(p, f, *format, *walletType, *includeDeleted); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func exportTo(p *postgres.Postgres, out io.Writer, format, walletType string, includeDeleted bool) error {
+	enc, _, err := wallet.NewEncoder(format, out)
+	if err != nil {
+		return err
+	}
+	return wallet.Export(p, wallet.Filter{WalletType: walletType, IncludeDeleted: includeDeleted}, enc)
+}
+
+// publisher builds the outbox publisher from the comma-separated
+// OUTBOX_PUBLISHERS: webhook (the default), log and nats.
+func publisher(p *postgres.Postgres) outbox.Publisher {
+	names := os.Getenv("OUTBOX_PUBLISHERS")
+	if names == "" {
+		names = "webhook"
+	}
+	var publishers []outbox.Publisher
+	for _, name := range strings.Split(names, ",") {
+		switch strings.TrimSpace(name) {
+		case "webhook":
+			publishers = append(publishers, webhook.Publisher(p))
+		case "log":
+			publishers = append(publishers, outbox.Log(log.Default()))
+		case "nats":
+			conn, err := nats.Connect(os.Getenv("NATS_URL"), nats.MaxReconnects(-1))
+			if err != nil {
+				panic(err)
+			}
+			publishers = append(publishers, outbox.NATS(conn, os.Getenv("NATS_SUBJECT_PREFIX")))
+		default:
+			panic("unknown outbox publisher " + name)
+		}
+	}
+	return outbox.Multi(publishers...)
+}
+
+// walletCache puts the cache picked by CACHE_BACKEND in front of the
+// wallet reads: memory (the default) or redis, at REDIS_URL.
+func walletCache(p *postgres.Postgres) *cache.Store {
+	var backend cache.Backend
+	switch os.Getenv("CACHE_BACKEND") {
+	case "", "memory":
+		size := 10000
+		if value := os.Getenv("CACHE_SIZE"); value != "" {
+			var err error
+			if size, err = strconv.Atoi(value); err != nil {
+				panic(err)
+			}
+		}
+		backend = cache.NewMemory(size)
+	case "redis":
+		opts, err := redis.ParseURL(os.Getenv("REDIS_URL"))
+		if err != nil {
+			panic(err)
+		}
+		backend = cache.NewRedis(redis.NewClient(opts), "wallet-api:")
+	default:
+		panic("unknown cache backend " + os.Getenv("CACHE_BACKEND"))
+	}
+	return cache.NewStore(p, backend, duration("CACHE_TTL", 30*time.Second))
+}
+
+// duration reads a time.Duration such as "720h" from the env variable,
+// or returns fallback when it is unset.
+func duration(env string, fallback time.Duration) time.Duration {
+	value := os.Getenv(env)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// rateLimit builds a rate limiting middleware for a route group from the
+// limit in the env variable, e.g. "100/m", or fallback when it is unset.
+func rateLimit(store ratelimit.Store, group, env, fallback string, keyFunc func(echo.Context) string) echo.MiddlewareFunc {
+	value := os.Getenv(env)
+	if value == "" {
+		value = fallback
+	}
+	limit, err := ratelimit.ParseLimit(value)
+	if err != nil {
+		panic(err)
+	}
+	return ratelimit.Middleware(ratelimit.Config{Store: store, Limit: limit, Group: group, KeyFunc: keyFunc})
 }
@@ -0,0 +1,120 @@
+package memory
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/apikey"
+)
+
+// errDuplicateHash mirrors the UNIQUE constraint on api_key.key_hash.
+var errDuplicateHash = errors.New("duplicate api key hash")
+
+// readKey returns a copy of a stored key that the caller may change.
+func readKey(k storedKey) apikey.APIKey {
+	key := k.APIKey
+	key.Scopes = append([]string{}, k.Scopes...)
+	for _, t := range []**time.Time{&key.ExpiresAt, &key.LastUsedAt, &key.RevokedAt} {
+		if *t != nil {
+			v := **t
+			*t = &v
+		}
+	}
+	return key
+}
+
+func (m *Memory) APIKeys() ([]apikey.APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := make([]apikey.APIKey, 0, len(m.keys))
+	for _, k := range m.keys {
+		keys = append(keys, readKey(k))
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys, nil
+}
+
+func (m *Memory) APIKeyByHash(hash string) (apikey.APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, k := range m.keys {
+		if k.hash == hash {
+			return readKey(k), nil
+		}
+	}
+	return apikey.APIKey{}, apikey.ErrNotFound
+}
+
+// hashTaken reports whether another key has the hash. The caller holds a
+// lock.
+func (m *Memory) hashTaken(hash string, id int) bool {
+	for _, k := range m.keys {
+		if k.hash == hash && k.ID != id {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Memory) CreateAPIKey(createAPIKey apikey.CreateAPIKey, secret apikey.Secret) (apikey.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.hashTaken(secret.Hash, 0) {
+		return apikey.APIKey{}, errDuplicateHash
+	}
+	m.lastKeyID++
+	k := storedKey{
+		APIKey: apikey.APIKey{
+			ID:        m.lastKeyID,
+			Name:      createAPIKey.Name,
+			Prefix:    secret.Prefix,
+			Scopes:    append([]string{}, createAPIKey.Scopes...),
+			ExpiresAt: createAPIKey.ExpiresAt,
+			CreatedAt: m.timestamp(),
+		},
+		hash: secret.Hash,
+	}
+	k.APIKey = readKey(k)
+	m.keys[k.ID] = k
+	return readKey(k), nil
+}
+
+// RotateAPIKey replaces the secret of a key that is not revoked.
+func (m *Memory) RotateAPIKey(id int, secret apikey.Secret) (apikey.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k, ok := m.keys[id]
+	if !ok || k.RevokedAt != nil {
+		return apikey.APIKey{}, apikey.ErrNotFound
+	}
+	if m.hashTaken(secret.Hash, id) {
+		return apikey.APIKey{}, errDuplicateHash
+	}
+	k.Prefix, k.hash, k.LastUsedAt = secret.Prefix, secret.Hash, nil
+	m.keys[id] = k
+	return readKey(k), nil
+}
+
+func (m *Memory) RevokeAPIKey(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k, ok := m.keys[id]
+	if !ok || k.RevokedAt != nil {
+		return apikey.ErrNotFound
+	}
+	now := m.timestamp()
+	k.RevokedAt = &now
+	m.keys[id] = k
+	return nil
+}
+
+func (m *Memory) TouchAPIKey(id int, usedAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if k, ok := m.keys[id]; ok {
+		k.LastUsedAt = &usedAt
+		m.keys[id] = k
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+// Package memory keeps wallets and API keys in memory, with the same
+// semantics as the postgres package, so that the API can run without a
+// database for local development and tests.
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/apikey"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/ledger"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet"
+)
+
+// Memory is safe for concurrent use. Like the transactions of Postgres,
+// each method sees and leaves the store in a consistent state, and
+// changes either fully apply or not at all.
+type Memory struct {
+	mu      sync.RWMutex
+	wallets map[int]wallet.Wallet
+	audit   []wallet.AuditEntry
+	keys    map[int]storedKey
+	// The last ids handed out, like the sequences of SERIAL columns.
+	lastWalletID, lastAuditID, lastKeyID int
+	now                                  func() time.Time
+}
+
+type storedKey struct {
+	apikey.APIKey
+	hash string
+}
+
+func New() *Memory {
+	return &Memory{
+		wallets: map[int]wallet.Wallet{},
+		keys:    map[int]storedKey{},
+		now:     time.Now,
+	}
+}
+
+// WithTx runs fn on a copy of the store, holding the write lock
+// throughout, and keeps the copy if fn returns nil. Like an IMMEDIATE
+// transaction of SQLite, it never conflicts with another, but the store
+// waits for it.
+func (m *Memory) WithTx(ctx context.Context, fn func(tx wallet.Storer) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	tx := m.clone()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	m.wallets, m.audit, m.keys = tx.wallets, tx.audit, tx.keys
+	m.lastWalletID, m.lastAuditID, m.lastKeyID = tx.lastWalletID, tx.lastAuditID, tx.lastKeyID
+	return nil
+}
+
+// clone copies the store. The caller holds a lock. Stored values are
+// replaced, never changed in place, so copying the maps and the audit
+// is enough.
+func (m *Memory) clone() *Memory {
+	c := &Memory{
+		wallets:      make(map[int]wallet.Wallet, len(m.wallets)),
+		audit:        append([]wallet.AuditEntry(nil), m.audit...),
+		keys:         make(map[int]storedKey, len(m.keys)),
+		lastWalletID: m.lastWalletID,
+		lastAuditID:  m.lastAuditID,
+		lastKeyID:    m.lastKeyID,
+		now:          m.now,
+	}
+	for id, w := range m.wallets {
+		c.wallets[id] = w
+	}
+	for id, k := range m.keys {
+		c.keys[id] = k
+	}
+	return c
+}
+
+// timestamp returns the current time at the precision of Postgres.
+func (m *Memory) timestamp() time.Time {
+	return m.now().Truncate(time.Microsecond)
+}
+
+// check enforces the constraints of the user_wallet table and rounds
+// amounts to its DECIMAL(10, 2) columns.
+func check(w *wallet.Wallet) error {
+	switch w.WalletType {
+	case wallet.TypeSavings, wallet.TypeCreditCard, wallet.TypeCrypto:
+	default:
+		return fmt.Errorf("%w: invalid wallet_type %q", wallet.ErrInvalidWallet, w.WalletType)
+	}
+	if w.CreditLimit != nil {
+		if *w.CreditLimit < 0 {
+			return fmt.Errorf("%w: credit_limit must not be negative", wallet.ErrInvalidWallet)
+		}
+		if w.WalletType != wallet.TypeCreditCard {
+			return fmt.Errorf("%w: credit_limit is only allowed for %s wallets", wallet.ErrInvalidWallet, wallet.TypeCreditCard)
+		}
+		limit := ledger.Round(*w.CreditLimit)
+		w.CreditLimit = &limit
+	}
+	w.Balance = ledger.Round(w.Balance)
+	return nil
+}
+
+// read returns a copy of a stored wallet, with its derived fields, that
+// the caller may change.
+func read(w wallet.Wallet) wallet.Wallet {
+	if w.CreditLimit != nil {
+		limit := *w.CreditLimit
+		w.CreditLimit = &limit
+	}
+	if w.DeletedAt != nil {
+		deletedAt := *w.DeletedAt
+		w.DeletedAt = &deletedAt
+	}
+	w.Derive()
+	return w
+}
+
+// stored strips the derived fields from w before it is stored.
+func stored(w wallet.Wallet) wallet.Wallet {
+	w.AvailableBalance, w.AvailableCredit, w.OutstandingBalance = 0, nil, nil
+	return w
+}
+
+// addAudit records a wallet mutation, as postgres.insertAudit does. The
+// caller holds the write lock.
+func (m *Memory) addAudit(actor wallet.Actor, action string, walletID int, before, after *wallet.Wallet, reason string) {
+	m.lastAuditID++
+	m.audit = append(m.audit, wallet.AuditEntry{
+		ID:        m.lastAuditID,
+		Actor:     actor.Name,
+		Action:    action,
+		WalletID:  walletID,
+		Before:    auditJSON(before),
+		After:     auditJSON(after),
+		Reason:    reason,
+		RequestID: actor.RequestID,
+		CreatedAt: m.timestamp(),
+	})
+}
+
+func auditJSON(w *wallet.Wallet) json.RawMessage {
+	if w == nil {
+		return nil
+	}
+	b, _ := json.Marshal(w)
+	return b
+}
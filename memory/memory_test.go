@@ -0,0 +1,95 @@
+package memory
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/apikey"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet/storertest"
+)
+
+var actor = wallet.Actor{Name: "admin", RequestID: "req-1"}
+
+func TestConformance(t *testing.T) {
+	storertest.Run(t, func(t *testing.T) wallet.Storer { return New() })
+}
+
+func TestUpdateWallet(t *testing.T) {
+	m := New()
+	now := time.Date(2024, 4, 12, 10, 45, 16, 0, time.UTC)
+	m.now = func() time.Time { return now }
+	m.CreateWallet(wallet.CreateWallet{UserID: 1, WalletType: wallet.TypeSavings, Balance: 100}, actor)
+
+	now = now.Add(time.Hour)
+	w, err := m.UpdateWallet(wallet.UpdateWallet{ID: 1, UserID: 2, WalletName: "Moved", WalletType: wallet.TypeSavings, Balance: 150}, actor)
+	if err != nil {
+		t.Fatalf("unable to update wallet: %v", err)
+	}
+	if w.UserID != 2 || w.Balance != 150 || !w.CreatedAt.Equal(now) {
+		t.Errorf("expected updated wallet but got %+v", w)
+	}
+	if w, _ := m.WalletByUser(2); w.ID != 1 {
+		t.Errorf("expected wallet to move to user 2 but got %+v", w)
+	}
+}
+
+func TestPurgeDeletedWallets(t *testing.T) {
+	m := New()
+	now := time.Now()
+	m.now = func() time.Time { return now }
+	m.CreateWallet(wallet.CreateWallet{UserID: 1, WalletType: wallet.TypeSavings}, actor)
+	m.CreateWallet(wallet.CreateWallet{UserID: 2, WalletType: wallet.TypeSavings}, actor)
+	m.DeleteWallet(1, actor)
+	now = now.Add(time.Hour)
+	m.DeleteWallet(2, actor)
+
+	n, err := m.PurgeDeletedWallets(now.Add(-time.Minute), actor)
+	if err != nil || n != 1 {
+		t.Errorf("expected 1 wallet to be purged but got %d, %v", n, err)
+	}
+	if wallets, _ := m.Wallets(wallet.Filter{IncludeDeleted: true}); len(wallets) != 1 || wallets[0].ID != 2 {
+		t.Errorf("expected only wallet 2 to be kept but got %+v", wallets)
+	}
+	if entries, _ := m.WalletAudit(1); entries[len(entries)-1].Action != wallet.ActionPurge {
+		t.Errorf("expected the purge to be audited but got %+v", entries)
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	m := New()
+	k, err := m.CreateAPIKey(apikey.CreateAPIKey{Name: "batch", Scopes: []string{"wallets:read"}},
+		apikey.Secret{Prefix: "wk_1", Hash: "hash-1"})
+	if err != nil || k.ID != 1 {
+		t.Fatalf("unable to create api key: %+v, %v", k, err)
+	}
+	if _, err := m.CreateAPIKey(apikey.CreateAPIKey{Name: "copy"}, apikey.Secret{Hash: "hash-1"}); err == nil {
+		t.Errorf("expected a duplicate hash to be rejected")
+	}
+
+	m.TouchAPIKey(k.ID, time.Now())
+	rotated, err := m.RotateAPIKey(k.ID, apikey.Secret{Prefix: "wk_2", Hash: "hash-2"})
+	if err != nil || rotated.Prefix != "wk_2" || rotated.LastUsedAt != nil {
+		t.Errorf("expected key to be rotated but got %+v, %v", rotated, err)
+	}
+	if _, err := m.APIKeyByHash("hash-1"); !errors.Is(err, apikey.ErrNotFound) {
+		t.Errorf("expected the old hash to be forgotten but got %v", err)
+	}
+	if got, err := m.APIKeyByHash("hash-2"); err != nil || got.Name != "batch" {
+		t.Errorf("expected key by new hash but got %+v, %v", got, err)
+	}
+
+	if err := m.RevokeAPIKey(k.ID); err != nil {
+		t.Errorf("unable to revoke key: %v", err)
+	}
+	if err := m.RevokeAPIKey(k.ID); !errors.Is(err, apikey.ErrNotFound) {
+		t.Errorf("expected a revoked key not to be revoked again but got %v", err)
+	}
+	if _, err := m.RotateAPIKey(k.ID, apikey.Secret{Hash: "hash-3"}); !errors.Is(err, apikey.ErrNotFound) {
+		t.Errorf("expected a revoked key not to be rotated but got %v", err)
+	}
+	if keys, _ := m.APIKeys(); len(keys) != 1 || keys[0].RevokedAt == nil {
+		t.Errorf("expected the revoked key to be listed but got %+v", keys)
+	}
+}
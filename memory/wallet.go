@@ -0,0 +1,262 @@
+package memory
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet"
+)
+
+// matching returns copies of the wallets matching filter, ordered by id.
+// The caller holds a lock.
+func (m *Memory) matching(filter wallet.Filter) []wallet.Wallet {
+	wallets := []wallet.Wallet{}
+	for _, w := range m.wallets {
+		if (filter.IncludeDeleted || w.DeletedAt == nil) &&
+			(filter.WalletType == "" || w.WalletType == filter.WalletType) &&
+			w.ID > filter.AfterID {
+			wallets = append(wallets, read(w))
+		}
+	}
+	sort.Slice(wallets, func(i, j int) bool { return wallets[i].ID < wallets[j].ID })
+	if filter.Limit != 0 && len(wallets) > filter.Limit {
+		wallets = wallets[:filter.Limit]
+	}
+	return wallets
+}
+
+func (m *Memory) Wallets(filter wallet.Filter) ([]wallet.Wallet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.matching(filter), nil
+}
+
+// EachWallet calls fn with a snapshot of the wallets matching filter, in
+// id order, so that fn may use the store. It stops at the first error fn
+// returns.
+func (m *Memory) EachWallet(filter wallet.Filter, fn func(wallet.Wallet) error) error {
+	m.mu.RLock()
+	wallets := m.matching(filter)
+	m.mu.RUnlock()
+	for _, w := range wallets {
+		if err := fn(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WalletByUser returns the newest wallet of the user that is not
+// deleted, or wallet.ErrNotFound.
+func (m *Memory) WalletByUser(userID int) (wallet.Wallet, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var result wallet.Wallet
+	for _, w := range m.wallets {
+		if w.UserID == userID && w.DeletedAt == nil && w.ID > result.ID {
+			result = w
+		}
+	}
+	if result.ID == 0 {
+		return result, wallet.ErrNotFound
+	}
+	return read(result), nil
+}
+
+// create stores a new wallet. The caller holds the write lock.
+func (m *Memory) create(createWallet wallet.CreateWallet) (wallet.Wallet, error) {
+	w := wallet.Wallet{
+		UserID:      createWallet.UserID,
+		UserName:    createWallet.UserName,
+		WalletName:  createWallet.WalletName,
+		WalletType:  createWallet.WalletType,
+		Balance:     createWallet.Balance,
+		CreditLimit: createWallet.CreditLimit,
+		Status:      wallet.StatusActive,
+		CreatedAt:   m.timestamp(),
+	}
+	if err := check(&w); err != nil {
+		return wallet.Wallet{}, err
+	}
+	m.lastWalletID++
+	w.ID = m.lastWalletID
+	m.wallets[w.ID] = w
+	return read(w), nil
+}
+
+func (m *Memory) CreateWallet(createWallet wallet.CreateWallet, actor wallet.Actor) (wallet.Wallet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result, err := m.create(createWallet)
+	if err != nil {
+		return result, err
+	}
+	m.addAudit(actor, wallet.ActionCreate, result.ID, nil, &result, "")
+	return result, nil
+}
+
+func (m *Memory) ImportWallets(createWallets []wallet.CreateWallet, actor wallet.Actor) ([]wallet.Wallet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// Checking every wallet first keeps a failed import from creating
+	// any, like the rolled back transaction of Postgres.
+	for i, c := range createWallets {
+		w := wallet.Wallet{WalletType: c.WalletType, CreditLimit: c.CreditLimit}
+		if err := check(&w); err != nil {
+			return nil, fmt.Errorf("wallet %d: %w", i+1, err)
+		}
+	}
+	created := make([]wallet.Wallet, 0, len(createWallets))
+	for _, c := range createWallets {
+		w, _ := m.create(c)
+		m.addAudit(actor, wallet.ActionCreate, w.ID, nil, &w, "import")
+		created = append(created, w)
+	}
+	return created, nil
+}
+
+// DeleteWallet soft-deletes the wallets of a user. They stay hidden
+// from reads until restored or purged.
+func (m *Memory) DeleteWallet(userID int, actor wallet.Actor) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.timestamp()
+	for _, id := range m.sortedIDs() {
+		w := m.wallets[id]
+		if w.UserID != userID || w.DeletedAt != nil {
+			continue
+		}
+		before := read(w)
+		w.DeletedAt = &now
+		m.wallets[id] = w
+		after := read(w)
+		m.addAudit(actor, wallet.ActionDelete, id, &before, &after, "")
+	}
+	return nil
+}
+
+// sortedIDs returns the ids of all wallets in order, so that changes to
+// many wallets are audited in the order Postgres would.
+func (m *Memory) sortedIDs() []int {
+	ids := make([]int, 0, len(m.wallets))
+	for id := range m.wallets {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// live returns the wallet with the id unless it is deleted. The caller
+// holds a lock.
+func (m *Memory) live(walletID int) (wallet.Wallet, error) {
+	w, ok := m.wallets[walletID]
+	if !ok || w.DeletedAt != nil {
+		return wallet.Wallet{}, wallet.ErrNotFound
+	}
+	return read(w), nil
+}
+
+func (m *Memory) UpdateWallet(updateWallet wallet.UpdateWallet, actor wallet.Actor) (wallet.Wallet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	before, err := m.live(updateWallet.ID)
+	if err != nil {
+		return wallet.Wallet{}, fmt.Errorf("unable to update row: %w", err)
+	}
+	after, err := wallet.ApplyUpdate(before, updateWallet)
+	if err != nil {
+		return wallet.Wallet{}, err
+	}
+	if err := check(&after); err != nil {
+		return wallet.Wallet{}, err
+	}
+	// Postgres sets created_at on update too.
+	after.CreatedAt = m.timestamp()
+	m.wallets[after.ID] = stored(after)
+	result := read(after)
+	m.addAudit(actor, wallet.ActionUpdate, result.ID, &before, &result, "")
+	return result, nil
+}
+
+func (m *Memory) RestoreWallet(walletID int, actor wallet.Actor) (wallet.Wallet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w, ok := m.wallets[walletID]
+	if !ok || w.DeletedAt == nil {
+		return wallet.Wallet{}, wallet.ErrNotFound
+	}
+	w.DeletedAt = nil
+	m.wallets[walletID] = w
+	result := read(w)
+	m.addAudit(actor, wallet.ActionRestore, walletID, nil, &result, "")
+	return result, nil
+}
+
+// PurgeDeletedWallets hard-deletes wallets soft-deleted before the
+// cut-off and returns how many were removed.
+func (m *Memory) PurgeDeletedWallets(before time.Time, actor wallet.Actor) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	purged := 0
+	for _, id := range m.sortedIDs() {
+		w := m.wallets[id]
+		if w.DeletedAt == nil || !w.DeletedAt.Before(before) {
+			continue
+		}
+		delete(m.wallets, id)
+		gone := read(w)
+		m.addAudit(actor, wallet.ActionPurge, id, &gone, nil, "")
+		purged++
+	}
+	return purged, nil
+}
+
+func (m *Memory) ChangeWalletStatus(walletID int, changeStatus wallet.ChangeStatus, actor wallet.Actor) (wallet.Wallet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	before, err := m.live(walletID)
+	if err != nil {
+		return wallet.Wallet{}, err
+	}
+	if err := wallet.Transition(before, changeStatus.Status); err != nil {
+		return wallet.Wallet{}, err
+	}
+	w := m.wallets[walletID]
+	w.Status = changeStatus.Status
+	m.wallets[walletID] = w
+	result := read(w)
+	m.addAudit(actor, wallet.ActionStatus, walletID, &before, &result, changeStatus.Reason)
+	return result, nil
+}
+
+func (m *Memory) WalletAudit(walletID int) ([]wallet.AuditEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	entries := []wallet.AuditEntry{}
+	for _, e := range m.audit {
+		if e.WalletID == walletID {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+// AuditEntries returns the entries matching filter, newest first, up to
+// filter.Limit.
+func (m *Memory) AuditEntries(filter wallet.AuditFilter) ([]wallet.AuditEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	entries := []wallet.AuditEntry{}
+	for i := len(m.audit) - 1; i >= 0 && len(entries) < filter.Limit; i-- {
+		e := m.audit[i]
+		if (filter.Actor == "" || e.Actor == filter.Actor) &&
+			(filter.Action == "" || e.Action == filter.Action) &&
+			(filter.WalletID == 0 || e.WalletID == filter.WalletID) &&
+			(filter.From == nil || !e.CreatedAt.Before(*filter.From)) &&
+			(filter.To == nil || e.CreatedAt.Before(*filter.To)) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
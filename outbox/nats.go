@@ -0,0 +1,29 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// flushTimeout bounds how long a NATS server can take to read a message.
+const flushTimeout = 5 * time.Second
+
+// NATS publishes messages to NATS, or any server speaking its protocol,
+// on the subject prefix followed by the event type, such as
+// "events.wallet.created". The event ID is sent as the Nats-Msg-Id
+// header, so JetStream streams drop the repeats.
+func NATS(conn *nats.Conn, prefix string) Publisher {
+	return PublisherFunc(func(ctx context.Context, message Message) error {
+		msg := nats.NewMsg(prefix + message.Type)
+		msg.Header.Set(nats.MsgIdHdr, message.EventID)
+		msg.Data = message.Payload
+		if err := conn.PublishMsg(msg); err != nil {
+			return err
+		}
+		// Core NATS has no acknowledgements; a flush at least waits until
+		// the server has read the message.
+		return conn.FlushTimeout(flushTimeout)
+	})
+}
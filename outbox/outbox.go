@@ -0,0 +1,161 @@
+// Package outbox relays domain events that were written to the outbox
+// table in the transaction of the change they describe, so that an event
+// is published if and only if its change committed.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// relayBatch is how many messages a relay run publishes at once.
+const relayBatch = 100
+
+// MaxAttempts is how often a message is tried before it is
+// dead-lettered, so that it no longer holds back its wallet's events.
+const MaxAttempts = 10
+
+// Message is an event waiting in the outbox, or sent from it.
+type Message struct {
+	ID int64 `json:"id"`
+	// EventID identifies the event to consumers, which may see it more
+	// than once and should ignore repeats.
+	EventID string `json:"event_id"`
+	// WalletID is the wallet the event is about; the messages of a wallet
+	// are published in the order they were written.
+	WalletID  int             `json:"wallet_id"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+	Attempts  int             `json:"attempts"`
+	LastError string          `json:"last_error,omitempty"`
+	SentAt    *time.Time      `json:"sent_at,omitempty"`
+	// DeadAt is when the relay gave up on the message after MaxAttempts.
+	DeadAt *time.Time `json:"dead_at,omitempty"`
+}
+
+// Publisher sends a message on to where events are consumed. It returns
+// once the message is accepted there, and may be called again for a
+// message it already accepted.
+type Publisher interface {
+	Publish(ctx context.Context, message Message) error
+}
+
+// PublisherFunc adapts a function to a Publisher.
+type PublisherFunc func(ctx context.Context, message Message) error
+
+func (f PublisherFunc) Publish(ctx context.Context, message Message) error {
+	return f(ctx, message)
+}
+
+// Multi publishes to every publisher in turn. A message that fails on
+// one is published to all of them again on the next attempt.
+func Multi(publishers ...Publisher) Publisher {
+	return PublisherFunc(func(ctx context.Context, message Message) error {
+		for _, p := range publishers {
+			if err := p.Publish(ctx, message); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Log publishes by logging messages, for development.
+func Log(logger *log.Logger) Publisher {
+	return PublisherFunc(func(ctx context.Context, message Message) error {
+		logger.Printf("event %s %s wallet %d: %s", message.EventID, message.Type, message.WalletID, message.Payload)
+		return nil
+	})
+}
+
+// Store holds the outbox.
+type Store interface {
+	// RelayOutbox locks the outbox so that one relay runs at a time, calls
+	// relay with up to limit unsent messages, oldest first, and saves the
+	// messages it returns, all in one transaction. Dead-lettered messages
+	// and those queued behind a failed message of their wallet are left
+	// out, so that a stuck wallet cannot fill the batch. It returns how
+	// many messages were passed to relay.
+	RelayOutbox(limit int, relay func(messages []Message) []Message) (int, error)
+	// PurgeOutbox deletes messages sent before the cut-off.
+	PurgeOutbox(before time.Time) (int, error)
+}
+
+// Relay publishes messages in order and returns those it attempted, each
+// marked sent or with the failure recorded. Once a message of a wallet
+// fails, the later messages of that wallet are left for the next run, so
+// that consumers never see a wallet's events out of order. A message that
+// fails for the MaxAttempts time is dead-lettered instead, and no longer
+// holds them back.
+func Relay(ctx context.Context, publisher Publisher, messages []Message, now func() time.Time) []Message {
+	blocked := map[int]bool{}
+	attempted := make([]Message, 0, len(messages))
+	for _, m := range messages {
+		if blocked[m.WalletID] {
+			continue
+		}
+		m.Attempts++
+		if err := publisher.Publish(ctx, m); err != nil {
+			log.Printf("outbox event %s attempt %d failed: %v", m.EventID, m.Attempts, err)
+			m.LastError = err.Error()
+			if m.Attempts >= MaxAttempts {
+				deadAt := now()
+				m.DeadAt = &deadAt
+				log.Printf("outbox event %s dead-lettered after %d attempts", m.EventID, m.Attempts)
+			} else {
+				blocked[m.WalletID] = true
+			}
+		} else {
+			sentAt := now()
+			m.LastError, m.SentAt = "", &sentAt
+		}
+		attempted = append(attempted, m)
+	}
+	return attempted
+}
+
+// RelayJob returns a job that publishes every unsent message.
+func RelayJob(store Store, publisher Publisher) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		for ctx.Err() == nil {
+			done, released := 0, false
+			n, err := store.RelayOutbox(relayBatch, func(messages []Message) []Message {
+				attempted := Relay(ctx, publisher, messages, time.Now)
+				for _, m := range attempted {
+					if m.SentAt == nil && m.DeadAt == nil {
+						continue
+					}
+					done++
+					// The messages queued behind one that had failed were
+					// left out of the batch.
+					released = released || m.Attempts > 1
+				}
+				return attempted
+			})
+			// A batch that was not sent in full is retried on the next
+			// tick rather than at once.
+			if err != nil || done < n || (n < relayBatch && !released) {
+				return err
+			}
+		}
+		return ctx.Err()
+	}
+}
+
+// PurgeJob returns a job that deletes messages sent more than retention
+// ago.
+func PurgeJob(store Store, retention time.Duration) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		n, err := store.PurgeOutbox(time.Now().Add(-retention))
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			log.Printf("purged %d outbox messages sent more than %v ago", n, retention)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,265 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+type StubStore struct {
+	unsent []Message
+}
+
+func (s *StubStore) RelayOutbox(limit int, relay func(messages []Message) []Message) (int, error) {
+	var batch []Message
+	failed := map[int]bool{}
+	for _, m := range s.unsent {
+		if len(batch) < limit && !failed[m.WalletID] {
+			batch = append(batch, m)
+		}
+		failed[m.WalletID] = failed[m.WalletID] || m.Attempts > 0
+	}
+	if len(batch) == 0 {
+		return 0, nil
+	}
+	saved := map[int64]Message{}
+	for _, m := range relay(append([]Message(nil), batch...)) {
+		saved[m.ID] = m
+	}
+	var unsent []Message
+	for _, m := range s.unsent {
+		if saved, ok := saved[m.ID]; ok {
+			m = saved
+		}
+		if m.SentAt == nil && m.DeadAt == nil {
+			unsent = append(unsent, m)
+		}
+	}
+	s.unsent = unsent
+	return len(batch), nil
+}
+
+func (s *StubStore) PurgeOutbox(before time.Time) (int, error) {
+	return 0, nil
+}
+
+// failing publishes every message but those of a wallet.
+type failing struct {
+	wallet    int
+	published []string
+}
+
+func (f *failing) Publish(ctx context.Context, message Message) error {
+	if message.WalletID == f.wallet {
+		return errors.New("broker unavailable")
+	}
+	f.published = append(f.published, message.EventID)
+	return nil
+}
+
+func messages() []Message {
+	return []Message{
+		{ID: 1, EventID: "evt_1", WalletID: 1, Type: "wallet.created"},
+		{ID: 2, EventID: "evt_2", WalletID: 2, Type: "wallet.created"},
+		{ID: 3, EventID: "evt_3", WalletID: 1, Type: "wallet.balance_changed"},
+		{ID: 4, EventID: "evt_4", WalletID: 2, Type: "wallet.balance_changed"},
+	}
+}
+
+func TestRelay(t *testing.T) {
+	t.Run("given all published should mark all sent", func(t *testing.T) {
+		p := &failing{}
+		attempted := Relay(context.Background(), p, messages(), time.Now)
+
+		if strings.Join(p.published, ",") != "evt_1,evt_2,evt_3,evt_4" {
+			t.Errorf("expected messages published in order but got %v", p.published)
+		}
+		for _, m := range attempted {
+			if m.SentAt == nil || m.Attempts != 1 {
+				t.Errorf("expected message %d sent after 1 attempt but got %+v", m.ID, m)
+			}
+		}
+	})
+
+	t.Run("given a wallet fails should hold back its later messages", func(t *testing.T) {
+		p := &failing{wallet: 1}
+		attempted := Relay(context.Background(), p, messages(), time.Now)
+
+		if strings.Join(p.published, ",") != "evt_2,evt_4" {
+			t.Errorf("expected only wallet 2 published but got %v", p.published)
+		}
+		if len(attempted) != 3 {
+			t.Fatalf("expected 3 attempted messages but got %d", len(attempted))
+		}
+		if attempted[0].SentAt != nil || attempted[0].LastError != "broker unavailable" {
+			t.Errorf("expected failure recorded on message 1 but got %+v", attempted[0])
+		}
+		for _, m := range attempted {
+			if m.ID == 3 {
+				t.Errorf("expected message 3 to wait for message 1")
+			}
+		}
+	})
+}
+
+func TestRelayDeadLetter(t *testing.T) {
+	batch := messages()
+	batch[0].Attempts = MaxAttempts - 1
+	p := &failing{wallet: 1}
+	attempted := Relay(context.Background(), p, batch, time.Now)
+
+	if len(attempted) != 4 || attempted[0].DeadAt == nil || attempted[0].SentAt != nil {
+		t.Fatalf("expected message 1 dead-lettered and the rest attempted but got %+v", attempted)
+	}
+	if attempted[2].ID != 3 || attempted[2].DeadAt != nil || attempted[2].Attempts != 1 {
+		t.Errorf("expected message 3 to be tried once message 1 was given up but got %+v", attempted[2])
+	}
+}
+
+func TestRelayJobStuckWallet(t *testing.T) {
+	// A wallet with a whole batch of messages behind a failed one must not
+	// keep the other wallets waiting.
+	store := &StubStore{}
+	for i := 1; i <= relayBatch+1; i++ {
+		store.unsent = append(store.unsent, Message{ID: int64(i), EventID: "evt_" + strconv.Itoa(i), WalletID: 1})
+	}
+	store.unsent[0].Attempts = 1
+	store.unsent = append(store.unsent, Message{ID: relayBatch + 2, EventID: "evt_other", WalletID: 2})
+	p := &failing{wallet: 1}
+
+	if err := RelayJob(store, p)(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(p.published, ",") != "evt_other" {
+		t.Errorf("expected wallet 2 to be published but got %v", p.published)
+	}
+}
+
+func TestRelayJob(t *testing.T) {
+	store := &StubStore{unsent: messages()}
+	p := &failing{wallet: 1}
+
+	if err := RelayJob(store, p)(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(store.unsent) != 2 || store.unsent[0].ID != 1 || store.unsent[1].ID != 3 {
+		t.Fatalf("expected wallet 1 messages left unsent but got %+v", store.unsent)
+	}
+	if store.unsent[0].Attempts != 1 || store.unsent[1].Attempts != 0 {
+		t.Errorf("expected only message 1 attempted but got %+v", store.unsent)
+	}
+
+	p.wallet = 0
+	if err := RelayJob(store, p)(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(store.unsent) != 0 || strings.Join(p.published, ",") != "evt_2,evt_4,evt_1,evt_3" {
+		t.Errorf("expected wallet 1 published in order on retry but got %v", p.published)
+	}
+}
+
+func TestMulti(t *testing.T) {
+	first, second := &failing{}, &failing{wallet: 2}
+	p := Multi(first, second)
+
+	if err := p.Publish(context.Background(), Message{EventID: "evt_1", WalletID: 1}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := p.Publish(context.Background(), Message{EventID: "evt_2", WalletID: 2}); err == nil {
+		t.Errorf("expected error from second publisher")
+	}
+	if len(first.published) != 2 || len(second.published) != 1 {
+		t.Errorf("expected both publishers to be called but got %v and %v", first.published, second.published)
+	}
+}
+
+// natsServer speaks enough of the NATS protocol to accept a client and
+// hand over what it publishes.
+func natsServer(t *testing.T) (string, <-chan *nats.Msg) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { lis.Close() })
+	published := make(chan *nats.Msg, 10)
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte(`INFO {"server_id":"test","version":"2.10.0","proto":1,"headers":true,"max_payload":1048576}` + "\r\n"))
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			fields := strings.Fields(line)
+			if len(fields) == 0 {
+				continue
+			}
+			switch fields[0] {
+			case "PING":
+				conn.Write([]byte("PONG\r\n"))
+			case "HPUB":
+				// HPUB <subject> <header size> <total size>
+				headerSize, _ := strconv.Atoi(fields[2])
+				totalSize, _ := strconv.Atoi(fields[3])
+				buf := make([]byte, totalSize+2)
+				if _, err := io.ReadFull(r, buf); err != nil {
+					return
+				}
+				msg := nats.NewMsg(fields[1])
+				for _, h := range strings.Split(string(buf[:headerSize]), "\r\n")[1:] {
+					if key, value, ok := strings.Cut(h, ":"); ok {
+						msg.Header.Set(key, strings.TrimSpace(value))
+					}
+				}
+				msg.Data = buf[headerSize:totalSize]
+				published <- msg
+			}
+		}
+	}()
+	return "nats://" + lis.Addr().String(), published
+}
+
+func TestNATS(t *testing.T) {
+	url, published := natsServer(t)
+	conn, err := nats.Connect(url)
+	if err != nil {
+		t.Fatalf("unable to connect: %v", err)
+	}
+	defer conn.Close()
+
+	payload := json.RawMessage(`{"id":"evt_1"}`)
+	err = NATS(conn, "events.").Publish(context.Background(), Message{EventID: "evt_1", Type: "wallet.created", Payload: payload})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	select {
+	case msg := <-published:
+		if msg.Subject != "events.wallet.created" {
+			t.Errorf("expected subject events.wallet.created but got %s", msg.Subject)
+		}
+		if msg.Header.Get(nats.MsgIdHdr) != "evt_1" {
+			t.Errorf("expected Nats-Msg-Id evt_1 but got %q", msg.Header.Get(nats.MsgIdHdr))
+		}
+		if string(msg.Data) != string(payload) {
+			t.Errorf("expected payload %s but got %s", payload, msg.Data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected message to be published")
+	}
+}
@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/apikey"
+	"github.com/lib/pq"
+)
+
+const apiKeyColumns = "id, name, prefix, scopes, expires_at, last_used_at, revoked_at, created_at"
+
+func scanAPIKey(row scanner) (apikey.APIKey, error) {
+	var k apikey.APIKey
+	err := row.Scan(&k.ID, &k.Name, &k.Prefix, pq.Array(&k.Scopes),
+		&k.ExpiresAt, &k.LastUsedAt, &k.RevokedAt, &k.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return k, apikey.ErrNotFound
+	}
+	return k, err
+}
+
+func (p *Postgres) APIKeys() ([]apikey.APIKey, error) {
+	rows, err := p.conn().Query("SELECT " + apiKeyColumns + " FROM api_key ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []apikey.APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+func (p *Postgres) APIKeyByHash(hash string) (apikey.APIKey, error) {
+	row := p.conn().QueryRow("SELECT "+apiKeyColumns+" FROM api_key WHERE key_hash = $1", hash)
+	return scanAPIKey(row)
+}
+
+func (p *Postgres) CreateAPIKey(createAPIKey apikey.CreateAPIKey, secret apikey.Secret) (apikey.APIKey, error) {
+	row := p.conn().QueryRow("INSERT INTO api_key(name, prefix, key_hash, scopes, expires_at) VALUES($1,$2,$3,$4,$5) "+
+		"RETURNING "+apiKeyColumns,
+		createAPIKey.Name, secret.Prefix, secret.Hash, pq.Array(createAPIKey.Scopes), createAPIKey.ExpiresAt)
+	return scanAPIKey(row)
+}
+
+func (p *Postgres) RotateAPIKey(id int, secret apikey.Secret) (apikey.APIKey, error) {
+	row := p.conn().QueryRow("UPDATE api_key SET prefix = $1, key_hash = $2, last_used_at = NULL "+
+		"WHERE id = $3 AND revoked_at IS NULL RETURNING "+apiKeyColumns,
+		secret.Prefix, secret.Hash, id)
+	return scanAPIKey(row)
+}
+
+func (p *Postgres) RevokeAPIKey(id int) error {
+	res, err := p.conn().Exec("UPDATE api_key SET revoked_at = $1 WHERE id = $2 AND revoked_at IS NULL", time.Now(), id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return apikey.ErrNotFound
+	}
+	return nil
+}
+
+func (p *Postgres) TouchAPIKey(id int, usedAt time.Time) error {
+	_, err := p.conn().Exec("UPDATE api_key SET last_used_at = $1 WHERE id = $2", usedAt, id)
+	return err
+}
@@ -0,0 +1,111 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/asset"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet"
+)
+
+// Balances are stored in the smallest unit of the asset (satoshi, wei),
+// so NUMERIC(38, 0) holds any of them exactly.
+const holdingColumns = "wallet_id, asset, address, balance, created_at"
+
+func scanHolding(row scanner) (asset.Holding, error) {
+	var h asset.Holding
+	var balance string
+	err := row.Scan(&h.WalletID, &h.Asset, &h.Address, &balance, &h.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return h, asset.ErrNotFound
+	}
+	if err != nil {
+		return h, err
+	}
+	units, ok := new(big.Int).SetString(balance, 10)
+	if !ok {
+		return h, fmt.Errorf("invalid %s balance %q", h.Asset, balance)
+	}
+	return h, h.SetUnits(units)
+}
+
+func (p *Postgres) Holdings(walletID int) ([]asset.Holding, error) {
+	rows, err := p.conn().Query("SELECT "+holdingColumns+" FROM wallet_asset WHERE wallet_id = $1 ORDER BY asset", walletID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	holdings := []asset.Holding{}
+	for rows.Next() {
+		h, err := scanHolding(rows)
+		if err != nil {
+			return nil, err
+		}
+		holdings = append(holdings, h)
+	}
+	return holdings, rows.Err()
+}
+
+func (p *Postgres) Holding(walletID int, code string) (asset.Holding, error) {
+	return scanHolding(p.conn().QueryRow("SELECT "+holdingColumns+" FROM wallet_asset WHERE wallet_id = $1 AND asset = $2",
+		walletID, code))
+}
+
+func (p *Postgres) CreateHolding(walletID int, createHolding asset.CreateHolding, actor wallet.Actor) (asset.Holding, error) {
+	var result asset.Holding
+	err := p.inTx(func(tx *sql.Tx) error {
+		w, err := lockWallet(tx, walletID)
+		if err != nil {
+			return err
+		}
+		if w.WalletType != wallet.TypeCrypto {
+			return asset.ErrNotCrypto
+		}
+		result, err = scanHolding(tx.QueryRow("INSERT INTO wallet_asset(wallet_id, asset, address) VALUES($1,$2,$3) "+
+			"ON CONFLICT (wallet_id, asset) DO NOTHING RETURNING "+holdingColumns,
+			walletID, createHolding.Asset, createHolding.Address))
+		if errors.Is(err, asset.ErrNotFound) {
+			return asset.ErrDuplicate
+		}
+		if err != nil {
+			return err
+		}
+		if err := insertAudit(tx, actor, wallet.ActionHolding, walletID, nil, &result, ""); err != nil {
+			return err
+		}
+		return emitHoldingEvent(tx, w, nil, result)
+	})
+	return result, err
+}
+
+func (p *Postgres) AdjustHolding(walletID int, code string, delta *big.Int, description string, actor wallet.Actor) (asset.Holding, error) {
+	var result asset.Holding
+	err := p.inTx(func(tx *sql.Tx) error {
+		w, err := lockWallet(tx, walletID)
+		if err != nil {
+			return err
+		}
+		before, err := scanHolding(tx.QueryRow("SELECT "+holdingColumns+" FROM wallet_asset "+
+			"WHERE wallet_id = $1 AND asset = $2 FOR UPDATE", walletID, code))
+		if err != nil {
+			return err
+		}
+		balance, err := asset.Apply(w, before, delta)
+		if err != nil {
+			return err
+		}
+		result, err = scanHolding(tx.QueryRow("UPDATE wallet_asset SET balance = $1 WHERE wallet_id = $2 AND asset = $3 "+
+			"RETURNING "+holdingColumns, balance.String(), walletID, code))
+		if err != nil {
+			return err
+		}
+		if err := insertAudit(tx, actor, wallet.ActionHolding, walletID, &before, &result, description); err != nil {
+			return err
+		}
+		return emitHoldingEvent(tx, w, &before, result)
+	})
+	return result, err
+}
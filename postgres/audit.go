@@ -0,0 +1,104 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet"
+)
+
+const auditColumns = "id, actor, action, wallet_id, before, after, reason, request_id, created_at"
+
+// insertAudit records a wallet mutation in tx, so the audit entry is
+// committed if and only if the change itself is. before and after are
+// usually the wallet, or whatever part of it changed.
+func insertAudit[T any](tx *sql.Tx, actor wallet.Actor, action string, walletID int, before, after *T, reason string) error {
+	beforeJSON, err := auditJSON(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := auditJSON(after)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec("INSERT INTO wallet_audit(actor, action, wallet_id, before, after, reason, request_id) "+
+		"VALUES($1,$2,$3,$4,$5,$6,$7)",
+		actor.Name, action, walletID, beforeJSON, afterJSON, reason, actor.RequestID)
+	return err
+}
+
+func auditJSON[T any](v *T) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func scanAuditEntries(rows *sql.Rows) ([]wallet.AuditEntry, error) {
+	defer rows.Close()
+
+	entries := []wallet.AuditEntry{}
+	for rows.Next() {
+		var e wallet.AuditEntry
+		var before, after []byte
+		err := rows.Scan(&e.ID, &e.Actor, &e.Action, &e.WalletID,
+			&before, &after, &e.Reason, &e.RequestID, &e.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		e.Before, e.After = before, after
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func (p *Postgres) WalletAudit(walletID int) ([]wallet.AuditEntry, error) {
+	rows, err := p.conn().Query("SELECT "+auditColumns+" FROM wallet_audit WHERE wallet_id = $1 ORDER BY id", walletID)
+	if err != nil {
+		return nil, err
+	}
+	return scanAuditEntries(rows)
+}
+
+func (p *Postgres) AuditEntries(filter wallet.AuditFilter) ([]wallet.AuditEntry, error) {
+	var where []string
+	var args []any
+	add := func(cond string, arg any) {
+		args = append(args, arg)
+		where = append(where, cond+" $"+strconv.Itoa(len(args)))
+	}
+	if filter.Actor != "" {
+		add("actor =", filter.Actor)
+	}
+	if filter.Action != "" {
+		add("action =", filter.Action)
+	}
+	if filter.WalletID != 0 {
+		add("wallet_id =", filter.WalletID)
+	}
+	if filter.From != nil {
+		add("created_at >=", *filter.From)
+	}
+	if filter.To != nil {
+		add("created_at <", *filter.To)
+	}
+
+	sqlStr := "SELECT " + auditColumns + " FROM wallet_audit"
+	if len(where) > 0 {
+		sqlStr += " WHERE " + strings.Join(where, " AND ")
+	}
+	args = append(args, filter.Limit)
+	sqlStr += " ORDER BY id DESC LIMIT $" + strconv.Itoa(len(args))
+
+	rows, err := p.conn().Query(sqlStr, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanAuditEntries(rows)
+}
@@ -0,0 +1,206 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/hold"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/ledger"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet"
+)
+
+const holdColumns = "id, wallet_id, amount, captured, status, description, expires_at, created_at, updated_at"
+
+func scanHold(row scanner) (hold.Hold, error) {
+	var h hold.Hold
+	err := row.Scan(&h.ID, &h.WalletID, &h.Amount, &h.Captured, &h.Status, &h.Description,
+		&h.ExpiresAt, &h.CreatedAt, &h.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return h, hold.ErrNotFound
+	}
+	return h, err
+}
+
+func collectHolds(rows *sql.Rows) ([]hold.Hold, error) {
+	defer rows.Close()
+
+	holds := []hold.Hold{}
+	for rows.Next() {
+		h, err := scanHold(rows)
+		if err != nil {
+			return nil, err
+		}
+		holds = append(holds, h)
+	}
+	return holds, rows.Err()
+}
+
+func (p *Postgres) WalletHolds(walletID int) ([]hold.Hold, error) {
+	rows, err := p.conn().Query("SELECT "+holdColumns+" FROM wallet_hold WHERE wallet_id = $1 ORDER BY id DESC", walletID)
+	if err != nil {
+		return nil, err
+	}
+	return collectHolds(rows)
+}
+
+func (p *Postgres) PlaceHold(walletID int, placeHold hold.PlaceHold, expiresAt time.Time, actor wallet.Actor) (hold.Hold, error) {
+	var result hold.Hold
+	err := p.inTx(func(tx *sql.Tx) error {
+		w, err := lockWallet(tx, walletID)
+		if err != nil {
+			return err
+		}
+		// A hold must be covered like a debit of its amount would be,
+		// on top of the holds already placed.
+		amount := ledger.Round(placeHold.Amount)
+		if err := wallet.CheckBalanceChange(w, ledger.Round(w.Balance-amount)); err != nil {
+			return err
+		}
+		result, err = scanHold(tx.QueryRow("INSERT INTO wallet_hold(wallet_id, amount, description, expires_at) "+
+			"VALUES($1,$2,$3,$4) RETURNING "+holdColumns, walletID, amount, placeHold.Description, expiresAt))
+		if err != nil {
+			return err
+		}
+		if err := insertAudit(tx, actor, wallet.ActionHold, walletID, nil, &result, placeHold.Description); err != nil {
+			return err
+		}
+		return emitHeldEvents(tx, w)
+	})
+	return result, err
+}
+
+// emitHeldEvents writes the balance_changed event of a change to the
+// holds of a wallet, from before, as locked at the start of the change,
+// to how it reads now.
+func emitHeldEvents(tx *sql.Tx, before wallet.Wallet) error {
+	after, err := lockWallet(tx, before.ID)
+	if err != nil {
+		return err
+	}
+	return emitWalletEvents(tx, &before, &after)
+}
+
+// lockHold locks the wallet of a hold, then the hold, in the same order
+// as PlaceHold and postTransaction lock them.
+func lockHold(tx *sql.Tx, holdID int) (hold.Hold, wallet.Wallet, error) {
+	var walletID int
+	err := tx.QueryRow("SELECT wallet_id FROM wallet_hold WHERE id = $1", holdID).Scan(&walletID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return hold.Hold{}, wallet.Wallet{}, hold.ErrNotFound
+	}
+	if err != nil {
+		return hold.Hold{}, wallet.Wallet{}, err
+	}
+	w, err := lockWallet(tx, walletID)
+	if err != nil {
+		return hold.Hold{}, w, err
+	}
+	h, err := scanHold(tx.QueryRow("SELECT "+holdColumns+" FROM wallet_hold WHERE id = $1 FOR UPDATE", holdID))
+	return h, w, err
+}
+
+func (p *Postgres) CaptureHold(holdID int, captureHold hold.CaptureHold, actor wallet.Actor) (hold.Hold, error) {
+	var result hold.Hold
+	err := p.inTx(func(tx *sql.Tx) error {
+		before, _, err := lockHold(tx, holdID)
+		if err != nil {
+			return err
+		}
+		amount, err := before.Capture(captureHold, time.Now())
+		if err != nil {
+			return err
+		}
+		// Release the hold before posting, so the debit is checked
+		// against the balance the hold reserved.
+		result, err = scanHold(tx.QueryRow("UPDATE wallet_hold SET status = $1, captured = $2, updated_at = now() "+
+			"WHERE id = $3 RETURNING "+holdColumns, hold.StatusCaptured, amount, holdID))
+		if err != nil {
+			return err
+		}
+		_, err = postTransaction(tx, ledger.PostTransaction{
+			WalletID:    before.WalletID,
+			Amount:      -amount,
+			Kind:        ledger.KindCapture,
+			Description: before.Description,
+			Reference:   hold.Reference(holdID),
+		}, actor)
+		if err != nil {
+			return err
+		}
+		return insertAudit(tx, actor, wallet.ActionHold, before.WalletID, &before, &result, "")
+	})
+	return result, err
+}
+
+func (p *Postgres) VoidHold(holdID int, actor wallet.Actor) (hold.Hold, error) {
+	var result hold.Hold
+	err := p.inTx(func(tx *sql.Tx) error {
+		before, w, err := lockHold(tx, holdID)
+		if err != nil {
+			return err
+		}
+		if err := before.CheckActive(time.Now()); err != nil {
+			return err
+		}
+		result, err = scanHold(tx.QueryRow("UPDATE wallet_hold SET status = $1, updated_at = now() "+
+			"WHERE id = $2 RETURNING "+holdColumns, hold.StatusVoided, holdID))
+		if err != nil {
+			return err
+		}
+		if err := insertAudit(tx, actor, wallet.ActionHold, before.WalletID, &before, &result, ""); err != nil {
+			return err
+		}
+		return emitHeldEvents(tx, w)
+	})
+	return result, err
+}
+
+func (p *Postgres) ExpireHolds(now time.Time, actor wallet.Actor) (int, error) {
+	var expired []hold.Hold
+	err := p.inTx(func(tx *sql.Tx) error {
+		// Lock the wallets before their holds, in the order of lockHold.
+		rows, err := tx.Query("SELECT "+walletColumns+" FROM user_wallet WHERE deleted_at IS NULL AND id IN "+
+			"(SELECT wallet_id FROM wallet_hold WHERE status = $1 AND expires_at <= $2) ORDER BY id FOR UPDATE",
+			hold.StatusActive, now)
+		if err != nil {
+			return err
+		}
+		wallets, err := collectWallets(rows)
+		if err != nil {
+			return err
+		}
+		rows, err = tx.Query("UPDATE wallet_hold SET status = $1, updated_at = now() "+
+			"WHERE status = $2 AND expires_at <= $3 RETURNING "+holdColumns,
+			hold.StatusExpired, hold.StatusActive, now)
+		if err != nil {
+			return err
+		}
+		expired, err = collectHolds(rows)
+		if err != nil {
+			return err
+		}
+		released := map[int]float64{}
+		for _, h := range expired {
+			before := h
+			before.Status = hold.StatusActive
+			if err := insertAudit(tx, actor, wallet.ActionHold, h.WalletID, &before, &h, "expired"); err != nil {
+				return err
+			}
+			released[h.WalletID] += h.Amount
+		}
+		// Reads stop counting a hold once it expires, so the wallets
+		// already look released; the events show the funds they held
+		// until now.
+		for _, after := range wallets {
+			before := after
+			before.Held = ledger.Round(before.Held + released[after.ID])
+			before.Derive()
+			if err := emitWalletEvents(tx, &before, &after); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return len(expired), err
+}
@@ -0,0 +1,144 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/interest"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/ledger"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet"
+)
+
+const productColumns = "id, name, annual_rate, compounding, day_count, created_at"
+
+func scanProduct(row scanner, dest ...any) (interest.Product, error) {
+	var p interest.Product
+	err := row.Scan(append([]any{&p.ID, &p.Name, &p.AnnualRate, &p.Compounding, &p.DayCount, &p.CreatedAt}, dest...)...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return p, interest.ErrNotFound
+	}
+	return p, err
+}
+
+func (p *Postgres) Products() ([]interest.Product, error) {
+	rows, err := p.conn().Query("SELECT " + productColumns + " FROM interest_product ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	products := []interest.Product{}
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, product)
+	}
+	return products, rows.Err()
+}
+
+func (p *Postgres) CreateProduct(createProduct interest.CreateProduct) (interest.Product, error) {
+	return scanProduct(p.conn().QueryRow("INSERT INTO interest_product(name, annual_rate, compounding, day_count) "+
+		"VALUES($1,$2,$3,$4) RETURNING "+productColumns,
+		createProduct.Name, createProduct.AnnualRate, createProduct.Compounding, createProduct.DayCount))
+}
+
+func (p *Postgres) AttachProduct(walletID int, productID int) error {
+	return p.inTx(func(tx *sql.Tx) error {
+		w, err := lockWallet(tx, walletID)
+		if err != nil {
+			return err
+		}
+		if w.WalletType != wallet.TypeSavings {
+			return interest.ErrNotSavings
+		}
+		_, err = scanProduct(tx.QueryRow("SELECT "+productColumns+" FROM interest_product WHERE id = $1", productID))
+		if err != nil {
+			return err
+		}
+		today := time.Now().UTC().Truncate(24 * time.Hour)
+		_, err = tx.Exec("INSERT INTO savings_interest(wallet_id, product_id, accrued_through) VALUES($1,$2,$3) "+
+			"ON CONFLICT (wallet_id) DO UPDATE SET product_id = EXCLUDED.product_id",
+			walletID, productID, today)
+		return err
+	})
+}
+
+func (p *Postgres) Accounts() ([]interest.Account, error) {
+	rows, err := p.conn().Query("SELECT p.id, p.name, p.annual_rate, p.compounding, p.day_count, p.created_at, " +
+		"s.wallet_id, s.accrued_through FROM savings_interest s " +
+		"JOIN interest_product p ON p.id = s.product_id " +
+		"JOIN user_wallet w ON w.id = s.wallet_id " +
+		"WHERE w.deleted_at IS NULL AND w.status <> 'closed' ORDER BY s.wallet_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := []interest.Account{}
+	for rows.Next() {
+		var a interest.Account
+		a.Product, err = scanProduct(rows, &a.WalletID, &a.AccruedThrough)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, a)
+	}
+	return accounts, rows.Err()
+}
+
+func (p *Postgres) AccrueInterest(account interest.Account, end time.Time) error {
+	return p.inTx(func(tx *sql.Tx) error {
+		// Claim the period: only one run can move accrued_through from
+		// its current value, the others see no row and back off.
+		res, err := tx.Exec("UPDATE savings_interest SET accrued_through = $1 WHERE wallet_id = $2 AND accrued_through = $3",
+			end, account.WalletID, account.AccruedThrough)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return interest.ErrAlreadyAccrued
+		}
+
+		w, err := lockWallet(tx, account.WalletID)
+		if err != nil {
+			return err
+		}
+		// Each day of the period earns on its own balance, worked back
+		// from the current one through the ledger like statements are,
+		// not on the balance when the job happens to run.
+		rows, err := tx.Query("SELECT "+transactionColumns+" FROM wallet_transaction "+
+			"WHERE wallet_id = $1 AND created_at >= $2 ORDER BY id", account.WalletID, account.AccruedThrough)
+		if err != nil {
+			return err
+		}
+		since, err := collectTransactions(rows)
+		if err != nil {
+			return err
+		}
+		opening, changes := interest.BalanceHistory(w.Balance, since, account.AccruedThrough, end)
+		amount := account.Product.InterestOn(opening, changes, account.AccruedThrough, end)
+		if amount <= 0 {
+			return nil
+		}
+		_, err = postTransaction(tx, ledger.PostTransaction{
+			WalletID: account.WalletID,
+			Amount:   amount,
+			Kind:     ledger.KindInterest,
+			Description: fmt.Sprintf("Interest %s to %s at %.4g%% (%s)", account.AccruedThrough.Format(time.DateOnly),
+				end.Format(time.DateOnly), account.Product.AnnualRate*100, account.Product.DayCount),
+			Reference: interest.Reference(account.WalletID, end),
+		}, wallet.Actor{Name: "system:interest"})
+		if errors.Is(err, ledger.ErrDuplicate) {
+			return nil
+		}
+		return err
+	})
+}
@@ -0,0 +1,145 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/ledger"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet"
+	"github.com/lib/pq"
+)
+
+// transactionColumns ends with the sum of the reversals of a
+// transaction, so it may be selected from wallet_transaction only.
+const transactionColumns = "id, wallet_id, amount, balance_after, kind, description, COALESCE(reference, ''), reversal_of, created_at, " +
+	"(SELECT COALESCE(SUM(ABS(r.amount)), 0) FROM wallet_transaction r WHERE r.reversal_of = wallet_transaction.id)"
+
+func scanTransaction(row scanner) (ledger.Transaction, error) {
+	var t ledger.Transaction
+	err := row.Scan(&t.ID, &t.WalletID, &t.Amount, &t.BalanceAfter,
+		&t.Kind, &t.Description, &t.Reference, &t.ReversalOf, &t.CreatedAt, &t.Reversed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return t, ledger.ErrNotFound
+	}
+	return t, err
+}
+
+// collectTransactions scans and closes rows.
+func collectTransactions(rows *sql.Rows) ([]ledger.Transaction, error) {
+	defer rows.Close()
+
+	transactions := []ledger.Transaction{}
+	for rows.Next() {
+		t, err := scanTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, t)
+	}
+	return transactions, rows.Err()
+}
+
+func (p *Postgres) WalletTransactions(walletID int) ([]ledger.Transaction, error) {
+	rows, err := p.conn().Query("SELECT "+transactionColumns+" FROM wallet_transaction WHERE wallet_id = $1 ORDER BY id", walletID)
+	if err != nil {
+		return nil, err
+	}
+	return collectTransactions(rows)
+}
+
+// TransactionsByWallets returns up to limit transactions of each of the
+// wallets after the id after, in one query, in id order.
+func (p *Postgres) TransactionsByWallets(walletIDs []int, after int, limit int) ([]ledger.Transaction, error) {
+	rows, err := p.conn().Query("SELECT "+transactionColumns+" FROM wallet_transaction WHERE id IN ("+
+		"SELECT id FROM (SELECT id, ROW_NUMBER() OVER (PARTITION BY wallet_id ORDER BY id) AS n FROM wallet_transaction "+
+		"WHERE wallet_id = ANY($1) AND id > $2) page WHERE n <= $3) ORDER BY id",
+		pq.Array(walletIDs), after, limit)
+	if err != nil {
+		return nil, err
+	}
+	return collectTransactions(rows)
+}
+
+// insertTransaction writes a ledger entry without touching the balance,
+// for callers that have already updated it.
+func insertTransaction(tx *sql.Tx, t ledger.Transaction) (ledger.Transaction, error) {
+	result, err := scanTransaction(tx.QueryRow("INSERT INTO wallet_transaction"+
+		"(wallet_id, amount, balance_after, kind, description, reference, reversal_of) VALUES($1,$2,$3,$4,$5,NULLIF($6, ''),$7) "+
+		"ON CONFLICT (reference) DO NOTHING RETURNING "+transactionColumns,
+		t.WalletID, t.Amount, t.BalanceAfter, t.Kind, t.Description, t.Reference, t.ReversalOf))
+	if errors.Is(err, ledger.ErrNotFound) {
+		return result, ledger.ErrDuplicate
+	}
+	return result, err
+}
+
+// postTransaction moves money in or out of a wallet: it checks the
+// change against the wallet's status and limits, writes the ledger
+// entry and updates the balance, all in tx.
+func postTransaction(tx *sql.Tx, post ledger.PostTransaction, actor wallet.Actor) (ledger.Transaction, error) {
+	before, err := lockWallet(tx, post.WalletID)
+	if err != nil {
+		return ledger.Transaction{}, err
+	}
+	amount := ledger.Round(post.Amount)
+	balance := ledger.Round(before.Balance + amount)
+	check := wallet.CheckBalanceChange
+	if post.AllowOverLimit {
+		check = wallet.CheckStatus
+	}
+	if err := check(before, balance); err != nil {
+		return ledger.Transaction{}, err
+	}
+	result, err := insertTransaction(tx, ledger.Transaction{
+		WalletID:     post.WalletID,
+		Amount:       amount,
+		BalanceAfter: balance,
+		Kind:         post.Kind,
+		Description:  post.Description,
+		Reference:    post.Reference,
+		ReversalOf:   post.ReversalOf,
+	})
+	if err != nil {
+		return result, err
+	}
+	after, err := scanWallet(tx.QueryRow("UPDATE user_wallet SET balance = $1 WHERE id = $2 RETURNING "+walletColumns,
+		balance, post.WalletID))
+	if err != nil {
+		return result, err
+	}
+	if err := insertAudit(tx, actor, wallet.ActionTransaction, post.WalletID, &before, &after, post.Description); err != nil {
+		return result, err
+	}
+	return result, emitWalletEvents(tx, &before, &after)
+}
+
+func (p *Postgres) ReverseTransaction(transactionID int, reverse ledger.Reverse, actor wallet.Actor) (ledger.Transaction, error) {
+	var result ledger.Transaction
+	err := p.inTx(func(tx *sql.Tx) error {
+		// Locking the original serializes its reversals, so they cannot
+		// add up to more than it. What it has been reversed by is only
+		// read once the lock is held: a statement that waits for a lock
+		// still sees the snapshot from before it waited, without the
+		// reversal that held the lock.
+		var id int
+		err := tx.QueryRow("SELECT id FROM wallet_transaction WHERE id = $1 FOR UPDATE", transactionID).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ledger.ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		original, err := scanTransaction(tx.QueryRow("SELECT "+transactionColumns+" FROM wallet_transaction "+
+			"WHERE id = $1", transactionID))
+		if err != nil {
+			return err
+		}
+		post, err := original.Reversal(reverse)
+		if err != nil {
+			return err
+		}
+		result, err = postTransaction(tx, post, actor)
+		return err
+	})
+	return result, err
+}
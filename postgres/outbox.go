@@ -0,0 +1,122 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/asset"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/outbox"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/webhook"
+)
+
+const outboxColumns = "id, event_id, wallet_id, event_type, payload, created_at, attempts, last_error, sent_at, dead_at"
+
+func scanMessage(row scanner) (outbox.Message, error) {
+	var m outbox.Message
+	var payload []byte
+	err := row.Scan(&m.ID, &m.EventID, &m.WalletID, &m.Type, &payload, &m.CreatedAt, &m.Attempts, &m.LastError, &m.SentAt, &m.DeadAt)
+	m.Payload = payload
+	return m, err
+}
+
+// collectMessages scans and closes rows.
+func collectMessages(rows *sql.Rows) ([]outbox.Message, error) {
+	defer rows.Close()
+	var messages []outbox.Message
+	for rows.Next() {
+		m, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
+
+// relayLock is the advisory lock held by the relay, so that only one
+// replica publishes at a time and the messages of a wallet keep their
+// order.
+const relayLock = 0x6f7574626f78
+
+// emitWalletEvents writes the events of a wallet changing from before to
+// after to the outbox, in tx, so that they are published if and only if
+// the change commits.
+func emitWalletEvents(tx *sql.Tx, before, after *wallet.Wallet) error {
+	events, err := webhook.WalletEvents(before, after, time.Now())
+	if err != nil {
+		return err
+	}
+	return insertEvents(tx, events)
+}
+
+// emitHoldingEvent writes the event of a crypto holding of w changing
+// from before to after to the outbox, in tx.
+func emitHoldingEvent(tx *sql.Tx, w wallet.Wallet, before *asset.Holding, after asset.Holding) error {
+	event, err := webhook.HoldingEvent(w, before, after, time.Now())
+	if err != nil {
+		return err
+	}
+	return insertEvents(tx, []webhook.Event{event})
+}
+
+func insertEvents(tx *sql.Tx, events []webhook.Event) error {
+	for _, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("INSERT INTO outbox(event_id, wallet_id, event_type, payload, created_at) VALUES($1,$2,$3,$4,$5)",
+			event.ID, event.Data.Wallet.ID, event.Type, string(payload), event.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Postgres) RelayOutbox(limit int, relay func(messages []outbox.Message) []outbox.Message) (int, error) {
+	var n int
+	// relay publishes the messages, so a retry would send them again.
+	err := p.inTxOnce(func(tx *sql.Tx) error {
+		var locked bool
+		if err := tx.QueryRow("SELECT pg_try_advisory_xact_lock($1)", relayLock).Scan(&locked); err != nil || !locked {
+			return err
+		}
+		rows, err := tx.Query("SELECT "+outboxColumns+" FROM outbox m WHERE sent_at IS NULL AND dead_at IS NULL "+
+			"AND NOT EXISTS (SELECT 1 FROM outbox failed WHERE failed.wallet_id = m.wallet_id AND failed.id < m.id "+
+			"AND failed.sent_at IS NULL AND failed.dead_at IS NULL AND failed.attempts > 0) "+
+			"ORDER BY id LIMIT $1", limit)
+		if err != nil {
+			return err
+		}
+		messages, err := collectMessages(rows)
+		if err != nil {
+			return err
+		}
+		n = len(messages)
+		if n == 0 {
+			return nil
+		}
+
+		for _, m := range relay(messages) {
+			_, err := tx.Exec("UPDATE outbox SET attempts = $1, last_error = $2, sent_at = $3, dead_at = $4 WHERE id = $5",
+				m.Attempts, m.LastError, m.SentAt, m.DeadAt, m.ID)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return n, err
+}
+
+func (p *Postgres) PurgeOutbox(before time.Time) (int, error) {
+	res, err := p.conn().Exec("DELETE FROM outbox WHERE sent_at < $1", before)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
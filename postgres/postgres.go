@@ -1,17 +1,45 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"log"
+	"math/rand"
 	"os"
+	"strconv"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet"
+	"github.com/lib/pq"
 )
 
 type Postgres struct {
 	Db *sql.DB
+	// dsn is kept for the connections that LISTEN.
+	dsn string
+	// TxOptions configures the transactions the store begins.
+	TxOptions TxOptions
+	// tx is set on the Postgres that WithTx passes to its fn, whose
+	// methods then run in tx instead of on the pool.
+	tx *sql.Tx
 }
 
+// TxOptions configures the transactions of a Postgres.
+type TxOptions struct {
+	// Isolation is the isolation level transactions begin at, the
+	// default of the database (READ COMMITTED) if zero.
+	Isolation sql.IsolationLevel
+	// MaxRetries is how often a transaction that failed with a
+	// serialization failure or a deadlock is run again.
+	MaxRetries int
+}
+
+// New connects to the database at CONNECTION_STRING. TX_ISOLATION sets
+// the isolation level of its transactions, read-committed (the default),
+// repeatable-read or serializable, and TX_MAX_RETRIES how often they are
+// retried on serialization failures, 3 by default.
 func New() (*Postgres, error) {
 	databaseSource := os.Getenv("CONNECTION_STRING")
 	db, err := sql.Open("postgres", databaseSource)
@@ -23,5 +51,155 @@ func New() (*Postgres, error) {
 	if err != nil {
 		log.Fatal(err)
 	}
-	return &Postgres{Db: db}, nil
+	opts := TxOptions{MaxRetries: 3}
+	if opts.Isolation, err = ParseIsolation(os.Getenv("TX_ISOLATION")); err != nil {
+		return nil, err
+	}
+	if value := os.Getenv("TX_MAX_RETRIES"); value != "" {
+		if opts.MaxRetries, err = strconv.Atoi(value); err != nil || opts.MaxRetries < 0 {
+			return nil, fmt.Errorf("TX_MAX_RETRIES must be a number of retries, not %q", value)
+		}
+	}
+	return &Postgres{Db: db, dsn: databaseSource, TxOptions: opts}, nil
+}
+
+// ParseIsolation parses an isolation level as read-committed,
+// repeatable-read or serializable. Empty is the default of the database.
+func ParseIsolation(s string) (sql.IsolationLevel, error) {
+	switch s {
+	case "":
+		return sql.LevelDefault, nil
+	case "read-committed":
+		return sql.LevelReadCommitted, nil
+	case "repeatable-read":
+		return sql.LevelRepeatableRead, nil
+	case "serializable":
+		return sql.LevelSerializable, nil
+	}
+	return sql.LevelDefault, fmt.Errorf("unknown isolation level %q", s)
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx.
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// conn returns the transaction of the Postgres, if bound to one, or the
+// pool.
+func (p *Postgres) conn() querier {
+	if p.tx != nil {
+		return p.tx
+	}
+	return p.Db
+}
+
+// inTx runs fn in a transaction, committing if it returns nil and
+// rolling back otherwise. In the transaction of WithTx, fn runs in a
+// savepoint instead, so that a failing call leaves the transaction
+// usable.
+func (p *Postgres) inTx(fn func(tx *sql.Tx) error) error {
+	if p.tx != nil {
+		return savepoint(p.tx, fn)
+	}
+	return p.withTx(context.Background(), func(tx *Postgres) error {
+		return fn(tx.tx)
+	})
+}
+
+// inTxOnce is inTx for fn with side effects outside the transaction,
+// such as writing an export or publishing messages, which must not be
+// repeated by a retry.
+func (p *Postgres) inTxOnce(fn func(tx *sql.Tx) error) error {
+	if p.tx != nil {
+		return savepoint(p.tx, fn)
+	}
+	return p.runTx(context.Background(), func(tx *Postgres) error {
+		return fn(tx.tx)
+	})
+}
+
+// WithTx runs fn in a transaction at the isolation level of TxOptions,
+// retrying it up to MaxRetries times when it fails with a serialization
+// failure or a deadlock. Changes made through tx commit together, with
+// their audit entries and events, or not at all. A call through tx that
+// fails leaves the transaction usable, except for the few that are a
+// single statement, such as CreateAPIKey, after which fn should return.
+func (p *Postgres) WithTx(ctx context.Context, fn func(tx wallet.Storer) error) error {
+	return p.withTx(ctx, func(tx *Postgres) error {
+		return fn(tx)
+	})
+}
+
+func (p *Postgres) withTx(ctx context.Context, fn func(tx *Postgres) error) error {
+	if p.tx != nil {
+		return savepoint(p.tx, func(*sql.Tx) error {
+			return fn(p)
+		})
+	}
+	for attempt := 0; ; attempt++ {
+		err := p.runTx(ctx, fn)
+		if err == nil || !retryable(err) || attempt >= p.TxOptions.MaxRetries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(retryDelay(attempt)):
+		}
+	}
+}
+
+func (p *Postgres) runTx(ctx context.Context, fn func(tx *Postgres) error) error {
+	tx, err := p.Db.BeginTx(ctx, &sql.TxOptions{Isolation: p.TxOptions.Isolation})
+	if err != nil {
+		return err
+	}
+	bound := *p
+	bound.tx = tx
+	if err := fn(&bound); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// savepoint runs fn in a savepoint of tx, rolling back to it if fn
+// fails. Savepoints of the same name nest, the newest shadowing the
+// others, so nested calls can share the name as long as each releases
+// its own.
+func savepoint(tx *sql.Tx, fn func(tx *sql.Tx) error) error {
+	if _, err := tx.Exec("SAVEPOINT storer_call"); err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_, rbErr := tx.Exec("ROLLBACK TO SAVEPOINT storer_call; RELEASE SAVEPOINT storer_call")
+		return errors.Join(err, rbErr)
+	}
+	_, err := tx.Exec("RELEASE SAVEPOINT storer_call")
+	return err
+}
+
+// retryable reports whether err is a serialization failure or a
+// deadlock, after which the whole transaction may succeed when run
+// again.
+func retryable(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == "40001" || pqErr.Code == "40P01"
+}
+
+// retryDelay backs off exponentially from 10ms, with jitter so that
+// conflicting transactions do not retry in lockstep.
+func retryDelay(attempt int) time.Duration {
+	base := 10 * time.Millisecond << min(attempt, 6)
+	return base/2 + time.Duration(rand.Int63n(int64(base)))
 }
@@ -0,0 +1,438 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/asset"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/hold"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/ledger"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/outbox"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/ratelimit"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/statement"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/transfer"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet/storertest"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/webhook"
+	"github.com/lib/pq"
+)
+
+// TestConformance runs the suite against the database at
+// TEST_CONNECTION_STRING, set up with init.sql. It deletes every wallet,
+// so never point it at a database you need.
+func TestConformance(t *testing.T) {
+	storertest.RunErrors(t, func(t *testing.T) wallet.Storer {
+		db, err := sql.Open("postgres", "")
+		if err != nil {
+			t.Fatalf("unable to open database: %v", err)
+		}
+		db.Close()
+		return &Postgres{Db: db}
+	})
+
+	dsn := os.Getenv("TEST_CONNECTION_STRING")
+	if dsn == "" {
+		t.Skip("TEST_CONNECTION_STRING is not set")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("unable to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	storertest.Run(t, func(t *testing.T) wallet.Storer {
+		if _, err := db.Exec("DELETE FROM user_wallet"); err != nil {
+			t.Fatalf("unable to remove wallets: %v", err)
+		}
+		return &Postgres{Db: db, dsn: dsn}
+	})
+	t.Run("HoldEvents", func(t *testing.T) {
+		if _, err := db.Exec("DELETE FROM user_wallet"); err != nil {
+			t.Fatalf("unable to remove wallets: %v", err)
+		}
+		testHoldEvents(t, &Postgres{Db: db, dsn: dsn})
+	})
+	t.Run("ConcurrentReversals", func(t *testing.T) {
+		if _, err := db.Exec("DELETE FROM user_wallet"); err != nil {
+			t.Fatalf("unable to remove wallets: %v", err)
+		}
+		testConcurrentReversals(t, &Postgres{Db: db, dsn: dsn})
+	})
+	t.Run("RelayStuckWallet", func(t *testing.T) {
+		if _, err := db.Exec("DELETE FROM outbox"); err != nil {
+			t.Fatalf("unable to empty outbox: %v", err)
+		}
+		testRelayStuckWallet(t, &Postgres{Db: db, dsn: dsn})
+	})
+	t.Run("TransferAudit", func(t *testing.T) {
+		if _, err := db.Exec("DELETE FROM user_wallet"); err != nil {
+			t.Fatalf("unable to remove wallets: %v", err)
+		}
+		testTransferAudit(t, &Postgres{Db: db, dsn: dsn})
+	})
+	t.Run("SweepBuckets", func(t *testing.T) {
+		if _, err := db.Exec("DELETE FROM rate_limit_bucket"); err != nil {
+			t.Fatalf("unable to remove buckets: %v", err)
+		}
+		testSweepBuckets(t, &Postgres{Db: db, dsn: dsn})
+	})
+	t.Run("LateFees", func(t *testing.T) {
+		if _, err := db.Exec("DELETE FROM user_wallet"); err != nil {
+			t.Fatalf("unable to remove wallets: %v", err)
+		}
+		testLateFees(t, &Postgres{Db: db, dsn: dsn})
+	})
+}
+
+// testConcurrentReversals reverses a transaction of 100 in parts of 20
+// at once, of which only 5 may succeed.
+func testConcurrentReversals(t *testing.T, p *Postgres) {
+	actor := wallet.Actor{Name: "test"}
+	w, err := p.CreateWallet(wallet.CreateWallet{UserID: 1, WalletType: wallet.TypeSavings, Balance: 1000}, actor)
+	if err != nil {
+		t.Fatalf("unable to create wallet: %v", err)
+	}
+	var original ledger.Transaction
+	err = p.inTx(func(tx *sql.Tx) error {
+		original, err = postTransaction(tx, ledger.PostTransaction{WalletID: w.ID, Amount: -100, Kind: ledger.KindAdjustment}, actor)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("unable to post transaction: %v", err)
+	}
+
+	const n = 10
+	part := 20.0
+	errs := make(chan error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := p.ReverseTransaction(original.ID, ledger.Reverse{Amount: &part}, actor)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	reversed := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			reversed++
+		case !errors.Is(err, ledger.ErrInvalidReversal) && !errors.Is(err, ledger.ErrAlreadyReversed):
+			t.Errorf("expected a reversal past the amount to be rejected but got %v", err)
+		}
+	}
+	if reversed != 5 {
+		t.Errorf("expected 5 reversals of 20 to succeed but got %d", reversed)
+	}
+	if got, _ := p.WalletByUser(1); got.Balance != 1000 {
+		t.Errorf("expected the balance to be restored to 1000 but got %v", got.Balance)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&pq.Error{Code: "40001"}, true},
+		{fmt.Errorf("unable to update row: %w", &pq.Error{Code: "40P01"}), true},
+		{&pq.Error{Code: "23505"}, false},
+		{wallet.ErrNotFound, false},
+		{errors.New("connection refused"), false},
+	}
+	for _, tt := range tests {
+		if got := retryable(tt.err); got != tt.want {
+			t.Errorf("expected retryable(%v) to be %t", tt.err, tt.want)
+		}
+	}
+	for attempt := 0; attempt < 10; attempt++ {
+		if d := retryDelay(attempt); d < 5*time.Millisecond || d > 960*time.Millisecond {
+			t.Errorf("expected retry %d to back off within bounds but got %v", attempt, d)
+		}
+	}
+}
+
+func TestParseIsolation(t *testing.T) {
+	for s, want := range map[string]sql.IsolationLevel{
+		"":                sql.LevelDefault,
+		"read-committed":  sql.LevelReadCommitted,
+		"repeatable-read": sql.LevelRepeatableRead,
+		"serializable":    sql.LevelSerializable,
+	} {
+		if got, err := ParseIsolation(s); err != nil || got != want {
+			t.Errorf("expected %q to be %v but got %v, %v", s, want, got, err)
+		}
+	}
+	if _, err := ParseIsolation("snapshot"); err == nil {
+		t.Errorf("expected an unknown isolation level to be rejected")
+	}
+}
+
+func TestEventCursor(t *testing.T) {
+	messages := func(ids ...int64) []outbox.Message {
+		var messages []outbox.Message
+		for _, id := range ids {
+			messages = append(messages, outbox.Message{ID: id})
+		}
+		return messages
+	}
+	ids := func(messages []outbox.Message) []int64 {
+		var ids []int64
+		for _, m := range messages {
+			ids = append(ids, m.ID)
+		}
+		return ids
+	}
+
+	c := newEventCursor(10)
+	if got := ids(c.fresh(messages(12, 12))); !reflect.DeepEqual(got, []int64{12}) {
+		t.Errorf("expected 12 once but got %v", got)
+	}
+
+	// 11 committed after 12 while the connection was lost.
+	c.reread()
+	if c.from != 10 {
+		t.Errorf("expected a catch-up after the start, 10, but got %d", c.from)
+	}
+	if got := ids(c.fresh(messages(11, 12, 13))); !reflect.DeepEqual(got, []int64{11, 13}) {
+		t.Errorf("expected only the missed 11 and 13 but got %v", got)
+	}
+	c.caughtUp()
+	if c.from != -1 {
+		t.Errorf("expected no catch-up to be due but got %d", c.from)
+	}
+
+	// The lookup of 2015 failed after 2020 was published.
+	c.fresh(messages(2020))
+	c.behind(2014)
+	c.reread()
+	if c.from != 1020 {
+		t.Errorf("expected the catch-up to start at the window, 1020, but got %d", c.from)
+	}
+	c.caughtUp()
+	if len(c.seen) != 1 || !c.seen[2020] {
+		t.Errorf("expected the events below the window to be forgotten but got %v", c.seen)
+	}
+}
+
+// testHoldEvents checks that holds and holdings write their events to
+// the outbox, like the changes of balances do.
+func testHoldEvents(t *testing.T, p *Postgres) {
+	actor := wallet.Actor{Name: "test"}
+	w, err := p.CreateWallet(wallet.CreateWallet{UserID: 1, WalletType: wallet.TypeCrypto, Balance: 100}, actor)
+	if err != nil {
+		t.Fatalf("unable to create wallet: %v", err)
+	}
+	events := func() []string {
+		t.Helper()
+		rows, err := p.Db.Query("SELECT event_type FROM outbox WHERE wallet_id = $1 ORDER BY id", w.ID)
+		if err != nil {
+			t.Fatalf("unable to read outbox: %v", err)
+		}
+		defer rows.Close()
+		var types []string
+		for rows.Next() {
+			var eventType string
+			rows.Scan(&eventType)
+			types = append(types, eventType)
+		}
+		return types
+	}
+
+	h, err := p.PlaceHold(w.ID, hold.PlaceHold{Amount: 25}, time.Now().Add(time.Hour), actor)
+	if err != nil {
+		t.Fatalf("unable to place hold: %v", err)
+	}
+	if _, err := p.VoidHold(h.ID, actor); err != nil {
+		t.Fatalf("unable to void hold: %v", err)
+	}
+	// A hold placed already expired is not counted, so only its expiry
+	// by the job is seen.
+	if _, err := p.PlaceHold(w.ID, hold.PlaceHold{Amount: 10}, time.Now().Add(-time.Second), actor); err != nil {
+		t.Fatalf("unable to place hold: %v", err)
+	}
+	if n, err := p.ExpireHolds(time.Now(), actor); err != nil || n != 1 {
+		t.Fatalf("expected 1 hold to expire but got %d, %v", n, err)
+	}
+	if _, err := p.CreateHolding(w.ID, asset.CreateHolding{Asset: "BTC", Address: "bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq"}, actor); err != nil {
+		t.Fatalf("unable to create holding: %v", err)
+	}
+	if _, err := p.AdjustHolding(w.ID, "BTC", big.NewInt(12345), "deposit", actor); err != nil {
+		t.Fatalf("unable to adjust holding: %v", err)
+	}
+
+	want := []string{webhook.EventWalletCreated,
+		webhook.EventWalletBalanceChanged, webhook.EventWalletBalanceChanged, webhook.EventWalletBalanceChanged,
+		webhook.EventWalletHoldingChanged, webhook.EventWalletHoldingChanged}
+	if got := events(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected events %v but got %v", want, got)
+	}
+}
+
+// testLateFees checks that the late fee of a frozen card waits until it
+// is active again and that the one of a closed card is waived.
+func testLateFees(t *testing.T, p *Postgres) {
+	actor := wallet.Actor{Name: "test"}
+	limit := 1000.0
+	period := statement.PeriodOf(time.Now().AddDate(0, -3, 0))
+	card := func(userID int) wallet.Wallet {
+		t.Helper()
+		w, err := p.CreateWallet(wallet.CreateWallet{UserID: userID, WalletType: wallet.TypeCreditCard, CreditLimit: &limit}, actor)
+		if err != nil {
+			t.Fatalf("unable to create wallet: %v", err)
+		}
+		_, err = p.Db.Exec("INSERT INTO card_statement(wallet_id, period, period_start, period_end, opening_balance, closing_balance, minimum_payment, due_date) "+
+			"VALUES($1, $2, $3, $4, 0, -500, 25, $5)", w.ID, period.Key(), period.Start, period.End, period.DueDate())
+		if err != nil {
+			t.Fatalf("unable to insert statement: %v", err)
+		}
+		return w
+	}
+	overdue := func() []statement.Statement {
+		t.Helper()
+		overdue, err := p.OverdueStatements(time.Now())
+		if err != nil {
+			t.Fatalf("unable to read overdue statements: %v", err)
+		}
+		return overdue
+	}
+	balance := func(userID int) float64 {
+		t.Helper()
+		w, err := p.WalletByUser(userID)
+		if err != nil {
+			t.Fatalf("unable to read wallet: %v", err)
+		}
+		return w.Balance
+	}
+
+	frozen := card(1)
+	closed := card(2)
+	if _, err := p.ChangeWalletStatus(frozen.ID, wallet.ChangeStatus{Status: wallet.StatusFrozen}, actor); err != nil {
+		t.Fatalf("unable to freeze wallet: %v", err)
+	}
+	if _, err := p.ChangeWalletStatus(closed.ID, wallet.ChangeStatus{Status: wallet.StatusClosed}, actor); err != nil {
+		t.Fatalf("unable to close wallet: %v", err)
+	}
+
+	due := overdue()
+	if len(due) != 1 || due[0].WalletID != closed.ID {
+		t.Fatalf("expected only the statement of the closed card to be overdue but got %+v", due)
+	}
+	if err := p.AssessLateFee(due[0]); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The card may be frozen between listing and assessing.
+	if err := p.AssessLateFee(statement.Statement{WalletID: frozen.ID, Period: period.Key()}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := balance(1); got != 0 {
+		t.Errorf("expected no fee on the frozen card yet but got a balance of %v", got)
+	}
+	if got := balance(2); got != 0 {
+		t.Errorf("expected the fee on the closed card to be waived but got a balance of %v", got)
+	}
+
+	if _, err := p.ChangeWalletStatus(frozen.ID, wallet.ChangeStatus{Status: wallet.StatusActive}, actor); err != nil {
+		t.Fatalf("unable to unfreeze wallet: %v", err)
+	}
+	due = overdue()
+	if len(due) != 1 || due[0].WalletID != frozen.ID {
+		t.Fatalf("expected the statement of the unfrozen card to be overdue but got %+v", due)
+	}
+	if err := p.AssessLateFee(due[0]); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := balance(1); got != -statement.LateFee {
+		t.Errorf("expected the late fee once the card is active but got a balance of %v", got)
+	}
+	if due := overdue(); len(due) != 0 {
+		t.Errorf("expected no overdue statements left but got %+v", due)
+	}
+}
+
+// testRelayStuckWallet checks that the messages queued behind a failed
+// one are left out of the batch, so that other wallets still get theirs.
+func testRelayStuckWallet(t *testing.T, p *Postgres) {
+	insert := func(walletID, attempts int) {
+		t.Helper()
+		_, err := p.Db.Exec("INSERT INTO outbox(event_id, wallet_id, event_type, payload, attempts) VALUES('evt_' || gen_random_uuid(), $1, 'wallet.updated', '{}', $2)",
+			walletID, attempts)
+		if err != nil {
+			t.Fatalf("unable to insert message: %v", err)
+		}
+	}
+	insert(1, 1)
+	for i := 0; i < 100; i++ {
+		insert(1, 0)
+	}
+	insert(2, 0)
+
+	var got []int
+	n, err := p.RelayOutbox(100, func(messages []outbox.Message) []outbox.Message {
+		for _, m := range messages {
+			got = append(got, m.WalletID)
+		}
+		return nil
+	})
+	if err != nil || n != 2 || !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Errorf("expected the failed message of wallet 1 and the one of wallet 2 but got %v, %d, %v", got, n, err)
+	}
+}
+
+// testTransferAudit checks that scheduling a transfer is audited and
+// that it needs both wallets.
+func testTransferAudit(t *testing.T, p *Postgres) {
+	actor := wallet.Actor{Name: "test"}
+	from, err := p.CreateWallet(wallet.CreateWallet{UserID: 1, WalletType: wallet.TypeSavings, Balance: 100}, actor)
+	if err != nil {
+		t.Fatalf("unable to create wallet: %v", err)
+	}
+	to, err := p.CreateWallet(wallet.CreateWallet{UserID: 2, WalletType: wallet.TypeSavings}, actor)
+	if err != nil {
+		t.Fatalf("unable to create wallet: %v", err)
+	}
+	create := transfer.CreateTransfer{FromWalletID: from.ID, ToWalletID: to.ID, Amount: 10, Description: "rent"}
+
+	tr, err := p.CreateTransfer(create, time.Now().Add(time.Hour), actor)
+	if err != nil {
+		t.Fatalf("unable to create transfer: %v", err)
+	}
+	entries, err := p.WalletAudit(from.ID)
+	if err != nil || len(entries) != 2 || entries[1].Action != wallet.ActionTransfer || entries[1].Reason != "rent" {
+		t.Errorf("expected the transfer %d to be audited but got %+v, %v", tr.ID, entries, err)
+	}
+
+	if err := p.DeleteWallet(2, actor); err != nil {
+		t.Fatalf("unable to delete wallet: %v", err)
+	}
+	if _, err := p.CreateTransfer(create, time.Now().Add(time.Hour), actor); !errors.Is(err, wallet.ErrNotFound) {
+		t.Errorf("expected a transfer to a deleted wallet to be rejected but got %v", err)
+	}
+}
+
+// testSweepBuckets checks that a bucket is deleted once it has refilled.
+func testSweepBuckets(t *testing.T, p *Postgres) {
+	limit := ratelimit.Limit{Rate: 1, Burst: 10}
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := p.TakeToken("ip:192.0.2.1", limit, now); err != nil {
+			t.Fatalf("unable to take token: %v", err)
+		}
+	}
+
+	if n, err := p.SweepBuckets(now.Add(2 * time.Second)); err != nil || n != 0 {
+		t.Errorf("expected a bucket still refilling to be kept but got %d, %v", n, err)
+	}
+	if n, err := p.SweepBuckets(now.Add(3 * time.Second)); err != nil || n != 1 {
+		t.Errorf("expected the refilled bucket to be deleted but got %d, %v", n, err)
+	}
+}
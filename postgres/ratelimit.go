@@ -0,0 +1,49 @@
+package postgres
+
+import (
+	"time"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/ratelimit"
+)
+
+// TakeToken implements ratelimit.Store. The bucket row is locked for the
+// duration of the refill so concurrent requests from every replica are
+// serialised on it.
+func (p *Postgres) TakeToken(key string, limit ratelimit.Limit, now time.Time) (ratelimit.Result, error) {
+	tx, err := p.Db.Begin()
+	if err != nil {
+		return ratelimit.Result{}, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("INSERT INTO rate_limit_bucket(key, tokens, updated_at, full_at) VALUES($1,$2,$3,$3) "+
+		"ON CONFLICT (key) DO NOTHING", key, limit.Burst, now)
+	if err != nil {
+		return ratelimit.Result{}, err
+	}
+	var tokens float64
+	var last time.Time
+	err = tx.QueryRow("SELECT tokens, updated_at FROM rate_limit_bucket WHERE key = $1 FOR UPDATE", key).
+		Scan(&tokens, &last)
+	if err != nil {
+		return ratelimit.Result{}, err
+	}
+
+	tokens, result := ratelimit.Take(ratelimit.Refill(tokens, last, now, limit), limit)
+	_, err = tx.Exec("UPDATE rate_limit_bucket SET tokens = $1, updated_at = $2, full_at = $3 WHERE key = $4",
+		tokens, now, now.Add(result.Reset), key)
+	if err != nil {
+		return ratelimit.Result{}, err
+	}
+	return result, tx.Commit()
+}
+
+// SweepBuckets implements ratelimit.Sweeper.
+func (p *Postgres) SweepBuckets(now time.Time) (int, error) {
+	res, err := p.Db.Exec("DELETE FROM rate_limit_bucket WHERE full_at <= $1", now)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
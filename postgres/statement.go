@@ -0,0 +1,193 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/ledger"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/statement"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet"
+)
+
+const statementColumns = "wallet_id, period, period_start, period_end, opening_balance, closing_balance, " +
+	"minimum_payment, due_date, late_fee, created_at"
+
+func scanStatement(row scanner) (statement.Statement, error) {
+	var s statement.Statement
+	err := row.Scan(&s.WalletID, &s.Period, &s.PeriodStart, &s.PeriodEnd, &s.OpeningBalance, &s.ClosingBalance,
+		&s.MinimumPayment, &s.DueDate, &s.LateFee, &s.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return s, statement.ErrNotFound
+	}
+	return s, err
+}
+
+func collectStatements(rows *sql.Rows, err error) ([]statement.Statement, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	statements := []statement.Statement{}
+	for rows.Next() {
+		s, err := scanStatement(rows)
+		if err != nil {
+			return nil, err
+		}
+		statements = append(statements, s)
+	}
+	return statements, rows.Err()
+}
+
+func (p *Postgres) Statements(walletID int) ([]statement.Statement, error) {
+	return collectStatements(p.conn().Query("SELECT "+statementColumns+" FROM card_statement "+
+		"WHERE wallet_id = $1 ORDER BY period_start DESC", walletID))
+}
+
+func (p *Postgres) Statement(walletID int, period string) (statement.Statement, error) {
+	s, err := scanStatement(p.conn().QueryRow("SELECT "+statementColumns+" FROM card_statement "+
+		"WHERE wallet_id = $1 AND period = $2", walletID, period))
+	if err != nil {
+		return s, err
+	}
+	rows, err := p.conn().Query("SELECT "+transactionColumns+" FROM wallet_transaction "+
+		"WHERE wallet_id = $1 AND created_at >= $2 AND created_at < $3 ORDER BY id",
+		walletID, s.PeriodStart, s.PeriodEnd)
+	if err != nil {
+		return s, err
+	}
+	defer rows.Close()
+
+	s.Transactions = []ledger.Transaction{}
+	for rows.Next() {
+		t, err := scanTransaction(rows)
+		if err != nil {
+			return s, err
+		}
+		s.Transactions = append(s.Transactions, t)
+	}
+	return s, rows.Err()
+}
+
+func (p *Postgres) StatementAccounts() ([]statement.Account, error) {
+	rows, err := p.conn().Query("SELECT w.id, w.created_at, MAX(s.period_end) FROM user_wallet w " +
+		"LEFT JOIN card_statement s ON s.wallet_id = w.id " +
+		"WHERE w.wallet_type = 'Credit Card' AND w.deleted_at IS NULL AND w.status <> 'closed' " +
+		"GROUP BY w.id ORDER BY w.id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accounts := []statement.Account{}
+	for rows.Next() {
+		var a statement.Account
+		var createdAt time.Time
+		var lastEnd sql.NullTime
+		if err := rows.Scan(&a.WalletID, &createdAt, &lastEnd); err != nil {
+			return nil, err
+		}
+		a.Next = statement.PeriodOf(createdAt)
+		if lastEnd.Valid {
+			a.Next = statement.PeriodOf(lastEnd.Time)
+		}
+		accounts = append(accounts, a)
+	}
+	return accounts, rows.Err()
+}
+
+func (p *Postgres) GenerateStatement(walletID int, period statement.Period) (statement.Statement, error) {
+	var s statement.Statement
+	err := p.inTx(func(tx *sql.Tx) error {
+		// Locking the wallet keeps postings out while the balances are
+		// worked back from the current balance through the ledger.
+		w, err := lockWallet(tx, walletID)
+		if err != nil {
+			return err
+		}
+		var during, since float64
+		err = tx.QueryRow("SELECT COALESCE(SUM(amount) FILTER (WHERE created_at < $3), 0), "+
+			"COALESCE(SUM(amount) FILTER (WHERE created_at >= $3), 0) "+
+			"FROM wallet_transaction WHERE wallet_id = $1 AND created_at >= $2",
+			walletID, period.Start, period.End).Scan(&during, &since)
+		if err != nil {
+			return err
+		}
+		closing := ledger.Round(w.Balance - since)
+		opening := ledger.Round(closing - during)
+		_, err = tx.Exec("INSERT INTO card_statement(wallet_id, period, period_start, period_end, "+
+			"opening_balance, closing_balance, minimum_payment, due_date) VALUES($1,$2,$3,$4,$5,$6,$7,$8) "+
+			"ON CONFLICT (wallet_id, period) DO NOTHING",
+			walletID, period.Key(), period.Start, period.End, opening, closing,
+			statement.MinimumPayment(closing), period.DueDate())
+		if err != nil {
+			return err
+		}
+		s, err = scanStatement(tx.QueryRow("SELECT "+statementColumns+" FROM card_statement "+
+			"WHERE wallet_id = $1 AND period = $2", walletID, period.Key()))
+		return err
+	})
+	return s, err
+}
+
+func (p *Postgres) OverdueStatements(now time.Time) ([]statement.Statement, error) {
+	return collectStatements(p.conn().Query("SELECT "+statementColumns+" FROM card_statement "+
+		"WHERE due_date < $1 AND minimum_payment > 0 AND late_fee_assessed_at IS NULL "+
+		"AND wallet_id IN (SELECT id FROM user_wallet WHERE deleted_at IS NULL AND status <> $2) ORDER BY due_date",
+		now, wallet.StatusFrozen))
+}
+
+func (p *Postgres) AssessLateFee(s statement.Statement) error {
+	return p.inTx(func(tx *sql.Tx) error {
+		s, err := scanStatement(tx.QueryRow("SELECT "+statementColumns+" FROM card_statement "+
+			"WHERE wallet_id = $1 AND period = $2 AND late_fee_assessed_at IS NULL FOR UPDATE", s.WalletID, s.Period))
+		if errors.Is(err, statement.ErrNotFound) {
+			// Assessed by another run in the meantime.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// A frozen card takes the fee once it is active again. On a
+		// closed card it is waived rather than left to fail on every run.
+		w, err := lockWallet(tx, s.WalletID)
+		if err != nil {
+			return err
+		}
+		switch w.Status {
+		case wallet.StatusFrozen:
+			return nil
+		case wallet.StatusClosed:
+			_, err = tx.Exec("UPDATE card_statement SET late_fee = 0, late_fee_assessed_at = now() WHERE wallet_id = $1 AND period = $2",
+				s.WalletID, s.Period)
+			return err
+		}
+		var paid float64
+		err = tx.QueryRow("SELECT COALESCE(SUM(amount), 0) FROM wallet_transaction "+
+			"WHERE wallet_id = $1 AND amount > 0 AND created_at >= $2 AND created_at < $3",
+			s.WalletID, s.PeriodEnd, s.DueDate).Scan(&paid)
+		if err != nil {
+			return err
+		}
+		fee := 0.0
+		if s.LateFeeDue(paid) {
+			fee = statement.LateFee
+			_, err = postTransaction(tx, ledger.PostTransaction{
+				WalletID:       s.WalletID,
+				Amount:         -fee,
+				Kind:           ledger.KindFee,
+				Description:    fmt.Sprintf("Late fee: minimum payment of %.2f for %s not received by %s", s.MinimumPayment, s.Period, s.DueDate.Format(time.DateOnly)),
+				Reference:      statement.LateFeeReference(s.WalletID, s.Period),
+				AllowOverLimit: true,
+			}, wallet.Actor{Name: "system:statement"})
+			if err != nil && !errors.Is(err, ledger.ErrDuplicate) {
+				return err
+			}
+		}
+		_, err = tx.Exec("UPDATE card_statement SET late_fee = $1, late_fee_assessed_at = now() WHERE wallet_id = $2 AND period = $3",
+			fee, s.WalletID, s.Period)
+		return err
+	})
+}
@@ -0,0 +1,173 @@
+package postgres
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/outbox"
+	"github.com/lib/pq"
+)
+
+// walletEventsChannel is notified with the id of every outbox message
+// as it commits, by the outbox_notify trigger.
+const walletEventsChannel = "wallet_events"
+
+const catchUpBatch = 500
+
+func (p *Postgres) WalletEventsSince(afterID int64, userID int, limit int) ([]outbox.Message, error) {
+	rows, err := p.conn().Query("SELECT "+outboxColumns+" FROM outbox WHERE id > $1 "+
+		"AND ($2 = 0 OR (payload->'data'->'wallet'->>'user_id')::int = $2) ORDER BY id LIMIT $3",
+		afterID, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return collectMessages(rows)
+}
+
+// ListenWalletEvents calls publish with every wallet event committed by
+// any replica until ctx is done. Events committed while the connection
+// was lost, or whose lookup failed, are published once it is back.
+func (p *Postgres) ListenWalletEvents(ctx context.Context, publish func(message outbox.Message)) error {
+	listener := pq.NewListener(p.dsn, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("listen %s: %v", walletEventsChannel, err)
+		}
+	})
+	defer listener.Close()
+	if err := listener.Listen(walletEventsChannel); err != nil {
+		return err
+	}
+
+	var lastID int64
+	if err := p.Db.QueryRow("SELECT COALESCE(MAX(id), 0) FROM outbox").Scan(&lastID); err != nil {
+		return err
+	}
+	cursor := newEventCursor(lastID)
+	catchUp := func() {
+		if cursor.from < 0 {
+			return
+		}
+		messages, err := p.walletEventsAfter(cursor.from)
+		for _, m := range cursor.fresh(messages) {
+			publish(m)
+		}
+		if err != nil {
+			// Tried again on the next ping.
+			log.Printf("listen %s: %v", walletEventsChannel, err)
+			return
+		}
+		cursor.caughtUp()
+	}
+
+	ping := time.NewTicker(time.Minute)
+	defer ping.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ping.C:
+			go listener.Ping()
+			catchUp()
+		case n := <-listener.Notify:
+			if n == nil {
+				// The connection was re-established; catch up on what
+				// committed meanwhile.
+				cursor.reread()
+				catchUp()
+				continue
+			}
+			id, err := strconv.ParseInt(n.Extra, 10, 64)
+			if err != nil {
+				log.Printf("listen %s: %v", walletEventsChannel, err)
+				cursor.reread()
+				continue
+			}
+			m, err := scanMessage(p.Db.QueryRow("SELECT "+outboxColumns+" FROM outbox WHERE id = $1", id))
+			if err != nil {
+				log.Printf("listen %s: event %d: %v", walletEventsChannel, id, err)
+				cursor.behind(id - 1)
+				continue
+			}
+			for _, m := range cursor.fresh([]outbox.Message{m}) {
+				publish(m)
+			}
+		}
+	}
+}
+
+// catchUpWindow is how far below the highest id seen a catch-up reads.
+// Outbox ids are taken when an event is written, not when it commits,
+// so an event can commit after one with a higher id was published.
+const catchUpWindow = 1000
+
+// eventCursor tracks the events a listener has published, so that a
+// catch-up can re-read a window of them and publish only the missed.
+type eventCursor struct {
+	seen   map[int64]bool
+	lastID int64
+	// startID is the highest id when listening started; events up to it
+	// were not this listener's to publish.
+	startID int64
+	// from is the id the next catch-up reads after, or -1 if none is due.
+	from int64
+}
+
+func newEventCursor(lastID int64) *eventCursor {
+	return &eventCursor{seen: map[int64]bool{}, lastID: lastID, startID: lastID, from: -1}
+}
+
+// fresh marks messages as seen and returns those that were not.
+func (c *eventCursor) fresh(messages []outbox.Message) []outbox.Message {
+	var unseen []outbox.Message
+	for _, m := range messages {
+		if c.seen[m.ID] {
+			continue
+		}
+		c.seen[m.ID] = true
+		c.lastID = max(c.lastID, m.ID)
+		unseen = append(unseen, m)
+	}
+	return unseen
+}
+
+// reread asks for a catch-up of the window below the highest id seen.
+func (c *eventCursor) reread() {
+	c.behind(max(c.lastID-catchUpWindow, c.startID))
+}
+
+// behind asks for a catch-up of the events after id.
+func (c *eventCursor) behind(id int64) {
+	id = max(id, 0)
+	if c.from < 0 || id < c.from {
+		c.from = id
+	}
+}
+
+// caughtUp records a complete catch-up and forgets the events that the
+// next one will not read again.
+func (c *eventCursor) caughtUp() {
+	c.from = -1
+	for id := range c.seen {
+		if id <= c.lastID-catchUpWindow {
+			delete(c.seen, id)
+		}
+	}
+}
+
+// walletEventsAfter returns every event after id.
+func (p *Postgres) walletEventsAfter(id int64) ([]outbox.Message, error) {
+	var messages []outbox.Message
+	for {
+		batch, err := p.WalletEventsSince(id, 0, catchUpBatch)
+		if err != nil {
+			return messages, err
+		}
+		messages = append(messages, batch...)
+		if len(batch) < catchUpBatch {
+			return messages, nil
+		}
+		id = batch[len(batch)-1].ID
+	}
+}
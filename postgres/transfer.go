@@ -0,0 +1,215 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/ledger"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/transfer"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet"
+)
+
+const transferColumns = "id, from_wallet_id, to_wallet_id, amount, description, recurrence, start_at, next_run_at, " +
+	"status, attempts, last_run_at, last_error, created_by, created_at"
+
+func scanTransfer(row scanner) (transfer.Transfer, error) {
+	var t transfer.Transfer
+	err := row.Scan(&t.ID, &t.FromWalletID, &t.ToWalletID, &t.Amount, &t.Description, &t.Recurrence, &t.StartAt,
+		&t.NextRunAt, &t.Status, &t.Attempts, &t.LastRunAt, &t.LastError, &t.CreatedBy, &t.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return t, transfer.ErrNotFound
+	}
+	return t, err
+}
+
+func (p *Postgres) WalletTransfers(walletID int) ([]transfer.Transfer, error) {
+	rows, err := p.conn().Query("SELECT "+transferColumns+" FROM scheduled_transfer "+
+		"WHERE from_wallet_id = $1 OR to_wallet_id = $1 ORDER BY id", walletID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transfers := []transfer.Transfer{}
+	for rows.Next() {
+		t, err := scanTransfer(rows)
+		if err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, t)
+	}
+	return transfers, rows.Err()
+}
+
+func (p *Postgres) Transfer(transferID int) (transfer.Transfer, error) {
+	return scanTransfer(p.conn().QueryRow("SELECT "+transferColumns+" FROM scheduled_transfer WHERE id = $1", transferID))
+}
+
+func (p *Postgres) CreateTransfer(createTransfer transfer.CreateTransfer, firstRun time.Time, actor wallet.Actor) (transfer.Transfer, error) {
+	var result transfer.Transfer
+	err := p.inTx(func(tx *sql.Tx) error {
+		// Locking both wallets, in id order like postTransfer, keeps them
+		// from being deleted before the transfer is saved.
+		first, second := createTransfer.FromWalletID, createTransfer.ToWalletID
+		if first > second {
+			first, second = second, first
+		}
+		for _, id := range []int{first, second} {
+			if _, err := lockWallet(tx, id); err != nil {
+				return err
+			}
+		}
+		var err error
+		result, err = scanTransfer(tx.QueryRow("INSERT INTO scheduled_transfer"+
+			"(from_wallet_id, to_wallet_id, amount, description, recurrence, start_at, next_run_at, created_by) "+
+			"VALUES($1,$2,$3,$4,$5,$6,$7,$8) RETURNING "+transferColumns,
+			createTransfer.FromWalletID, createTransfer.ToWalletID, createTransfer.Amount, createTransfer.Description,
+			createTransfer.Recurrence, firstRun, firstRun, actor.Name))
+		if err != nil {
+			return err
+		}
+		return insertAudit(tx, actor, wallet.ActionTransfer, result.FromWalletID, nil, &result, result.Description)
+	})
+	return result, err
+}
+
+func (p *Postgres) CancelTransfer(transferID int) (transfer.Transfer, error) {
+	t, err := scanTransfer(p.conn().QueryRow("UPDATE scheduled_transfer SET status = $1, next_run_at = NULL "+
+		"WHERE id = $2 AND status = $3 RETURNING "+transferColumns,
+		transfer.StatusCancelled, transferID, transfer.StatusActive))
+	if errors.Is(err, transfer.ErrNotFound) {
+		if _, err := p.Transfer(transferID); err != nil {
+			return t, err
+		}
+		return t, transfer.ErrNotActive
+	}
+	return t, err
+}
+
+func (p *Postgres) TransferRuns(transferID int) ([]transfer.Run, error) {
+	rows, err := p.conn().Query("SELECT id, transfer_id, scheduled_for, attempt, succeeded, error, created_at "+
+		"FROM transfer_run WHERE transfer_id = $1 ORDER BY id DESC", transferID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []transfer.Run{}
+	for rows.Next() {
+		var r transfer.Run
+		if err := rows.Scan(&r.ID, &r.TransferID, &r.ScheduledFor, &r.Attempt, &r.Succeeded, &r.Error, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		runs = append(runs, r)
+	}
+	return runs, rows.Err()
+}
+
+func (p *Postgres) ExecuteDueTransfer(now time.Time) (transfer.Run, error) {
+	var run transfer.Run
+	err := p.inTx(func(tx *sql.Tx) error {
+		// SKIP LOCKED lets concurrent schedulers claim different
+		// transfers instead of queueing behind the same one.
+		t, err := scanTransfer(tx.QueryRow("SELECT "+transferColumns+" FROM scheduled_transfer "+
+			"WHERE status = $1 AND next_run_at <= $2 ORDER BY next_run_at LIMIT 1 FOR UPDATE SKIP LOCKED",
+			transfer.StatusActive, now))
+		if errors.Is(err, transfer.ErrNotFound) {
+			return transfer.ErrNoneDue
+		}
+		if err != nil {
+			return err
+		}
+
+		// The savepoint undoes a failed run's partial postings while
+		// keeping the claim, so the failure can be recorded.
+		if _, err := tx.Exec("SAVEPOINT transfer_run"); err != nil {
+			return err
+		}
+		runErr := executeTransfer(tx, t)
+		if runErr != nil {
+			if _, err := tx.Exec("ROLLBACK TO SAVEPOINT transfer_run"); err != nil {
+				return err
+			}
+		}
+
+		run = transfer.Run{TransferID: t.ID, ScheduledFor: *t.NextRunAt, Attempt: t.Attempts + 1, Succeeded: runErr == nil}
+		if runErr != nil {
+			run.Error = runErr.Error()
+		}
+		err = tx.QueryRow("INSERT INTO transfer_run(transfer_id, scheduled_for, attempt, succeeded, error) "+
+			"VALUES($1,$2,$3,$4,$5) RETURNING id, created_at",
+			run.TransferID, run.ScheduledFor, run.Attempt, run.Succeeded, run.Error).Scan(&run.ID, &run.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		next, err := t.Advance(now, runErr)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("UPDATE scheduled_transfer SET next_run_at = $1, status = $2, attempts = $3, "+
+			"last_run_at = $4, last_error = $5 WHERE id = $6",
+			next.NextRunAt, next.Status, next.Attempts, next.LastRunAt, next.LastError, t.ID)
+		return err
+	})
+	return run, err
+}
+
+// executeTransfer posts both legs of the run of t due at NextRunAt.
+func executeTransfer(tx *sql.Tx, t transfer.Transfer) error {
+	actor := wallet.Actor{Name: "scheduled-transfer:" + strconv.Itoa(t.ID)}
+	fromRef, toRef := transfer.References(t.ID, *t.NextRunAt)
+	_, err := postTransfer(tx, ledger.PostTransaction{
+		WalletID:    t.FromWalletID,
+		Amount:      -t.Amount,
+		Kind:        ledger.KindTransfer,
+		Description: t.Description,
+		Reference:   fromRef,
+	}, ledger.PostTransaction{
+		WalletID:    t.ToWalletID,
+		Amount:      t.Amount,
+		Kind:        ledger.KindTransfer,
+		Description: t.Description,
+		Reference:   toRef,
+	}, actor)
+	return err
+}
+
+// TransferFunds moves money between two wallets at once.
+func (p *Postgres) TransferFunds(t ledger.Transfer, actor wallet.Actor) ([]ledger.Transaction, error) {
+	var result []ledger.Transaction
+	err := p.inTx(func(tx *sql.Tx) error {
+		debit, credit := t.Legs()
+		var err error
+		result, err = postTransfer(tx, debit, credit, actor)
+		return err
+	})
+	return result, err
+}
+
+// postTransfer posts the debit and the credit of a transfer in tx.
+func postTransfer(tx *sql.Tx, debit, credit ledger.PostTransaction, actor wallet.Actor) ([]ledger.Transaction, error) {
+	// Lock both wallets in id order so two transfers between the same
+	// wallets in opposite directions cannot deadlock.
+	first, second := debit.WalletID, credit.WalletID
+	if first > second {
+		first, second = second, first
+	}
+	for _, id := range []int{first, second} {
+		if _, err := lockWallet(tx, id); err != nil {
+			return nil, err
+		}
+	}
+
+	from, err := postTransaction(tx, debit, actor)
+	if err != nil {
+		return nil, err
+	}
+	to, err := postTransaction(tx, credit, actor)
+	if err != nil {
+		return nil, err
+	}
+	return []ledger.Transaction{from, to}, nil
+}
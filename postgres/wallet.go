@@ -1,176 +1,390 @@
 package postgres
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/KKGo-Software-engineering/fun-exercise-api/ledger"
 	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet"
+	"github.com/lib/pq"
 )
 
-type Wallet struct {
-	ID         int       `postgres:"id"`
-	UserID     int       `postgres:"user_id"`
-	UserName   string    `postgres:"user_name"`
-	WalletName string    `postgres:"wallet_name"`
-	WalletType string    `postgres:"wallet_type"`
-	Balance    float64   `postgres:"balance"`
-	CreatedAt  time.Time `postgres:"created_at"`
-}
+// walletColumns ends with the funds held by active holds, which may be
+// selected from user_wallet only, or returned by statements on it.
+const walletColumns = "id, user_id, user_name, wallet_name, wallet_type, balance, credit_limit, status, created_at, deleted_at, " +
+	"(SELECT COALESCE(SUM(h.amount), 0) FROM wallet_hold h " +
+	"WHERE h.wallet_id = user_wallet.id AND h.status = 'active' AND h.expires_at > now())"
 
-func (p *Postgres) Wallets() ([]wallet.Wallet, error) {
-	rows, err := p.Db.Query("SELECT * FROM user_wallet")
-	if err != nil {
-		return nil, err
+func scanWallet(row scanner) (wallet.Wallet, error) {
+	var w wallet.Wallet
+	err := row.Scan(&w.ID,
+		&w.UserID, &w.UserName,
+		&w.WalletName, &w.WalletType,
+		&w.Balance, &w.CreditLimit, &w.Status, &w.CreatedAt, &w.DeletedAt, &w.Held,
+	)
+	w.Derive()
+	if errors.Is(err, sql.ErrNoRows) {
+		return w, wallet.ErrNotFound
 	}
+	return w, err
+}
+
+// collectWallets scans and closes rows.
+func collectWallets(rows *sql.Rows) ([]wallet.Wallet, error) {
 	defer rows.Close()
 
-	var wallets []wallet.Wallet
+	wallets := []wallet.Wallet{}
 	for rows.Next() {
-		var w Wallet
-		err := rows.Scan(&w.ID,
-			&w.UserID, &w.UserName,
-			&w.WalletName, &w.WalletType,
-			&w.Balance, &w.CreatedAt,
-		)
+		w, err := scanWallet(rows)
 		if err != nil {
 			return nil, err
 		}
-		wallets = append(wallets, wallet.Wallet{
-			ID:         w.ID,
-			UserID:     w.UserID,
-			UserName:   w.UserName,
-			WalletName: w.WalletName,
-			WalletType: w.WalletType,
-			Balance:    w.Balance,
-			CreatedAt:  w.CreatedAt,
-		})
+		wallets = append(wallets, w)
 	}
-	return wallets, nil
+	return wallets, rows.Err()
 }
 
-func (p *Postgres) WalletsByType(walletType string) ([]wallet.Wallet, error) {
+// walletsQuery selects the wallets matching filter, ordered by id.
+func walletsQuery(filter wallet.Filter) (string, []any) {
+	sqlStr := "SELECT " + walletColumns + " FROM user_wallet WHERE ($1 OR deleted_at IS NULL)"
+	args := []any{filter.IncludeDeleted}
+	if filter.WalletType != "" {
+		args = append(args, filter.WalletType)
+		sqlStr += " AND wallet_type = $" + strconv.Itoa(len(args))
+	}
+	if filter.AfterID != 0 {
+		args = append(args, filter.AfterID)
+		sqlStr += " AND id > $" + strconv.Itoa(len(args))
+	}
+	sqlStr += " ORDER BY id"
+	if filter.Limit != 0 {
+		args = append(args, filter.Limit)
+		sqlStr += " LIMIT $" + strconv.Itoa(len(args))
+	}
+	return sqlStr, args
+}
 
-	wallets := []wallet.Wallet{}
+// WalletsByUsers returns the wallets of all of the users in one query,
+// in id order.
+func (p *Postgres) WalletsByUsers(userIDs []int) ([]wallet.Wallet, error) {
+	rows, err := p.conn().Query("SELECT "+walletColumns+" FROM user_wallet WHERE user_id = ANY($1) AND deleted_at IS NULL ORDER BY id",
+		pq.Array(userIDs))
+	if err != nil {
+		return nil, err
+	}
+	return collectWallets(rows)
+}
 
-	rows, err := p.Db.Query("SELECT * FROM user_wallet WHERE wallet_type = $1", walletType)
+func (p *Postgres) Wallets(filter wallet.Filter) ([]wallet.Wallet, error) {
+	sqlStr, args := walletsQuery(filter)
+	rows, err := p.conn().Query(sqlStr, args...)
 	if err != nil {
-		return wallets, nil
+		return nil, err
 	}
-	defer rows.Close()
+	return collectWallets(rows)
+}
 
-	for rows.Next() {
-		var w Wallet
-		err := rows.Scan(&w.ID,
-			&w.UserID, &w.UserName,
-			&w.WalletName, &w.WalletType,
-			&w.Balance, &w.CreatedAt,
-		)
-		if err != nil {
-			return nil, err
+// exportFetchSize is how many rows EachWallet fetches from its cursor at
+// a time, which bounds its memory use however many wallets match.
+const exportFetchSize = 500
+
+// EachWallet calls fn with every wallet matching filter, in id order,
+// reading them through a server-side cursor. It stops at the first
+// error fn returns.
+func (p *Postgres) EachWallet(filter wallet.Filter, fn func(wallet.Wallet) error) error {
+	return p.inTxOnce(func(tx *sql.Tx) error {
+		sqlStr, args := walletsQuery(filter)
+		if _, err := tx.Exec("DECLARE wallet_export NO SCROLL CURSOR FOR "+sqlStr, args...); err != nil {
+			return err
 		}
-		wallets = append(wallets, wallet.Wallet{
-			ID:         w.ID,
-			UserID:     w.UserID,
-			UserName:   w.UserName,
-			WalletName: w.WalletName,
-			WalletType: w.WalletType,
-			Balance:    w.Balance,
-			CreatedAt:  w.CreatedAt,
-		})
-	}
-	return wallets, nil
+		for {
+			rows, err := tx.Query("FETCH FORWARD " + strconv.Itoa(exportFetchSize) + " FROM wallet_export")
+			if err != nil {
+				return err
+			}
+			n := 0
+			for rows.Next() {
+				w, err := scanWallet(rows)
+				if err == nil {
+					err = fn(w)
+				}
+				if err != nil {
+					rows.Close()
+					return err
+				}
+				n++
+			}
+			if err := rows.Close(); err != nil {
+				return err
+			}
+			if err := rows.Err(); err != nil {
+				return err
+			}
+			if n < exportFetchSize {
+				// In the transaction of WithTx the cursor would outlive
+				// the call and keep its name taken.
+				_, err := tx.Exec("CLOSE wallet_export")
+				return err
+			}
+		}
+	})
 }
 
-func (p *Postgres) WalletByUser(userId int) (wallet.Wallet, error) {
+// WalletByUser returns the newest wallet of the user that is not
+// deleted, or wallet.ErrNotFound.
+func (p *Postgres) WalletByUser(userID int) (wallet.Wallet, error) {
+	return scanWallet(p.conn().QueryRow("SELECT "+walletColumns+" FROM user_wallet "+
+		"WHERE user_id = $1 AND deleted_at IS NULL ORDER BY id DESC LIMIT 1", userID))
+}
 
+func (p *Postgres) CreateWallet(createWallet wallet.CreateWallet, actor wallet.Actor) (wallet.Wallet, error) {
 	var result wallet.Wallet
+	sqlStr := "INSERT INTO user_wallet(user_id,user_name,wallet_name,wallet_type,balance,credit_limit) VALUES($1,$2,$3,$4,$5,$6) " +
+		"RETURNING " + walletColumns
+	err := p.inTx(func(tx *sql.Tx) error {
+		var err error
+		result, err = scanWallet(tx.QueryRow(sqlStr, createWallet.UserID, createWallet.UserName,
+			createWallet.WalletName, createWallet.WalletType, createWallet.Balance, createWallet.CreditLimit))
+		if err != nil {
+			return err
+		}
+		if err := insertAudit(tx, actor, wallet.ActionCreate, result.ID, nil, &result, ""); err != nil {
+			return err
+		}
+		return emitWalletEvents(tx, nil, &result)
+	})
+	return result, err
+}
 
-	rows, err := p.Db.Query("SELECT * FROM user_wallet WHERE user_id = $1", userId)
-	if err != nil {
-		return result, nil
-	}
-	defer rows.Close()
+// importBatchSize keeps a batch of inserted wallets and their audit
+// entries well below the 65535 parameters a statement may have.
+const importBatchSize = 500
 
-	for rows.Next() {
-		var w Wallet
-		err := rows.Scan(&w.ID,
-			&w.UserID, &w.UserName,
-			&w.WalletName, &w.WalletType,
-			&w.Balance, &w.CreatedAt,
-		)
+// ImportWallets creates all of the wallets, in order, or none. They are
+// inserted importBatchSize at a time, with their audit entries and
+// events, in one transaction.
+func (p *Postgres) ImportWallets(createWallets []wallet.CreateWallet, actor wallet.Actor) ([]wallet.Wallet, error) {
+	var created []wallet.Wallet
+	err := p.inTx(func(tx *sql.Tx) error {
+		// A retry starts over.
+		created = make([]wallet.Wallet, 0, len(createWallets))
+		for start := 0; start < len(createWallets); start += importBatchSize {
+			batch := createWallets[start:min(start+importBatchSize, len(createWallets))]
+			args := make([]any, 0, len(batch)*6)
+			for _, c := range batch {
+				args = append(args, c.UserID, c.UserName, c.WalletName, c.WalletType, c.Balance, c.CreditLimit)
+			}
+			rows, err := tx.Query("INSERT INTO user_wallet(user_id,user_name,wallet_name,wallet_type,balance,credit_limit) "+
+				"VALUES "+placeholders(len(batch), 6)+" RETURNING "+walletColumns, args...)
+			if err != nil {
+				return err
+			}
+			wallets, err := collectWallets(rows)
+			if err != nil {
+				return err
+			}
+			// Ids are drawn in the order of VALUES; sorting by them lines
+			// the wallets up with the rows of the import.
+			sort.Slice(wallets, func(i, j int) bool { return wallets[i].ID < wallets[j].ID })
+			if err := insertAudits(tx, actor, wallet.ActionCreate, wallets, "import"); err != nil {
+				return err
+			}
+			for i := range wallets {
+				if err := emitWalletEvents(tx, nil, &wallets[i]); err != nil {
+					return err
+				}
+			}
+			created = append(created, wallets...)
+		}
+		return nil
+	})
+	return created, err
+}
+
+// insertAudits records the creation of many wallets in one statement.
+func insertAudits(tx *sql.Tx, actor wallet.Actor, action string, wallets []wallet.Wallet, reason string) error {
+	args := make([]any, 0, len(wallets)*6)
+	for i := range wallets {
+		after, err := auditJSON(&wallets[i])
 		if err != nil {
-			return result, err
+			return err
 		}
-		result = wallet.Wallet{
-			ID:         w.ID,
-			UserID:     w.UserID,
-			UserName:   w.UserName,
-			WalletName: w.WalletName,
-			WalletType: w.WalletType,
-			Balance:    w.Balance,
-			CreatedAt:  w.CreatedAt,
+		args = append(args, actor.Name, action, wallets[i].ID, after, reason, actor.RequestID)
+	}
+	_, err := tx.Exec("INSERT INTO wallet_audit(actor, action, wallet_id, after, reason, request_id) "+
+		"VALUES "+placeholders(len(wallets), 6), args...)
+	return err
+}
+
+// placeholders returns "($1,$2),($3,$4)" for 2 rows of 2 columns.
+func placeholders(rows, columns int) string {
+	var b strings.Builder
+	for r := 0; r < rows; r++ {
+		if r > 0 {
+			b.WriteByte(',')
 		}
+		b.WriteByte('(')
+		for c := 0; c < columns; c++ {
+			if c > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString("$" + strconv.Itoa(r*columns+c+1))
+		}
+		b.WriteByte(')')
 	}
-	return result, nil
+	return b.String()
 }
 
-func (p *Postgres) CreateWallet(createWallet wallet.CreateWallet) (wallet.Wallet, error) {
+// DeleteWallet soft-deletes the wallets of a user. They stay in
+// user_wallet, hidden from reads, until restored or purged.
+func (p *Postgres) DeleteWallet(userID int, actor wallet.Actor) error {
+	return p.inTx(func(tx *sql.Tx) error {
+		rows, err := tx.Query("UPDATE user_wallet SET deleted_at = $1 WHERE user_id = $2 AND deleted_at IS NULL "+
+			"RETURNING "+walletColumns, time.Now(), userID)
+		if err != nil {
+			return err
+		}
+		deleted, err := collectWallets(rows)
+		if err != nil {
+			return err
+		}
+		for _, after := range deleted {
+			before := after
+			before.DeletedAt = nil
+			if err := insertAudit(tx, actor, wallet.ActionDelete, after.ID, &before, &after, ""); err != nil {
+				return err
+			}
+			if err := emitWalletEvents(tx, &before, &after); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (p *Postgres) RestoreWallet(walletID int, actor wallet.Actor) (wallet.Wallet, error) {
 	var result wallet.Wallet
-	sqlStr := "INSERT INTO user_wallet(user_id,user_name,wallet_name,wallet_type,balance) VALUES($1,$2,$3,$4,$5) " +
-		"RETURNING id,user_id,user_name,wallet_name,wallet_type,balance,created_at"
-	rows, err := p.Db.Query(sqlStr, createWallet.UserID, createWallet.UserName,
-		createWallet.WalletName, createWallet.WalletType, createWallet.Balance)
-	fmt.Printf("%v\n", rows)
-	fmt.Printf("%v\n", err)
-	if err != nil {
-		return result, err
-	}
-	defer rows.Close()
-	for rows.Next() {
-		err := rows.Scan(&result.ID,
-			&result.UserID,
-			&result.UserName,
-			&result.WalletName,
-			&result.WalletType,
-			&result.Balance,
-			&result.CreatedAt)
+	err := p.inTx(func(tx *sql.Tx) error {
+		var deletedAt time.Time
+		err := tx.QueryRow("SELECT deleted_at FROM user_wallet WHERE id = $1 AND deleted_at IS NOT NULL FOR UPDATE",
+			walletID).Scan(&deletedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return wallet.ErrNotFound
+		}
 		if err != nil {
-			return result, err
+			return err
 		}
-	}
-	return result, nil
+		result, err = scanWallet(tx.QueryRow("UPDATE user_wallet SET deleted_at = NULL "+
+			"WHERE id = $1 RETURNING "+walletColumns, walletID))
+		if err != nil {
+			return err
+		}
+		if err := insertAudit(tx, actor, wallet.ActionRestore, result.ID, nil, &result, ""); err != nil {
+			return err
+		}
+		before := result
+		before.DeletedAt = &deletedAt
+		return emitWalletEvents(tx, &before, &result)
+	})
+	return result, err
 }
 
-func (p *Postgres) DeleteWallet(userID int) error {
-	_, err := p.Db.Exec("DELETE FROM user_wallet WHERE user_id = $1", userID)
-	if err != nil {
-		return err
-	}
-	return nil
+// PurgeDeletedWallets hard-deletes wallets soft-deleted before the
+// cut-off and returns how many were removed.
+func (p *Postgres) PurgeDeletedWallets(before time.Time, actor wallet.Actor) (int, error) {
+	var purged []wallet.Wallet
+	err := p.inTx(func(tx *sql.Tx) error {
+		rows, err := tx.Query("DELETE FROM user_wallet WHERE deleted_at < $1 RETURNING "+walletColumns, before)
+		if err != nil {
+			return err
+		}
+		purged, err = collectWallets(rows)
+		if err != nil {
+			return err
+		}
+		for _, w := range purged {
+			if err := insertAudit(tx, actor, wallet.ActionPurge, w.ID, &w, nil, ""); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return len(purged), err
 }
 
-func (p *Postgres) UpdateWallet(updateWallet wallet.UpdateWallet) (wallet.Wallet, error) {
+func (p *Postgres) UpdateWallet(updateWallet wallet.UpdateWallet, actor wallet.Actor) (wallet.Wallet, error) {
 	var result wallet.Wallet
 	sqlStr := "UPDATE user_wallet SET user_id=$1, user_name=$2, wallet_name=$3," +
-		"wallet_type=$4, balance=$5, created_at=$6 WHERE id=$7 " +
-		"RETURNING id, user_id, user_name, wallet_name, wallet_type, balance, created_at"
-	rows, err := p.Db.Query(sqlStr, updateWallet.UserID, updateWallet.UserName, updateWallet.WalletName,
-		updateWallet.WalletType, updateWallet.Balance, time.Now(),
-		updateWallet.ID)
-	if err != nil {
-		return result, errors.New("unable to update row")
-	}
-	defer rows.Close()
-	if rows.Next() {
-		err := rows.Scan(&result.ID,
-			&result.UserID,
-			&result.UserName,
-			&result.WalletName,
-			&result.WalletType,
-			&result.Balance,
-			&result.CreatedAt)
-		return result, err
-	}
-	return result, errors.New("unable to update row")
+		"wallet_type=$4, balance=$5, credit_limit=$6, created_at=$7 WHERE id=$8 " +
+		"RETURNING " + walletColumns
+	err := p.inTx(func(tx *sql.Tx) error {
+		before, err := lockWallet(tx, updateWallet.ID)
+		if errors.Is(err, wallet.ErrNotFound) {
+			return fmt.Errorf("unable to update row: %w", err)
+		}
+		if err != nil {
+			return err
+		}
+		after, err := wallet.ApplyUpdate(before, updateWallet)
+		if err != nil {
+			return err
+		}
+		result, err = scanWallet(tx.QueryRow(sqlStr, after.UserID, after.UserName, after.WalletName,
+			after.WalletType, after.Balance, after.CreditLimit, time.Now(),
+			after.ID))
+		if err != nil {
+			return err
+		}
+		if result.Balance != before.Balance {
+			_, err := insertTransaction(tx, ledger.Transaction{
+				WalletID:     result.ID,
+				Amount:       ledger.Round(result.Balance - before.Balance),
+				BalanceAfter: result.Balance,
+				Kind:         ledger.KindAdjustment,
+				Description:  "Balance set by " + actor.Name,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if err := insertAudit(tx, actor, wallet.ActionUpdate, result.ID, &before, &result, ""); err != nil {
+			return err
+		}
+		return emitWalletEvents(tx, &before, &result)
+	})
+	return result, err
+}
+
+func (p *Postgres) ChangeWalletStatus(walletID int, changeStatus wallet.ChangeStatus, actor wallet.Actor) (wallet.Wallet, error) {
+	var result wallet.Wallet
+	err := p.inTx(func(tx *sql.Tx) error {
+		before, err := lockWallet(tx, walletID)
+		if err != nil {
+			return err
+		}
+		if err := wallet.Transition(before, changeStatus.Status); err != nil {
+			return err
+		}
+		result, err = scanWallet(tx.QueryRow("UPDATE user_wallet SET status = $1 WHERE id = $2 RETURNING "+walletColumns,
+			changeStatus.Status, walletID))
+		if err != nil {
+			return err
+		}
+		if err := insertAudit(tx, actor, wallet.ActionStatus, walletID, &before, &result, changeStatus.Reason); err != nil {
+			return err
+		}
+		return emitWalletEvents(tx, &before, &result)
+	})
+	return result, err
+}
+
+// lockWallet reads a wallet that is not deleted and locks its row until
+// tx ends.
+func lockWallet(tx *sql.Tx, walletID int) (wallet.Wallet, error) {
+	return scanWallet(tx.QueryRow("SELECT "+walletColumns+" FROM user_wallet WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", walletID))
 }
@@ -0,0 +1,149 @@
+package postgres
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/outbox"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/webhook"
+	"github.com/lib/pq"
+)
+
+const (
+	subscriptionColumns = "id, url, events, created_at"
+	deliveryColumns     = "id, subscription_id, event_id, event_type, payload, status, attempts, next_attempt_at, " +
+		"last_error, last_status_code, created_at, delivered_at"
+
+	// deliveriesLimit is how many deliveries Deliveries lists.
+	deliveriesLimit = 100
+)
+
+func scanSubscription(row scanner) (webhook.Subscription, error) {
+	var s webhook.Subscription
+	err := row.Scan(&s.ID, &s.URL, pq.Array(&s.Events), &s.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return s, webhook.ErrNotFound
+	}
+	return s, err
+}
+
+func scanDelivery(row scanner, extra ...any) (webhook.Delivery, error) {
+	var d webhook.Delivery
+	var payload []byte
+	err := row.Scan(append([]any{&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &payload, &d.Status, &d.Attempts,
+		&d.NextAttemptAt, &d.LastError, &d.LastStatusCode, &d.CreatedAt, &d.DeliveredAt}, extra...)...)
+	if errors.Is(err, sql.ErrNoRows) {
+		return d, webhook.ErrNotFound
+	}
+	d.Payload = payload
+	return d, err
+}
+
+func (p *Postgres) Subscriptions() ([]webhook.Subscription, error) {
+	rows, err := p.conn().Query("SELECT " + subscriptionColumns + " FROM webhook_subscription ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subscriptions := []webhook.Subscription{}
+	for rows.Next() {
+		s, err := scanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, s)
+	}
+	return subscriptions, rows.Err()
+}
+
+func (p *Postgres) CreateSubscription(createSubscription webhook.CreateSubscription) (webhook.Subscription, error) {
+	row := p.conn().QueryRow("INSERT INTO webhook_subscription(url, events, secret) VALUES($1,$2,$3) RETURNING "+subscriptionColumns,
+		createSubscription.URL, pq.Array(createSubscription.Events), createSubscription.Secret)
+	return scanSubscription(row)
+}
+
+func (p *Postgres) DeleteSubscription(id int) error {
+	res, err := p.conn().Exec("DELETE FROM webhook_subscription WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return webhook.ErrNotFound
+	}
+	return nil
+}
+
+func (p *Postgres) Deliveries(status string) ([]webhook.Delivery, error) {
+	rows, err := p.conn().Query("SELECT "+deliveryColumns+" FROM webhook_delivery WHERE ($1 = '' OR status = $1) "+
+		"ORDER BY id DESC LIMIT $2", status, deliveriesLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []webhook.Delivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+func (p *Postgres) Redeliver(id int) (webhook.Delivery, error) {
+	row := p.conn().QueryRow("UPDATE webhook_delivery SET status = $1, attempts = 0, next_attempt_at = now() "+
+		"WHERE id = $2 RETURNING "+deliveryColumns, webhook.StatusPending, id)
+	return scanDelivery(row)
+}
+
+func (p *Postgres) ClaimDeliveries(now time.Time, limit int, lease time.Duration) ([]webhook.Delivery, error) {
+	// SKIP LOCKED lets concurrent dispatchers claim different deliveries;
+	// pushing next_attempt_at past the lease keeps them claimed after the
+	// transaction commits, while they are being sent.
+	rows, err := p.conn().Query("WITH claimed AS ("+
+		"UPDATE webhook_delivery SET next_attempt_at = $1 WHERE id IN ("+
+		"SELECT id FROM webhook_delivery WHERE status = $2 AND next_attempt_at <= $3 "+
+		"ORDER BY next_attempt_at LIMIT $4 FOR UPDATE SKIP LOCKED) RETURNING "+deliveryColumns+") "+
+		"SELECT claimed.*, s.url, s.secret FROM claimed JOIN webhook_subscription s ON s.id = claimed.subscription_id "+
+		"ORDER BY claimed.id",
+		now.Add(lease), webhook.StatusPending, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []webhook.Delivery
+	for rows.Next() {
+		var url, secret string
+		d, err := scanDelivery(rows, &url, &secret)
+		if err != nil {
+			return nil, err
+		}
+		d.URL, d.Secret = url, secret
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+func (p *Postgres) RecordDelivery(d webhook.Delivery) error {
+	_, err := p.conn().Exec("UPDATE webhook_delivery SET status = $1, attempts = $2, next_attempt_at = $3, "+
+		"last_error = $4, last_status_code = $5, delivered_at = $6 WHERE id = $7",
+		d.Status, d.Attempts, d.NextAttemptAt, d.LastError, d.LastStatusCode, d.DeliveredAt, d.ID)
+	return err
+}
+
+func (p *Postgres) EnqueueDeliveries(message outbox.Message) error {
+	_, err := p.conn().Exec("INSERT INTO webhook_delivery(subscription_id, event_id, event_type, payload) "+
+		"SELECT id, $1, $2, $3 FROM webhook_subscription WHERE $2 = ANY(events) "+
+		"ON CONFLICT (subscription_id, event_id) DO NOTHING",
+		message.EventID, message.Type, string(message.Payload))
+	return err
+}
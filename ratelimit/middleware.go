@@ -0,0 +1,86 @@
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/apikey"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	HeaderLimit     = "RateLimit-Limit"
+	HeaderRemaining = "RateLimit-Remaining"
+	HeaderReset     = "RateLimit-Reset"
+)
+
+type Err struct {
+	Message string `json:"message"`
+}
+
+type Config struct {
+	Store Store
+	Limit Limit
+	// Group separates the buckets of route groups that share a Store,
+	// so a client's reads do not use up its write allowance.
+	Group string
+	// KeyFunc identifies the client. Defaults to ClientKey.
+	KeyFunc func(c echo.Context) string
+}
+
+// ClientKey identifies a client by its API key, which is how batch
+// jobs and users authenticate, and falls back to the client IP for
+// requests that have not been authenticated yet.
+func ClientKey(c echo.Context) string {
+	if key, ok := apikey.FromContext(c); ok {
+		if key.ID == 0 {
+			return "key:" + key.Name
+		}
+		return "key:" + strconv.Itoa(key.ID)
+	}
+	return IPKey(c)
+}
+
+func IPKey(c echo.Context) string {
+	return "ip:" + c.RealIP()
+}
+
+// Middleware enforces config.Limit per client and reports the state of
+// the bucket in RateLimit-* headers. If the store fails the request is
+// let through, so an outage of the limiter backend does not take the
+// API down with it.
+func Middleware(config Config) echo.MiddlewareFunc {
+	if err := config.Limit.validate(); err != nil {
+		panic(err)
+	}
+	keyFunc := config.KeyFunc
+	if keyFunc == nil {
+		keyFunc = ClientKey
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key := config.Group + ":" + keyFunc(c)
+			result, err := config.Store.TakeToken(key, config.Limit, time.Now())
+			if err != nil {
+				c.Logger().Warnf("rate limiter unavailable for %s: %v", key, err)
+				return next(c)
+			}
+
+			header := c.Response().Header()
+			header.Set(HeaderLimit, strconv.Itoa(result.Limit))
+			header.Set(HeaderRemaining, strconv.Itoa(result.Remaining))
+			header.Set(HeaderReset, ceilSeconds(result.Reset))
+			if !result.Allowed {
+				header.Set(echo.HeaderRetryAfter, ceilSeconds(result.RetryAfter))
+				return c.JSON(http.StatusTooManyRequests, Err{Message: "rate limit exceeded"})
+			}
+			return next(c)
+		}
+	}
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
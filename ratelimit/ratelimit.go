@@ -0,0 +1,114 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit is a token bucket: Burst tokens at most, refilled at Rate
+// tokens per second.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until the next request would be allowed.
+	// It is zero when Allowed is true.
+	RetryAfter time.Duration
+}
+
+// Store takes one token from the bucket identified by key.
+type Store interface {
+	TakeToken(key string, limit Limit, now time.Time) (Result, error)
+}
+
+// Sweeper is implemented by the stores whose buckets outlive the process
+// and so must be swept by a job; Memory sweeps its own.
+type Sweeper interface {
+	// SweepBuckets deletes the buckets that have refilled completely by
+	// now, and returns how many.
+	SweepBuckets(now time.Time) (int, error)
+}
+
+// SweepJob returns a job that deletes refilled buckets, so that a bucket
+// for every client ever seen does not pile up.
+func SweepJob(store Sweeper) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		_, err := store.SweepBuckets(time.Now())
+		return err
+	}
+}
+
+// ParseLimit parses limits written as "<requests>/<unit>", e.g. "100/m".
+// The unit is one of s, m or h and the burst equals the request count.
+func ParseLimit(s string) (Limit, error) {
+	count, unit, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: expected <requests>/<unit>", s)
+	}
+	n, err := strconv.Atoi(count)
+	if err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: request count must be a positive integer", s)
+	}
+	var per time.Duration
+	switch unit {
+	case "s":
+		per = time.Second
+	case "m":
+		per = time.Minute
+	case "h":
+		per = time.Hour
+	default:
+		return Limit{}, fmt.Errorf("invalid rate limit %q: unit must be s, m or h", s)
+	}
+	return Limit{Rate: float64(n) / per.Seconds(), Burst: n}, nil
+}
+
+var errInvalidLimit = errors.New("rate limit must have a positive rate and burst")
+
+func (l Limit) validate() error {
+	if l.Rate <= 0 || l.Burst <= 0 {
+		return errInvalidLimit
+	}
+	return nil
+}
+
+// Refill returns the tokens in a bucket that held tokens at last, as of now.
+func Refill(tokens float64, last, now time.Time, limit Limit) float64 {
+	elapsed := now.Sub(last).Seconds()
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	return math.Min(float64(limit.Burst), tokens+elapsed*limit.Rate)
+}
+
+// Take applies one request to a bucket holding tokens (already refilled)
+// and returns the tokens left and the result to report to the client.
+// Backends share it so they all round and report the same way.
+func Take(tokens float64, limit Limit) (float64, Result) {
+	result := Result{Limit: limit.Burst}
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - tokens) / limit.Rate)
+	}
+	result.Remaining = int(math.Floor(tokens))
+	result.Reset = seconds((float64(limit.Burst) - tokens) / limit.Rate)
+	return tokens, result
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,89 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestParseLimit(t *testing.T) {
+	got, err := ParseLimit("120/m")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Burst != 120 || got.Rate != 2 {
+		t.Errorf("expected 2 tokens/s with burst 120 but got %+v", got)
+	}
+
+	for _, s := range []string{"", "120", "0/m", "x/m", "120/d"} {
+		if _, err := ParseLimit(s); err == nil {
+			t.Errorf("expected %q to be rejected", s)
+		}
+	}
+}
+
+func TestMemory(t *testing.T) {
+	m := NewMemory()
+	limit := Limit{Rate: 1, Burst: 2}
+	now := time.Date(2024, 04, 12, 10, 45, 16, 0, time.UTC)
+
+	for i, want := range []int{1, 0} {
+		r, _ := m.TakeToken("client", limit, now)
+		if !r.Allowed || r.Remaining != want {
+			t.Fatalf("request %d: expected allowed with %d remaining but got %+v", i, want, r)
+		}
+	}
+
+	r, _ := m.TakeToken("client", limit, now)
+	if r.Allowed {
+		t.Fatalf("expected third request to be limited")
+	}
+	if r.RetryAfter != time.Second {
+		t.Errorf("expected retry after 1s but got %v", r.RetryAfter)
+	}
+
+	if r, _ := m.TakeToken("other", limit, now); !r.Allowed {
+		t.Errorf("expected buckets to be independent per key")
+	}
+	if r, _ := m.TakeToken("client", limit, now.Add(time.Second)); !r.Allowed {
+		t.Errorf("expected a token to be refilled after 1s")
+	}
+
+	m.TakeToken("client", limit, now.Add(time.Hour))
+	if _, ok := m.buckets["other"]; ok {
+		t.Errorf("expected full buckets to be swept")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	e := echo.New()
+	e.GET("/", func(c echo.Context) error {
+		return c.String(http.StatusOK, "ok")
+	}, Middleware(Config{Store: NewMemory(), Limit: Limit{Rate: 0.5, Burst: 1}, Group: "read"}))
+
+	get := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		res := httptest.NewRecorder()
+		e.ServeHTTP(res, req)
+		return res
+	}
+
+	res := get()
+	if res.Code != http.StatusOK {
+		t.Fatalf("expected status code %d but got %d", http.StatusOK, res.Code)
+	}
+	if res.Header().Get(HeaderLimit) != "1" || res.Header().Get(HeaderRemaining) != "0" {
+		t.Errorf("unexpected rate limit headers %v", res.Header())
+	}
+
+	res = get()
+	if res.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status code %d but got %d", http.StatusTooManyRequests, res.Code)
+	}
+	if got := res.Header().Get(echo.HeaderRetryAfter); got != "2" {
+		t.Errorf("expected Retry-After 2 but got %q", got)
+	}
+}
@@ -0,0 +1,91 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/apikey"
+)
+
+const apiKeyColumns = "id, name, prefix, scopes, expires_at, last_used_at, revoked_at, created_at"
+
+func scanAPIKey(row scanner) (apikey.APIKey, error) {
+	var k apikey.APIKey
+	var scopes string
+	err := row.Scan(&k.ID, &k.Name, &k.Prefix, &scopes,
+		&k.ExpiresAt, &k.LastUsedAt, &k.RevokedAt, &k.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return k, apikey.ErrNotFound
+	}
+	if err != nil {
+		return k, err
+	}
+	return k, json.Unmarshal([]byte(scopes), &k.Scopes)
+}
+
+func (s *SQLite) APIKeys() ([]apikey.APIKey, error) {
+	rows, err := s.conn().Query("SELECT " + apiKeyColumns + " FROM api_key ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []apikey.APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+func (s *SQLite) APIKeyByHash(hash string) (apikey.APIKey, error) {
+	row := s.conn().QueryRow("SELECT "+apiKeyColumns+" FROM api_key WHERE key_hash = $1", hash)
+	return scanAPIKey(row)
+}
+
+func (s *SQLite) CreateAPIKey(createAPIKey apikey.CreateAPIKey, secret apikey.Secret) (apikey.APIKey, error) {
+	scopes := createAPIKey.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+	scopesJSON, err := json.Marshal(scopes)
+	if err != nil {
+		return apikey.APIKey{}, err
+	}
+	row := s.conn().QueryRow("INSERT INTO api_key(name, prefix, key_hash, scopes, expires_at, created_at) VALUES($1,$2,$3,$4,$5,$6) "+
+		"RETURNING "+apiKeyColumns,
+		createAPIKey.Name, secret.Prefix, secret.Hash, string(scopesJSON), nullTimestamp(createAPIKey.ExpiresAt), timestamp(time.Now()))
+	return scanAPIKey(row)
+}
+
+func (s *SQLite) RotateAPIKey(id int, secret apikey.Secret) (apikey.APIKey, error) {
+	row := s.conn().QueryRow("UPDATE api_key SET prefix = $1, key_hash = $2, last_used_at = NULL "+
+		"WHERE id = $3 AND revoked_at IS NULL RETURNING "+apiKeyColumns,
+		secret.Prefix, secret.Hash, id)
+	return scanAPIKey(row)
+}
+
+func (s *SQLite) RevokeAPIKey(id int) error {
+	res, err := s.conn().Exec("UPDATE api_key SET revoked_at = $1 WHERE id = $2 AND revoked_at IS NULL", timestamp(time.Now()), id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return apikey.ErrNotFound
+	}
+	return nil
+}
+
+func (s *SQLite) TouchAPIKey(id int, usedAt time.Time) error {
+	_, err := s.conn().Exec("UPDATE api_key SET last_used_at = $1 WHERE id = $2", timestamp(usedAt), id)
+	return err
+}
@@ -0,0 +1,64 @@
+-- The schema of init.sql for what SQLite stores. The wallet_type and
+-- wallet_status enums are CHECK constraints, DECIMAL(10, 2) amounts are
+-- rounded on write and timestamps are text in UTC.
+CREATE TABLE user_wallet (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL,
+	user_name VARCHAR(255) NOT NULL,
+	wallet_name VARCHAR(255) NOT NULL,
+	wallet_type TEXT NOT NULL CHECK (wallet_type IN ('Savings', 'Credit Card', 'Crypto Wallet')),
+	balance NUMERIC NOT NULL,
+	credit_limit NUMERIC CHECK (credit_limit >= 0),
+	status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'frozen', 'closed')),
+	created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000', 'now')),
+	deleted_at TIMESTAMP,
+	CHECK (credit_limit IS NULL OR wallet_type = 'Credit Card')
+);
+
+CREATE INDEX user_wallet_user_id_idx ON user_wallet (user_id);
+CREATE INDEX user_wallet_deleted_at_idx ON user_wallet (deleted_at) WHERE deleted_at IS NOT NULL;
+
+INSERT INTO user_wallet (user_id, user_name, wallet_name, wallet_type, balance, credit_limit) VALUES
+(1, 'John Doe', 'John Savings', 'Savings', 1000.00, NULL),
+(1, 'John Doe', 'John Credit Card', 'Credit Card', 500.00, 5000.00),
+(1, 'John Doe', 'John Crypto Wallet', 'Crypto Wallet', 100.00, NULL),
+(2, 'Jane Doe', 'Jane Savings', 'Savings', 2000.00, NULL),
+(2, 'Jane Doe', 'Jane Credit Card', 'Credit Card', 1000.00, 10000.00),
+(2, 'Jane Doe', 'Jane Crypto Wallet', 'Crypto Wallet', 200.00, NULL);
+
+-- scopes is a JSON array.
+CREATE TABLE api_key (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name VARCHAR(255) NOT NULL,
+	prefix VARCHAR(16) NOT NULL,
+	key_hash CHAR(64) NOT NULL UNIQUE,
+	scopes TEXT NOT NULL DEFAULT '[]',
+	expires_at TIMESTAMP,
+	last_used_at TIMESTAMP,
+	revoked_at TIMESTAMP,
+	created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000', 'now'))
+);
+
+CREATE TABLE wallet_audit (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	actor VARCHAR(255) NOT NULL,
+	action VARCHAR(32) NOT NULL,
+	wallet_id INTEGER NOT NULL,
+	before TEXT,
+	after TEXT,
+	reason TEXT NOT NULL DEFAULT '',
+	request_id VARCHAR(255) NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f000', 'now'))
+);
+
+CREATE INDEX wallet_audit_wallet_id_idx ON wallet_audit (wallet_id, id);
+
+CREATE TRIGGER wallet_audit_immutable_update BEFORE UPDATE ON wallet_audit
+BEGIN
+	SELECT RAISE(ABORT, 'wallet_audit is append-only');
+END;
+
+CREATE TRIGGER wallet_audit_immutable_delete BEFORE DELETE ON wallet_audit
+BEGIN
+	SELECT RAISE(ABORT, 'wallet_audit is append-only');
+END;
@@ -0,0 +1,194 @@
+// Package sqlite stores wallets, their audit and API keys in a SQLite
+// file, for demos and edge deployments without a Postgres server. It
+// behaves like the postgres package for everything it stores.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet"
+	_ "modernc.org/sqlite"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+type SQLite struct {
+	Db *sql.DB
+	// tx is set on the SQLite that WithTx passes to its fn, whose methods
+	// then run in tx instead of on the pool.
+	tx *sql.Tx
+}
+
+// New opens the database file at path, creating it if needed, and
+// migrates it to the latest schema.
+//
+// Transactions begin IMMEDIATE, taking the write lock at once, so that a
+// wallet read in a transaction stays locked until it ends, like SELECT
+// ... FOR UPDATE in Postgres. WAL lets reads go on meanwhile.
+func New(path string) (*SQLite, error) {
+	params := url.Values{}
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_pragma", "busy_timeout(5000)")
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Set("_txlock", "immediate")
+	db, err := sql.Open("sqlite", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate %s: %w", path, err)
+	}
+	return &SQLite{Db: db}, nil
+}
+
+// migrate applies the migrations newer than the user_version of the
+// database, in the order of their numbered names, each in a transaction
+// that also bumps user_version to its number.
+func migrate(db *sql.DB) error {
+	names, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	var current int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&current); err != nil {
+		return err
+	}
+	for _, name := range names {
+		base := strings.TrimPrefix(name, "migrations/")
+		version, err := strconv.Atoi(strings.SplitN(base, "_", 2)[0])
+		if err != nil {
+			return fmt.Errorf("migration %s: name must start with its number", base)
+		}
+		if version <= current {
+			continue
+		}
+		script, err := migrations.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		err = inTx(db, func(tx *sql.Tx) error {
+			if _, err := tx.Exec(string(script)); err != nil {
+				return err
+			}
+			// PRAGMA takes no parameters.
+			_, err := tx.Exec("PRAGMA user_version = " + strconv.Itoa(version))
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("migration %s: %w", base, err)
+		}
+	}
+	return nil
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// inTx runs fn in a transaction, committing if it returns nil and
+// rolling back otherwise.
+func inTx(db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// inTx runs fn in a transaction or, in the transaction of WithTx, in a
+// savepoint, so that a failing call leaves the transaction usable.
+func (s *SQLite) inTx(fn func(tx *sql.Tx) error) error {
+	if s.tx != nil {
+		return savepoint(s.tx, fn)
+	}
+	return inTx(s.Db, fn)
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx.
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// conn returns the transaction of the SQLite, if bound to one, or the
+// pool.
+func (s *SQLite) conn() querier {
+	if s.tx != nil {
+		return s.tx
+	}
+	return s.Db
+}
+
+// WithTx runs fn in a transaction. Since transactions take the write
+// lock when they begin, they are serializable and never conflict, so
+// unlike in Postgres there is nothing to retry.
+func (s *SQLite) WithTx(ctx context.Context, fn func(tx wallet.Storer) error) error {
+	if s.tx != nil {
+		return savepoint(s.tx, func(*sql.Tx) error {
+			return fn(s)
+		})
+	}
+	tx, err := s.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(&SQLite{Db: s.Db, tx: tx}); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// savepoint runs fn in a savepoint of tx, rolling back to it if fn
+// fails. Savepoints of the same name nest, the newest shadowing the
+// others, so nested calls can share the name as long as each releases
+// its own.
+func savepoint(tx *sql.Tx, fn func(tx *sql.Tx) error) error {
+	if _, err := tx.Exec("SAVEPOINT storer_call"); err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_, rbErr := tx.Exec("ROLLBACK TO storer_call; RELEASE storer_call")
+		return errors.Join(err, rbErr)
+	}
+	_, err := tx.Exec("RELEASE storer_call")
+	return err
+}
+
+// timeFormat has a fixed width, unlike the driver's, so that timestamps,
+// stored as text, compare in order.
+const timeFormat = "2006-01-02 15:04:05.000000"
+
+// timestamp formats t for a TIMESTAMP column, at the precision of
+// Postgres.
+func timestamp(t time.Time) string {
+	return t.UTC().Format(timeFormat)
+}
+
+// nullTimestamp formats t, or returns nil for NULL.
+func nullTimestamp(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return timestamp(*t)
+}
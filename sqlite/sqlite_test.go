@@ -0,0 +1,129 @@
+package sqlite
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/apikey"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet/storertest"
+)
+
+var actor = wallet.Actor{Name: "admin", RequestID: "req-1"}
+
+// seeded is how many wallets the first migration inserts.
+const seeded = 6
+
+func open(t *testing.T) *SQLite {
+	t.Helper()
+	s, err := New(filepath.Join(t.TempDir(), "wallets.db"))
+	if err != nil {
+		t.Fatalf("unable to open database: %v", err)
+	}
+	t.Cleanup(func() { s.Db.Close() })
+	return s
+}
+
+func TestMigrate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallets.db")
+	for i := 0; i < 2; i++ {
+		s, err := New(path)
+		if err != nil {
+			t.Fatalf("unable to open database the %d. time: %v", i+1, err)
+		}
+		wallets, _ := s.Wallets(wallet.Filter{})
+		if len(wallets) != seeded {
+			t.Errorf("expected %d seeded wallets but got %d", seeded, len(wallets))
+		}
+		s.Db.Close()
+	}
+}
+
+func TestConformance(t *testing.T) {
+	storertest.Run(t, func(t *testing.T) wallet.Storer {
+		s := open(t)
+		if _, err := s.Db.Exec("DELETE FROM user_wallet"); err != nil {
+			t.Fatalf("unable to remove the sample wallets: %v", err)
+		}
+		return s
+	})
+	storertest.RunErrors(t, func(t *testing.T) wallet.Storer {
+		s := open(t)
+		s.Db.Close()
+		return s
+	})
+}
+
+func TestAuditAppendOnly(t *testing.T) {
+	s := open(t)
+	if _, err := s.ChangeWalletStatus(1, wallet.ChangeStatus{Status: wallet.StatusFrozen, Reason: "fraud"}, actor); err != nil {
+		t.Fatalf("unable to change status: %v", err)
+	}
+	if _, err := s.Db.Exec("UPDATE wallet_audit SET reason = ''"); err == nil {
+		t.Errorf("expected audit entries not to be changed")
+	}
+	if _, err := s.Db.Exec("DELETE FROM wallet_audit"); err == nil {
+		t.Errorf("expected audit entries not to be deleted")
+	}
+	if entries, _ := s.WalletAudit(1); len(entries) != 1 || entries[0].Reason != "fraud" {
+		t.Errorf("expected the audit entry to be kept but got %+v", entries)
+	}
+}
+
+func TestPurgeDeletedWallets(t *testing.T) {
+	s := open(t)
+	s.DeleteWallet(1, actor)
+	cutOff := time.Now()
+	s.DeleteWallet(2, actor)
+
+	n, err := s.PurgeDeletedWallets(cutOff, actor)
+	if err != nil || n != 3 {
+		t.Errorf("expected the 3 wallets of user 1 to be purged but got %d, %v", n, err)
+	}
+	if wallets, _ := s.Wallets(wallet.Filter{IncludeDeleted: true}); len(wallets) != 3 || wallets[0].UserID != 2 {
+		t.Errorf("expected only the wallets of user 2 to be kept but got %+v", wallets)
+	}
+
+	entries, _ := s.AuditEntries(wallet.AuditFilter{Action: wallet.ActionPurge, To: &cutOff, Limit: 10})
+	if len(entries) != 0 {
+		t.Errorf("expected no purge before the cut-off but got %+v", entries)
+	}
+	entries, _ = s.AuditEntries(wallet.AuditFilter{Action: wallet.ActionPurge, From: &cutOff, Limit: 2})
+	if len(entries) != 2 || entries[0].WalletID != 3 || entries[0].After != nil {
+		t.Errorf("expected the last 2 purges, newest first, but got %+v", entries)
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	s := open(t)
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Microsecond)
+	k, err := s.CreateAPIKey(apikey.CreateAPIKey{Name: "batch", Scopes: []string{"wallets:read"}, ExpiresAt: &expiresAt},
+		apikey.Secret{Prefix: "wk_1", Hash: "hash-1"})
+	if err != nil || k.ID != 1 || len(k.Scopes) != 1 || !k.ExpiresAt.Equal(expiresAt) {
+		t.Fatalf("unable to create api key: %+v, %v", k, err)
+	}
+	if _, err := s.CreateAPIKey(apikey.CreateAPIKey{Name: "copy"}, apikey.Secret{Hash: "hash-1"}); err == nil {
+		t.Errorf("expected a duplicate hash to be rejected")
+	}
+
+	s.TouchAPIKey(k.ID, time.Now())
+	rotated, err := s.RotateAPIKey(k.ID, apikey.Secret{Prefix: "wk_2", Hash: "hash-2"})
+	if err != nil || rotated.Prefix != "wk_2" || rotated.LastUsedAt != nil {
+		t.Errorf("expected key to be rotated but got %+v, %v", rotated, err)
+	}
+	if _, err := s.APIKeyByHash("hash-1"); !errors.Is(err, apikey.ErrNotFound) {
+		t.Errorf("expected the old hash to be forgotten but got %v", err)
+	}
+
+	if err := s.RevokeAPIKey(k.ID); err != nil {
+		t.Errorf("unable to revoke key: %v", err)
+	}
+	if err := s.RevokeAPIKey(k.ID); !errors.Is(err, apikey.ErrNotFound) {
+		t.Errorf("expected a revoked key not to be revoked again but got %v", err)
+	}
+	if keys, _ := s.APIKeys(); len(keys) != 1 || keys[0].RevokedAt == nil {
+		t.Errorf("expected the revoked key to be listed but got %+v", keys)
+	}
+}
@@ -0,0 +1,277 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet"
+)
+
+// walletColumns has no funds held, since holds are only kept in
+// Postgres.
+const walletColumns = "id, user_id, user_name, wallet_name, wallet_type, balance, credit_limit, status, created_at, deleted_at"
+
+func scanWallet(row scanner) (wallet.Wallet, error) {
+	var w wallet.Wallet
+	err := row.Scan(&w.ID,
+		&w.UserID, &w.UserName,
+		&w.WalletName, &w.WalletType,
+		&w.Balance, &w.CreditLimit, &w.Status, &w.CreatedAt, &w.DeletedAt,
+	)
+	w.Derive()
+	if errors.Is(err, sql.ErrNoRows) {
+		return w, wallet.ErrNotFound
+	}
+	return w, err
+}
+
+// collectWallets scans and closes rows.
+func collectWallets(rows *sql.Rows) ([]wallet.Wallet, error) {
+	defer rows.Close()
+
+	wallets := []wallet.Wallet{}
+	for rows.Next() {
+		w, err := scanWallet(rows)
+		if err != nil {
+			return nil, err
+		}
+		wallets = append(wallets, w)
+	}
+	return wallets, rows.Err()
+}
+
+// walletsQuery selects the wallets matching filter, ordered by id.
+func walletsQuery(filter wallet.Filter) (string, []any) {
+	sqlStr := "SELECT " + walletColumns + " FROM user_wallet WHERE ($1 OR deleted_at IS NULL)"
+	args := []any{filter.IncludeDeleted}
+	if filter.WalletType != "" {
+		args = append(args, filter.WalletType)
+		sqlStr += " AND wallet_type = $" + strconv.Itoa(len(args))
+	}
+	if filter.AfterID != 0 {
+		args = append(args, filter.AfterID)
+		sqlStr += " AND id > $" + strconv.Itoa(len(args))
+	}
+	sqlStr += " ORDER BY id"
+	if filter.Limit != 0 {
+		args = append(args, filter.Limit)
+		sqlStr += " LIMIT $" + strconv.Itoa(len(args))
+	}
+	return sqlStr, args
+}
+
+func (s *SQLite) Wallets(filter wallet.Filter) ([]wallet.Wallet, error) {
+	sqlStr, args := walletsQuery(filter)
+	rows, err := s.conn().Query(sqlStr, args...)
+	if err != nil {
+		return nil, err
+	}
+	return collectWallets(rows)
+}
+
+// exportPageSize is how many wallets EachWallet reads at a time.
+const exportPageSize = 500
+
+// EachWallet calls fn with every wallet matching filter, in id order. It
+// reads them a page at a time, outside of a transaction, so that fn may
+// change wallets without waiting for the write lock. It stops at the
+// first error fn returns.
+func (s *SQLite) EachWallet(filter wallet.Filter, fn func(wallet.Wallet) error) error {
+	remaining := filter.Limit
+	for {
+		page := filter
+		page.Limit = exportPageSize
+		if remaining != 0 && remaining < exportPageSize {
+			page.Limit = remaining
+		}
+		wallets, err := s.Wallets(page)
+		if err != nil {
+			return err
+		}
+		for _, w := range wallets {
+			if err := fn(w); err != nil {
+				return err
+			}
+		}
+		if remaining != 0 {
+			remaining -= len(wallets)
+			if remaining == 0 {
+				return nil
+			}
+		}
+		if len(wallets) < page.Limit {
+			return nil
+		}
+		filter.AfterID = wallets[len(wallets)-1].ID
+	}
+}
+
+// WalletByUser returns the newest wallet of the user that is not
+// deleted, or wallet.ErrNotFound.
+func (s *SQLite) WalletByUser(userID int) (wallet.Wallet, error) {
+	return scanWallet(s.conn().QueryRow("SELECT "+walletColumns+" FROM user_wallet "+
+		"WHERE user_id = $1 AND deleted_at IS NULL ORDER BY id DESC LIMIT 1", userID))
+}
+
+// insertWallet rounds the amounts like the DECIMAL(10, 2) columns of
+// Postgres.
+func insertWallet(tx *sql.Tx, createWallet wallet.CreateWallet, createdAt time.Time) (wallet.Wallet, error) {
+	return scanWallet(tx.QueryRow("INSERT INTO user_wallet(user_id,user_name,wallet_name,wallet_type,balance,credit_limit,created_at) "+
+		"VALUES($1,$2,$3,$4,ROUND($5, 2),ROUND($6, 2),$7) RETURNING "+walletColumns,
+		createWallet.UserID, createWallet.UserName, createWallet.WalletName, createWallet.WalletType,
+		createWallet.Balance, createWallet.CreditLimit, timestamp(createdAt)))
+}
+
+func (s *SQLite) CreateWallet(createWallet wallet.CreateWallet, actor wallet.Actor) (wallet.Wallet, error) {
+	var result wallet.Wallet
+	err := s.inTx(func(tx *sql.Tx) error {
+		var err error
+		result, err = insertWallet(tx, createWallet, time.Now())
+		if err != nil {
+			return err
+		}
+		return insertAudit(tx, actor, wallet.ActionCreate, result.ID, nil, &result, "")
+	})
+	return result, err
+}
+
+func (s *SQLite) ImportWallets(createWallets []wallet.CreateWallet, actor wallet.Actor) ([]wallet.Wallet, error) {
+	created := make([]wallet.Wallet, 0, len(createWallets))
+	err := s.inTx(func(tx *sql.Tx) error {
+		now := time.Now()
+		for i, c := range createWallets {
+			w, err := insertWallet(tx, c, now)
+			if err != nil {
+				return fmt.Errorf("wallet %d: %w", i+1, err)
+			}
+			if err := insertAudit(tx, actor, wallet.ActionCreate, w.ID, nil, &w, "import"); err != nil {
+				return err
+			}
+			created = append(created, w)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// DeleteWallet soft-deletes the wallets of a user. They stay in
+// user_wallet, hidden from reads, until restored or purged.
+func (s *SQLite) DeleteWallet(userID int, actor wallet.Actor) error {
+	return s.inTx(func(tx *sql.Tx) error {
+		rows, err := tx.Query("UPDATE user_wallet SET deleted_at = $1 WHERE user_id = $2 AND deleted_at IS NULL "+
+			"RETURNING "+walletColumns, timestamp(time.Now()), userID)
+		if err != nil {
+			return err
+		}
+		deleted, err := collectWallets(rows)
+		if err != nil {
+			return err
+		}
+		for _, after := range deleted {
+			before := after
+			before.DeletedAt = nil
+			if err := insertAudit(tx, actor, wallet.ActionDelete, after.ID, &before, &after, ""); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *SQLite) RestoreWallet(walletID int, actor wallet.Actor) (wallet.Wallet, error) {
+	var result wallet.Wallet
+	err := s.inTx(func(tx *sql.Tx) error {
+		var err error
+		result, err = scanWallet(tx.QueryRow("UPDATE user_wallet SET deleted_at = NULL "+
+			"WHERE id = $1 AND deleted_at IS NOT NULL RETURNING "+walletColumns, walletID))
+		if err != nil {
+			return err
+		}
+		return insertAudit(tx, actor, wallet.ActionRestore, result.ID, nil, &result, "")
+	})
+	return result, err
+}
+
+// PurgeDeletedWallets hard-deletes wallets soft-deleted before the
+// cut-off and returns how many were removed.
+func (s *SQLite) PurgeDeletedWallets(before time.Time, actor wallet.Actor) (int, error) {
+	var purged []wallet.Wallet
+	err := s.inTx(func(tx *sql.Tx) error {
+		rows, err := tx.Query("DELETE FROM user_wallet WHERE deleted_at < $1 RETURNING "+walletColumns, timestamp(before))
+		if err != nil {
+			return err
+		}
+		purged, err = collectWallets(rows)
+		if err != nil {
+			return err
+		}
+		for _, w := range purged {
+			if err := insertAudit(tx, actor, wallet.ActionPurge, w.ID, &w, nil, ""); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return len(purged), err
+}
+
+func (s *SQLite) UpdateWallet(updateWallet wallet.UpdateWallet, actor wallet.Actor) (wallet.Wallet, error) {
+	var result wallet.Wallet
+	sqlStr := "UPDATE user_wallet SET user_id=$1, user_name=$2, wallet_name=$3," +
+		"wallet_type=$4, balance=ROUND($5, 2), credit_limit=ROUND($6, 2), created_at=$7 WHERE id=$8 " +
+		"RETURNING " + walletColumns
+	err := s.inTx(func(tx *sql.Tx) error {
+		before, err := liveWallet(tx, updateWallet.ID)
+		if errors.Is(err, wallet.ErrNotFound) {
+			return fmt.Errorf("unable to update row: %w", err)
+		}
+		if err != nil {
+			return err
+		}
+		after, err := wallet.ApplyUpdate(before, updateWallet)
+		if err != nil {
+			return err
+		}
+		result, err = scanWallet(tx.QueryRow(sqlStr, after.UserID, after.UserName, after.WalletName,
+			after.WalletType, after.Balance, after.CreditLimit, timestamp(time.Now()),
+			after.ID))
+		if err != nil {
+			return err
+		}
+		return insertAudit(tx, actor, wallet.ActionUpdate, result.ID, &before, &result, "")
+	})
+	return result, err
+}
+
+func (s *SQLite) ChangeWalletStatus(walletID int, changeStatus wallet.ChangeStatus, actor wallet.Actor) (wallet.Wallet, error) {
+	var result wallet.Wallet
+	err := s.inTx(func(tx *sql.Tx) error {
+		before, err := liveWallet(tx, walletID)
+		if err != nil {
+			return err
+		}
+		if err := wallet.Transition(before, changeStatus.Status); err != nil {
+			return err
+		}
+		result, err = scanWallet(tx.QueryRow("UPDATE user_wallet SET status = $1 WHERE id = $2 RETURNING "+walletColumns,
+			changeStatus.Status, walletID))
+		if err != nil {
+			return err
+		}
+		return insertAudit(tx, actor, wallet.ActionStatus, walletID, &before, &result, changeStatus.Reason)
+	})
+	return result, err
+}
+
+// liveWallet reads a wallet that is not deleted. The IMMEDIATE
+// transaction tx already holds the write lock, so it cannot change
+// until tx ends.
+func liveWallet(tx *sql.Tx, walletID int) (wallet.Wallet, error) {
+	return scanWallet(tx.QueryRow("SELECT "+walletColumns+" FROM user_wallet WHERE id = $1 AND deleted_at IS NULL", walletID))
+}
@@ -0,0 +1,97 @@
+package statement
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+type Handler struct {
+	store Storer
+}
+
+type Storer interface {
+	Statements(walletID int) ([]Statement, error)
+	// Statement returns the statement of a period with its transactions.
+	Statement(walletID int, period string) (Statement, error)
+
+	StatementAccounts() ([]Account, error)
+	GenerateStatement(walletID int, period Period) (Statement, error)
+	// OverdueStatements returns statements whose due date is before now
+	// and whose late fee has not been assessed yet, leaving out frozen
+	// cards until they are active again.
+	OverdueStatements(now time.Time) ([]Statement, error)
+	// AssessLateFee charges LateFee if the payments received by the due
+	// date fall short of the minimum payment, and marks the statement
+	// assessed either way. The fee is waived on closed cards and left
+	// for a later run on frozen ones.
+	AssessLateFee(statement Statement) error
+}
+
+func New(db Storer) *Handler {
+	return &Handler{store: db}
+}
+
+type Err struct {
+	Message string `json:"message"`
+}
+
+// StatementsHandler
+//
+//	@Summary		List wallet statements
+//	@Description	List the monthly statements of a Credit Card wallet, newest first, without transactions
+//	@Tags			statement
+//	@Produce		json
+//	@Success		200	{array}		Statement
+//	@Router			/api/v1/wallets/{id}/statements [get]
+//	@Failure		400	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Param			id path int true "Wallet ID"
+//	@Security		ApiKeyAuth
+func (h *Handler) StatementsHandler(c echo.Context) error {
+	walletID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: "invalid wallet id"})
+	}
+	statements, err := h.store.Statements(walletID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, Err{Message: err.Error()})
+	}
+	return c.JSON(http.StatusOK, statements)
+}
+
+// StatementHandler
+//
+//	@Summary		Get wallet statement
+//	@Description	Get the statement of a Credit Card wallet for one month, with its transactions
+//	@Tags			statement
+//	@Produce		json
+//	@Success		200	{object}	Statement
+//	@Router			/api/v1/wallets/{id}/statements/{period} [get]
+//	@Failure		400	{object}	Err
+//	@Failure		404	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Param			id path int true "Wallet ID"
+//	@Param			period path string true "Statement period, YYYY-MM"
+//	@Security		ApiKeyAuth
+func (h *Handler) StatementHandler(c echo.Context) error {
+	walletID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: "invalid wallet id"})
+	}
+	period, err := ParsePeriod(c.Param("period"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+	result, err := h.store.Statement(walletID, period.Key())
+	if errors.Is(err, ErrNotFound) {
+		return c.JSON(http.StatusNotFound, Err{Message: err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, Err{Message: err.Error()})
+	}
+	return c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,52 @@
+package statement
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Job returns a job that generates the statements of every period that
+// has ended and charges late fees on statements past their due date.
+func Job(store Storer) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		return Run(ctx, store, time.Now())
+	}
+}
+
+// Run generates missing statements and assesses late fees as of now.
+// Both steps are idempotent: statements are unique per wallet and period
+// and a statement's late fee is assessed once.
+func Run(ctx context.Context, store Storer, now time.Time) error {
+	accounts, err := store.StatementAccounts()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, account := range accounts {
+		for period := account.Next; !period.End.After(now); period = period.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if _, err := store.GenerateStatement(account.WalletID, period); err != nil {
+				errs = append(errs, fmt.Errorf("statement %d/%s: %w", account.WalletID, period.Key(), err))
+				break
+			}
+		}
+	}
+
+	overdue, err := store.OverdueStatements(now)
+	if err != nil {
+		return errors.Join(append(errs, err)...)
+	}
+	for _, s := range overdue {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := store.AssessLateFee(s); err != nil {
+			errs = append(errs, fmt.Errorf("late fee %d/%s: %w", s.WalletID, s.Period, err))
+		}
+	}
+	return errors.Join(errs...)
+}
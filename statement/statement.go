@@ -0,0 +1,105 @@
+package statement
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/ledger"
+)
+
+const (
+	// MinimumPaymentRate is the share of the amount owed that must be
+	// paid by the due date, but at least MinimumPaymentFloor.
+	MinimumPaymentRate  = 0.02
+	MinimumPaymentFloor = 25.00
+	LateFee             = 25.00
+	// GracePeriod is the time from the end of the statement period to
+	// the due date of its minimum payment.
+	GracePeriod = 21 * 24 * time.Hour
+
+	periodLayout = "2006-01"
+)
+
+var (
+	ErrNotFound      = errors.New("statement not found")
+	ErrInvalidPeriod = errors.New("period must be written as YYYY-MM")
+)
+
+// Statement is the monthly summary of a Credit Card wallet. Balances
+// follow the wallet: negative when the cardholder owes money.
+type Statement struct {
+	WalletID       int                  `json:"wallet_id" example:"2"`
+	Period         string               `json:"period" example:"2024-04"`
+	PeriodStart    time.Time            `json:"period_start" example:"2024-04-01T00:00:00Z"`
+	PeriodEnd      time.Time            `json:"period_end" example:"2024-05-01T00:00:00Z"`
+	OpeningBalance float64              `json:"opening_balance" example:"-120.00"`
+	ClosingBalance float64              `json:"closing_balance" example:"-950.00"`
+	MinimumPayment float64              `json:"minimum_payment" example:"25.00"`
+	DueDate        time.Time            `json:"due_date" example:"2024-05-22T00:00:00Z"`
+	LateFee        float64              `json:"late_fee" example:"0"`
+	Transactions   []ledger.Transaction `json:"transactions,omitempty"`
+	CreatedAt      time.Time            `json:"created_at" example:"2024-05-01T00:05:00Z"`
+}
+
+// Period is a calendar month in UTC.
+type Period struct {
+	Start time.Time
+	End   time.Time
+}
+
+func PeriodOf(t time.Time) Period {
+	t = t.UTC()
+	start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return Period{Start: start, End: start.AddDate(0, 1, 0)}
+}
+
+func ParsePeriod(s string) (Period, error) {
+	t, err := time.Parse(periodLayout, s)
+	if err != nil {
+		return Period{}, ErrInvalidPeriod
+	}
+	return PeriodOf(t), nil
+}
+
+func (p Period) Key() string {
+	return p.Start.Format(periodLayout)
+}
+
+func (p Period) Next() Period {
+	return PeriodOf(p.End)
+}
+
+func (p Period) DueDate() time.Time {
+	return p.End.Add(GracePeriod)
+}
+
+// MinimumPayment returns the minimum payment due for a closing balance.
+func MinimumPayment(closingBalance float64) float64 {
+	owed := -closingBalance
+	if owed <= 0 {
+		return 0
+	}
+	minimum := math.Max(MinimumPaymentFloor, ledger.Round(owed*MinimumPaymentRate))
+	return math.Min(minimum, owed)
+}
+
+// LateFeeDue reports whether paid, the sum of credits between the end of
+// the period and the due date, falls short of the minimum payment.
+func (s Statement) LateFeeDue(paid float64) bool {
+	return ledger.Round(paid) < s.MinimumPayment
+}
+
+// LateFeeReference is the ledger reference of the late fee of a
+// statement, so it is charged at most once.
+func LateFeeReference(walletID int, period string) string {
+	return fmt.Sprintf("late-fee:%d:%s", walletID, period)
+}
+
+// Account is a Credit Card wallet due for statements. Statements exist
+// for every period before Next.
+type Account struct {
+	WalletID int
+	Next     Period
+}
@@ -0,0 +1,186 @@
+package statement
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+type StubStorer struct {
+	accounts   []Account
+	statements []Statement
+	paid       map[string]float64
+	fees       map[string]float64
+}
+
+func (s *StubStorer) Statements(walletID int) ([]Statement, error) {
+	result := []Statement{}
+	for _, st := range s.statements {
+		if st.WalletID == walletID {
+			result = append(result, st)
+		}
+	}
+	return result, nil
+}
+
+func (s *StubStorer) Statement(walletID int, period string) (Statement, error) {
+	for _, st := range s.statements {
+		if st.WalletID == walletID && st.Period == period {
+			return st, nil
+		}
+	}
+	return Statement{}, ErrNotFound
+}
+
+func (s *StubStorer) StatementAccounts() ([]Account, error) {
+	return append([]Account(nil), s.accounts...), nil
+}
+
+func (s *StubStorer) GenerateStatement(walletID int, period Period) (Statement, error) {
+	if existing, err := s.Statement(walletID, period.Key()); err == nil {
+		return existing, nil
+	}
+	st := Statement{
+		WalletID:       walletID,
+		Period:         period.Key(),
+		PeriodStart:    period.Start,
+		PeriodEnd:      period.End,
+		ClosingBalance: -1000,
+		MinimumPayment: MinimumPayment(-1000),
+		DueDate:        period.DueDate(),
+	}
+	s.statements = append(s.statements, st)
+	return st, nil
+}
+
+func (s *StubStorer) OverdueStatements(now time.Time) ([]Statement, error) {
+	var result []Statement
+	for _, st := range s.statements {
+		if _, assessed := s.fees[st.Period]; !assessed && st.DueDate.Before(now) && st.MinimumPayment > 0 {
+			result = append(result, st)
+		}
+	}
+	return result, nil
+}
+
+func (s *StubStorer) AssessLateFee(st Statement) error {
+	s.fees[st.Period] = 0
+	if st.LateFeeDue(s.paid[st.Period]) {
+		s.fees[st.Period] = LateFee
+	}
+	return nil
+}
+
+func TestMinimumPayment(t *testing.T) {
+	tests := map[float64]float64{
+		100:     0,
+		0:       0,
+		-10:     10,
+		-100:    25,
+		-5000:   100,
+		-1234.5: 25,
+		-2000.1: 40,
+	}
+	for closing, want := range tests {
+		if got := MinimumPayment(closing); got != want {
+			t.Errorf("closing balance %v: expected minimum payment %v but got %v", closing, want, got)
+		}
+	}
+}
+
+func TestParsePeriod(t *testing.T) {
+	p, err := ParsePeriod("2024-12")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !p.Start.Equal(date(2024, 12, 1)) || !p.End.Equal(date(2025, 1, 1)) {
+		t.Errorf("expected December 2024 but got %v - %v", p.Start, p.End)
+	}
+	if !p.DueDate().Equal(date(2025, 1, 22)) {
+		t.Errorf("expected due date 2025-01-22 but got %v", p.DueDate())
+	}
+	if p.Next().Key() != "2025-01" {
+		t.Errorf("expected next period 2025-01 but got %s", p.Next().Key())
+	}
+	for _, invalid := range []string{"", "2024-13", "2024-1", "24-01"} {
+		if _, err := ParsePeriod(invalid); err != ErrInvalidPeriod {
+			t.Errorf("%q: expected ErrInvalidPeriod but got %v", invalid, err)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	store := &StubStorer{
+		accounts: []Account{{WalletID: 2, Next: PeriodOf(date(2024, 2, 10))}},
+		paid:     map[string]float64{"2024-02": 25, "2024-03": 24.99},
+		fees:     map[string]float64{},
+	}
+	now := time.Date(2024, 4, 25, 12, 0, 0, 0, time.UTC)
+
+	if err := Run(context.Background(), store, now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := Run(context.Background(), store, now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(store.statements) != 2 {
+		t.Fatalf("expected statements for February and March only but got %+v", store.statements)
+	}
+	if got := store.fees["2024-02"]; got != 0 {
+		t.Errorf("expected no late fee when the minimum was paid, got %v", got)
+	}
+	if got := store.fees["2024-03"]; got != LateFee {
+		t.Errorf("expected a late fee when the minimum was not paid, got %v", got)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	store := &StubStorer{statements: []Statement{{WalletID: 2, Period: "2024-03", MinimumPayment: 25}}}
+	h := New(store)
+
+	get := func(id, period string) *httptest.ResponseRecorder {
+		e := echo.New()
+		rec := httptest.NewRecorder()
+		c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+		if period == "" {
+			c.SetParamNames("id")
+			c.SetParamValues(id)
+			_ = h.StatementsHandler(c)
+		} else {
+			c.SetParamNames("id", "period")
+			c.SetParamValues(id, period)
+			_ = h.StatementHandler(c)
+		}
+		return rec
+	}
+
+	t.Run("list", func(t *testing.T) {
+		rec := get("2", "")
+		var got []Statement
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil || len(got) != 1 {
+			t.Errorf("expected one statement but got %d %s", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("period", func(t *testing.T) {
+		if rec := get("2", "2024-03"); rec.Code != http.StatusOK {
+			t.Errorf("expected status 200 but got %d", rec.Code)
+		}
+		if rec := get("2", "2024-04"); rec.Code != http.StatusNotFound {
+			t.Errorf("expected status 404 but got %d", rec.Code)
+		}
+		if rec := get("2", "March"); rec.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400 but got %d", rec.Code)
+		}
+	})
+}
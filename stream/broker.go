@@ -0,0 +1,79 @@
+// Package stream serves live wallet changes as Server-Sent Events.
+package stream
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/outbox"
+)
+
+// subscriberBuffer is how many events a subscriber can fall behind
+// before it is dropped; its client reconnects and catches up from the
+// outbox with Last-Event-ID.
+const subscriberBuffer = 64
+
+// Broker fans the wallet events of this replica's listener out to the
+// streams it serves.
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[*subscriber]struct{}
+}
+
+type subscriber struct {
+	userID int
+	events chan outbox.Message
+}
+
+func NewBroker() *Broker {
+	return &Broker{subscribers: map[*subscriber]struct{}{}}
+}
+
+// Subscribe returns the events of the user's wallets, or of all wallets
+// when userID is 0, from now on. The channel is closed when the
+// subscriber falls too far behind; cancel ends the subscription.
+func (b *Broker) Subscribe(userID int) (<-chan outbox.Message, func()) {
+	s := &subscriber{userID: userID, events: make(chan outbox.Message, subscriberBuffer)}
+	b.mu.Lock()
+	b.subscribers[s] = struct{}{}
+	b.mu.Unlock()
+	return s.events, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[s]; ok {
+			delete(b.subscribers, s)
+			close(s.events)
+		}
+	}
+}
+
+// Publish hands a wallet event to its subscribers without blocking.
+func (b *Broker) Publish(message outbox.Message) {
+	userID := userOf(message)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for s := range b.subscribers {
+		if s.userID != 0 && s.userID != userID {
+			continue
+		}
+		select {
+		case s.events <- message:
+		default:
+			delete(b.subscribers, s)
+			close(s.events)
+		}
+	}
+}
+
+// userOf reads the user of the wallet an event is about.
+func userOf(message outbox.Message) int {
+	var event struct {
+		Data struct {
+			Wallet struct {
+				UserID int `json:"user_id"`
+			} `json:"wallet"`
+		} `json:"data"`
+	}
+	json.Unmarshal(message.Payload, &event)
+	return event.Data.Wallet.UserID
+}
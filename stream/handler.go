@@ -0,0 +1,178 @@
+package stream
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/outbox"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// HeaderLastEventID is sent by EventSource when it reconnects.
+	HeaderLastEventID = "Last-Event-ID"
+
+	// replayBatch is how many missed events are read at a time.
+	replayBatch = 100
+	// heartbeat keeps idle streams from being closed by proxies.
+	heartbeat = 15 * time.Second
+	// retry is how long clients wait before reconnecting, in ms.
+	retry = 3000
+)
+
+type Handler struct {
+	store  Storer
+	broker *Broker
+}
+
+type Storer interface {
+	// WalletEventsSince returns up to limit events after the id, oldest
+	// first, of the user's wallets or of all wallets when userID is 0.
+	WalletEventsSince(afterID int64, userID int, limit int) ([]outbox.Message, error)
+}
+
+func New(db Storer, broker *Broker) *Handler {
+	return &Handler{store: db, broker: broker}
+}
+
+type Err struct {
+	Message string `json:"message"`
+}
+
+// WalletsStream
+//
+//	@Summary		Stream wallet changes
+//	@Description	Stream wallet.created, wallet.updated, wallet.balance_changed, wallet.deleted and wallet.holding_changed events as Server-Sent Events, with the webhook payload as data. Send the id of the last event received as the Last-Event-ID header, or the last_event_id query parameter, to first receive the events missed since.
+//	@Tags			wallet
+//	@Produce		text/event-stream
+//	@Param			Last-Event-ID	header	int	false	"Resume after this event"
+//	@Param			last_event_id	query	int	false	"Resume after this event, for clients that cannot set headers"
+//	@Success		200	{string}	string
+//	@Router			/api/v1/wallets/stream [get]
+//	@Failure		400	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Security		ApiKeyAuth
+func (h *Handler) WalletsStream(c echo.Context) error {
+	return h.serve(c, 0)
+}
+
+// UserWalletsStream
+//
+//	@Summary		Stream wallet changes of a user
+//	@Description	Like /api/v1/wallets/stream, for the wallets of one user.
+//	@Tags			wallet
+//	@Produce		text/event-stream
+//	@Param			id				path	int	true	"User ID"
+//	@Param			Last-Event-ID	header	int	false	"Resume after this event"
+//	@Param			last_event_id	query	int	false	"Resume after this event, for clients that cannot set headers"
+//	@Success		200	{string}	string
+//	@Router			/api/v1/users/{id}/wallets/stream [get]
+//	@Failure		400	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Security		ApiKeyAuth
+func (h *Handler) UserWalletsStream(c echo.Context) error {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || userID <= 0 {
+		return c.JSON(http.StatusBadRequest, Err{Message: "invalid user id"})
+	}
+	return h.serve(c, userID)
+}
+
+func (h *Handler) serve(c echo.Context, userID int) error {
+	lastID, resume, err := lastEventID(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+
+	// Subscribing before reading the missed events means none is lost in
+	// between; those received twice are skipped.
+	events, cancel := h.broker.Subscribe(userID)
+	defer cancel()
+	var missed []outbox.Message
+	if resume {
+		if missed, err = h.store.WalletEventsSince(lastID, userID, replayBatch); err != nil {
+			return c.JSON(http.StatusInternalServerError, Err{Message: err.Error()})
+		}
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set(echo.HeaderConnection, "keep-alive")
+	// Tells nginx not to buffer the stream.
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+	fmt.Fprintf(res, "retry: %d\n\n", retry)
+
+	replayed := map[int64]bool{}
+	for len(missed) > 0 {
+		for _, m := range missed {
+			if err := write(res, m); err != nil {
+				return nil
+			}
+			replayed[m.ID] = true
+			lastID = m.ID
+		}
+		if len(missed) < replayBatch {
+			break
+		}
+		if missed, err = h.store.WalletEventsSince(lastID, userID, replayBatch); err != nil {
+			// The client resumes from the last event it got.
+			c.Logger().Errorf("wallet stream replay failed: %v", err)
+			return nil
+		}
+	}
+	res.Flush()
+
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+		case <-ticker.C:
+			if _, err := io.WriteString(res, ": ping\n\n"); err != nil {
+				return nil
+			}
+		case m, ok := <-events:
+			if !ok {
+				// Dropped for falling behind; the client reconnects and
+				// resumes.
+				return nil
+			}
+			if replayed[m.ID] {
+				continue
+			}
+			if err := write(res, m); err != nil {
+				return nil
+			}
+		}
+		res.Flush()
+	}
+}
+
+// lastEventID reads the id to resume after, if any.
+func lastEventID(c echo.Context) (int64, bool, error) {
+	value := c.Request().Header.Get(HeaderLastEventID)
+	if value == "" {
+		value = c.QueryParam("last_event_id")
+	}
+	if value == "" {
+		return 0, false, nil
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id < 0 {
+		return 0, false, fmt.Errorf("invalid last event id %q", value)
+	}
+	return id, true, nil
+}
+
+// write sends m as an event named by its type, with its id so that the
+// client can resume after it.
+func write(w io.Writer, m outbox.Message) error {
+	_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", m.ID, m.Type, m.Payload)
+	return err
+}
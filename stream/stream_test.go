@@ -0,0 +1,193 @@
+package stream
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/outbox"
+	"github.com/labstack/echo/v4"
+)
+
+type StubStorer struct {
+	events []outbox.Message
+	err    error
+}
+
+func (s *StubStorer) WalletEventsSince(afterID int64, userID int, limit int) ([]outbox.Message, error) {
+	var result []outbox.Message
+	for _, m := range s.events {
+		if m.ID > afterID && (userID == 0 || userOf(m) == userID) && len(result) < limit {
+			result = append(result, m)
+		}
+	}
+	return result, s.err
+}
+
+func event(id int64, userID int, eventType string) outbox.Message {
+	payload := fmt.Sprintf(`{"id":"evt_%d","type":%q,"data":{"wallet":{"id":%d,"user_id":%d}}}`, id, eventType, id, userID)
+	return outbox.Message{ID: id, EventID: fmt.Sprintf("evt_%d", id), WalletID: int(id), Type: eventType, Payload: json.RawMessage(payload)}
+}
+
+type sse struct {
+	id        string
+	eventType string
+	data      string
+}
+
+// open starts a stream and returns its events as they are read.
+func open(t *testing.T, h *Handler, path string, header http.Header) (int, <-chan sse) {
+	e := echo.New()
+	e.GET("/wallets/stream", h.WalletsStream)
+	e.GET("/users/:id/wallets/stream", h.UserWalletsStream)
+	server := httptest.NewServer(e)
+	t.Cleanup(server.Close)
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+path, nil)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("unable to open stream: %v", err)
+	}
+	t.Cleanup(func() { res.Body.Close() })
+
+	events := make(chan sse, 10)
+	go func() {
+		defer close(events)
+		var ev sse
+		scanner := bufio.NewScanner(res.Body)
+		for scanner.Scan() {
+			key, value, _ := strings.Cut(scanner.Text(), ": ")
+			switch key {
+			case "id":
+				ev.id = value
+			case "event":
+				ev.eventType = value
+			case "data":
+				ev.data = value
+			case "":
+				if ev.id != "" {
+					events <- ev
+				}
+				ev = sse{}
+			}
+		}
+	}()
+	return res.StatusCode, events
+}
+
+func next(t *testing.T, events <-chan sse) sse {
+	t.Helper()
+	select {
+	case ev := <-events:
+		return ev
+	case <-time.After(time.Second):
+		t.Fatal("expected an event")
+		return sse{}
+	}
+}
+
+func TestStream(t *testing.T) {
+	t.Run("given live events should stream them", func(t *testing.T) {
+		broker := NewBroker()
+		code, events := open(t, New(&StubStorer{}, broker), "/wallets/stream", nil)
+		if code != http.StatusOK {
+			t.Fatalf("expected status code %d but got %d", http.StatusOK, code)
+		}
+
+		waitSubscribed(t, broker)
+		broker.Publish(event(7, 1, "wallet.created"))
+
+		ev := next(t, events)
+		if ev.id != "7" || ev.eventType != "wallet.created" || !strings.Contains(ev.data, `"evt_7"`) {
+			t.Errorf("expected wallet.created event 7 but got %+v", ev)
+		}
+	})
+
+	t.Run("given Last-Event-ID should replay missed events first", func(t *testing.T) {
+		broker := NewBroker()
+		store := &StubStorer{events: []outbox.Message{
+			event(1, 1, "wallet.created"),
+			event(2, 1, "wallet.balance_changed"),
+			event(3, 2, "wallet.created"),
+		}}
+		_, events := open(t, New(store, broker), "/wallets/stream", http.Header{HeaderLastEventID: {"1"}})
+
+		waitSubscribed(t, broker)
+		// Already replayed, so not sent again.
+		broker.Publish(event(3, 2, "wallet.created"))
+		broker.Publish(event(4, 1, "wallet.deleted"))
+
+		var got []string
+		for i := 0; i < 3; i++ {
+			got = append(got, next(t, events).id)
+		}
+		if strings.Join(got, ",") != "2,3,4" {
+			t.Errorf("expected events 2,3,4 but got %v", got)
+		}
+	})
+
+	t.Run("given user should only stream their wallets", func(t *testing.T) {
+		broker := NewBroker()
+		store := &StubStorer{events: []outbox.Message{event(1, 1, "wallet.created"), event(2, 2, "wallet.created")}}
+		_, events := open(t, New(store, broker), "/users/2/wallets/stream?last_event_id=0", nil)
+
+		waitSubscribed(t, broker)
+		broker.Publish(event(3, 1, "wallet.updated"))
+		broker.Publish(event(4, 2, "wallet.updated"))
+
+		for _, want := range []string{"2", "4"} {
+			if ev := next(t, events); ev.id != want {
+				t.Errorf("expected event %s of user 2 but got %+v", want, ev)
+			}
+		}
+	})
+
+	t.Run("given invalid Last-Event-ID should return 400", func(t *testing.T) {
+		code, _ := open(t, New(&StubStorer{}, NewBroker()), "/wallets/stream", http.Header{HeaderLastEventID: {"abc"}})
+		if code != http.StatusBadRequest {
+			t.Errorf("expected status code %d but got %d", http.StatusBadRequest, code)
+		}
+	})
+}
+
+func TestBroker(t *testing.T) {
+	broker := NewBroker()
+	events, cancel := broker.Subscribe(0)
+	defer cancel()
+
+	for i := 0; i <= subscriberBuffer; i++ {
+		broker.Publish(event(int64(i+1), 1, "wallet.updated"))
+	}
+
+	n := 0
+	for range events {
+		n++
+	}
+	if n != subscriberBuffer {
+		t.Errorf("expected a slow subscriber to get %d events then be dropped, got %d", subscriberBuffer, n)
+	}
+}
+
+// waitSubscribed waits for the stream to subscribe, which it does before
+// sending the response headers.
+func waitSubscribed(t *testing.T, broker *Broker) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		broker.mu.Lock()
+		n := len(broker.subscribers)
+		broker.mu.Unlock()
+		if n > 0 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("expected the stream to subscribe")
+}
@@ -0,0 +1,176 @@
+package transfer
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet"
+	"github.com/labstack/echo/v4"
+)
+
+type Handler struct {
+	store Storer
+}
+
+type Storer interface {
+	WalletTransfers(walletID int) ([]Transfer, error)
+	Transfer(transferID int) (Transfer, error)
+	CreateTransfer(createTransfer CreateTransfer, firstRun time.Time, actor wallet.Actor) (Transfer, error)
+	CancelTransfer(transferID int) (Transfer, error)
+	TransferRuns(transferID int) ([]Run, error)
+	// ExecuteDueTransfer claims one transfer due at now that no other
+	// scheduler holds, attempts its run and records the outcome. It
+	// returns ErrNoneDue when there is nothing left to do.
+	ExecuteDueTransfer(now time.Time) (Run, error)
+}
+
+func New(db Storer) *Handler {
+	return &Handler{store: db}
+}
+
+type Err struct {
+	Message string `json:"message"`
+}
+
+func errStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrNotFound), errors.Is(err, wallet.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrInvalidTransfer):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrNotActive):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// WalletTransfersHandler
+//
+//	@Summary		List scheduled transfers of a wallet
+//	@Description	List the scheduled transfers from or to a wallet
+//	@Tags			transfer
+//	@Produce		json
+//	@Success		200	{array}		Transfer
+//	@Router			/api/v1/wallets/{id}/transfers [get]
+//	@Failure		400	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Param			id path int true "Wallet ID"
+//	@Security		ApiKeyAuth
+func (h *Handler) WalletTransfersHandler(c echo.Context) error {
+	walletID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: "invalid wallet id"})
+	}
+	transfers, err := h.store.WalletTransfers(walletID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, Err{Message: err.Error()})
+	}
+	return c.JSON(http.StatusOK, transfers)
+}
+
+// TransferHandler
+//
+//	@Summary		Get scheduled transfer
+//	@Description	Get a scheduled transfer with its next run and last error
+//	@Tags			transfer
+//	@Produce		json
+//	@Success		200	{object}	Transfer
+//	@Router			/api/v1/transfers/{id} [get]
+//	@Failure		400	{object}	Err
+//	@Failure		404	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Param			id path int true "Transfer ID"
+//	@Security		ApiKeyAuth
+func (h *Handler) TransferHandler(c echo.Context) error {
+	transferID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: "invalid transfer id"})
+	}
+	result, err := h.store.Transfer(transferID)
+	if err != nil {
+		return c.JSON(errStatus(err), Err{Message: err.Error()})
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+// TransferRunsHandler
+//
+//	@Summary		List scheduled transfer runs
+//	@Description	List every attempt at a scheduled transfer, newest first, with the error of failed ones
+//	@Tags			transfer
+//	@Produce		json
+//	@Success		200	{array}		Run
+//	@Router			/api/v1/transfers/{id}/runs [get]
+//	@Failure		400	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Param			id path int true "Transfer ID"
+//	@Security		ApiKeyAuth
+func (h *Handler) TransferRunsHandler(c echo.Context) error {
+	transferID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: "invalid transfer id"})
+	}
+	runs, err := h.store.TransferRuns(transferID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, Err{Message: err.Error()})
+	}
+	return c.JSON(http.StatusOK, runs)
+}
+
+// CreateTransfer
+//
+//	@Summary		Schedule a transfer
+//	@Description	Schedule a one-off transfer at start_at, or a recurring one following an RRULE such as FREQ=MONTHLY;BYMONTHDAY=25 (FREQ DAILY, WEEKLY or MONTHLY with INTERVAL, BYDAY, BYMONTHDAY, COUNT and UNTIL)
+//	@Tags			transfer
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		CreateTransfer	true	"Request Body"
+//	@Success		201		{object}	Transfer
+//	@Router			/api/v1/transfers [post]
+//	@Failure		400		{object}	Err
+//	@Failure		404		{object}	Err
+//	@Failure		500		{object}	Err
+//	@Security		ApiKeyAuth
+func (h *Handler) CreateTransfer(c echo.Context) error {
+	var createTransfer CreateTransfer
+	if err := c.Bind(&createTransfer); err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+	firstRun, err := createTransfer.Validate(time.Now())
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+	result, err := h.store.CreateTransfer(createTransfer, firstRun, wallet.ActorFrom(c))
+	if err != nil {
+		return c.JSON(errStatus(err), Err{Message: err.Error()})
+	}
+	return c.JSON(http.StatusCreated, result)
+}
+
+// CancelTransfer
+//
+//	@Summary		Cancel a scheduled transfer
+//	@Description	Cancel all future runs of an active scheduled transfer
+//	@Tags			transfer
+//	@Produce		json
+//	@Param			id path int true "Transfer ID"
+//	@Success		200	{object}	Transfer
+//	@Router			/api/v1/transfers/{id} [delete]
+//	@Failure		400	{object}	Err
+//	@Failure		404	{object}	Err
+//	@Failure		409	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Security		ApiKeyAuth
+func (h *Handler) CancelTransfer(c echo.Context) error {
+	transferID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: "invalid transfer id"})
+	}
+	result, err := h.store.CancelTransfer(transferID)
+	if err != nil {
+		return c.JSON(errStatus(err), Err{Message: err.Error()})
+	}
+	return c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,34 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+// SchedulerJob returns a job that executes every transfer due. Several
+// replicas may run it at once: each transfer is claimed by one of them.
+func SchedulerJob(store Storer) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		return Execute(ctx, store, time.Now())
+	}
+}
+
+// Execute runs transfers due at now until none is left. Failed runs are
+// recorded and retried later rather than returned.
+func Execute(ctx context.Context, store Storer, now time.Time) error {
+	for ctx.Err() == nil {
+		run, err := store.ExecuteDueTransfer(now)
+		if errors.Is(err, ErrNoneDue) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !run.Succeeded {
+			log.Printf("scheduled transfer %d attempt %d failed: %s", run.TransferID, run.Attempt, run.Error)
+		}
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,163 @@
+package transfer
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rule is the subset of iCalendar RRULE (RFC 5545) supported for
+// recurring transfers, e.g. "FREQ=MONTHLY;BYMONTHDAY=25":
+//
+//   - FREQ: DAILY, WEEKLY or MONTHLY
+//   - INTERVAL: every n days, weeks or months, default 1
+//   - BYDAY: weekdays of a WEEKLY rule, e.g. MO,TH, default the weekday of the first run
+//   - BYMONTHDAY: day of a MONTHLY rule, 1 to 31 or -1 for the last day,
+//     default the day of the first run; months too short for it use their last day
+//   - COUNT: number of runs
+//   - UNTIL: last time a run may happen, as 20240131T000000Z
+//
+// Runs happen at the time of day of the first run, in UTC.
+type Rule struct {
+	Freq       string
+	Interval   int
+	ByDay      []time.Weekday
+	ByMonthDay int
+	Count      int
+	Until      *time.Time
+}
+
+const (
+	Daily   = "DAILY"
+	Weekly  = "WEEKLY"
+	Monthly = "MONTHLY"
+
+	untilLayout = "20060102T150405Z"
+	// maxOccurrences bounds the search for the next run of a rule.
+	maxOccurrences = 100000
+)
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+func ParseRule(s string) (Rule, error) {
+	r := Rule{Interval: 1}
+	for _, part := range strings.Split(strings.TrimPrefix(strings.ToUpper(s), "RRULE:"), ";") {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return r, fmt.Errorf("%w: malformed recurrence part %q", ErrInvalidTransfer, part)
+		}
+		var err error
+		switch name {
+		case "FREQ":
+			r.Freq = value
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(value)
+			if err == nil && r.Interval < 1 {
+				err = fmt.Errorf("must be positive")
+			}
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				weekday, ok := weekdays[day]
+				if !ok {
+					err = fmt.Errorf("unknown weekday %q", day)
+					break
+				}
+				r.ByDay = append(r.ByDay, weekday)
+			}
+		case "BYMONTHDAY":
+			r.ByMonthDay, err = strconv.Atoi(value)
+			if err == nil && (r.ByMonthDay == 0 || r.ByMonthDay < -1 || r.ByMonthDay > 31) {
+				err = fmt.Errorf("must be 1 to 31 or -1")
+			}
+		case "COUNT":
+			r.Count, err = strconv.Atoi(value)
+			if err == nil && r.Count < 1 {
+				err = fmt.Errorf("must be positive")
+			}
+		case "UNTIL":
+			var until time.Time
+			until, err = time.Parse(untilLayout, value)
+			r.Until = &until
+		default:
+			err = fmt.Errorf("not supported")
+		}
+		if err != nil {
+			return r, fmt.Errorf("%w: recurrence %s: %v", ErrInvalidTransfer, name, err)
+		}
+	}
+	switch {
+	case r.Freq != Daily && r.Freq != Weekly && r.Freq != Monthly:
+		return r, fmt.Errorf("%w: recurrence FREQ must be DAILY, WEEKLY or MONTHLY", ErrInvalidTransfer)
+	case len(r.ByDay) > 0 && r.Freq != Weekly:
+		return r, fmt.Errorf("%w: recurrence BYDAY needs FREQ=WEEKLY", ErrInvalidTransfer)
+	case r.ByMonthDay != 0 && r.Freq != Monthly:
+		return r, fmt.Errorf("%w: recurrence BYMONTHDAY needs FREQ=MONTHLY", ErrInvalidTransfer)
+	}
+	sort.Slice(r.ByDay, func(i, j int) bool { return mondayFirst(r.ByDay[i]) < mondayFirst(r.ByDay[j]) })
+	return r, nil
+}
+
+// Next returns the first run of the rule after the given time, for a
+// rule whose first run is at start. It reports false once the rule has
+// no more runs.
+func (r Rule) Next(start, after time.Time) (time.Time, bool) {
+	start = start.UTC()
+	for i, n := 0, 0; i < maxOccurrences; i++ {
+		for _, t := range r.occurrences(start, i) {
+			if t.Before(start) {
+				continue
+			}
+			n++
+			if (r.Count > 0 && n > r.Count) || (r.Until != nil && t.After(*r.Until)) {
+				return time.Time{}, false
+			}
+			if t.After(after) {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// occurrences returns the runs in the i-th day, week or month of the
+// rule, in order.
+func (r Rule) occurrences(start time.Time, i int) []time.Time {
+	switch r.Freq {
+	case Weekly:
+		days := r.ByDay
+		if len(days) == 0 {
+			days = []time.Weekday{start.Weekday()}
+		}
+		monday := start.AddDate(0, 0, -mondayFirst(start.Weekday())+7*r.Interval*i)
+		result := make([]time.Time, len(days))
+		for j, day := range days {
+			result[j] = monday.AddDate(0, 0, mondayFirst(day))
+		}
+		return result
+	case Monthly:
+		first := time.Date(start.Year(), start.Month()+time.Month(r.Interval*i), 1,
+			start.Hour(), start.Minute(), start.Second(), 0, time.UTC)
+		last := first.AddDate(0, 1, -1).Day()
+		day := r.ByMonthDay
+		switch {
+		case day == 0:
+			day = start.Day()
+		case day == -1:
+			day = last
+		}
+		if day > last {
+			day = last
+		}
+		return []time.Time{first.AddDate(0, 0, day-1)}
+	}
+	return []time.Time{start.AddDate(0, 0, r.Interval*i)}
+}
+
+func mondayFirst(d time.Weekday) int {
+	return (int(d) + 6) % 7
+}
@@ -0,0 +1,147 @@
+package transfer
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusActive Status = "active"
+	// StatusCompleted transfers have no runs left.
+	StatusCompleted Status = "completed"
+	// StatusFailed one-off transfers gave up after MaxAttempts.
+	StatusFailed    Status = "failed"
+	StatusCancelled Status = "cancelled"
+)
+
+const (
+	// MaxAttempts is how often a run is tried before it is given up and
+	// the transfer moves on to its next run.
+	MaxAttempts = 5
+	// RetryBackoff is the wait before the first retry; it doubles with
+	// every attempt.
+	RetryBackoff = time.Minute
+)
+
+var (
+	ErrNotFound        = errors.New("scheduled transfer not found")
+	ErrInvalidTransfer = errors.New("invalid scheduled transfer")
+	ErrNotActive       = errors.New("scheduled transfer is no longer active")
+	// ErrNoneDue is returned by ExecuteDueTransfer when no transfer is due.
+	ErrNoneDue = errors.New("no scheduled transfer due")
+)
+
+// Transfer is a standing order to move Amount from one wallet to
+// another, once at NextRunAt or repeatedly following Recurrence.
+type Transfer struct {
+	ID           int        `json:"id" example:"1"`
+	FromWalletID int        `json:"from_wallet_id" example:"1"`
+	ToWalletID   int        `json:"to_wallet_id" example:"2"`
+	Amount       float64    `json:"amount" example:"500.00"`
+	Description  string     `json:"description" example:"Pay off credit card"`
+	Recurrence   string     `json:"recurrence,omitempty" example:"FREQ=MONTHLY;BYMONTHDAY=25"`
+	StartAt      time.Time  `json:"start_at" example:"2024-04-25T09:00:00Z"`
+	NextRunAt    *time.Time `json:"next_run_at,omitempty" example:"2024-04-25T09:00:00Z"`
+	Status       Status     `json:"status" example:"active"`
+	Attempts     int        `json:"attempts" example:"0"`
+	LastRunAt    *time.Time `json:"last_run_at,omitempty" example:"2024-03-25T09:00:00Z"`
+	LastError    string     `json:"last_error,omitempty" example:"insufficient funds"`
+	CreatedBy    string     `json:"created_by" example:"api-key:3"`
+	CreatedAt    time.Time  `json:"created_at" example:"2024-03-20T14:19:00.729237Z"`
+}
+
+type CreateTransfer struct {
+	FromWalletID int     `json:"from_wallet_id" example:"1"`
+	ToWalletID   int     `json:"to_wallet_id" example:"2"`
+	Amount       float64 `json:"amount" example:"500.00"`
+	Description  string  `json:"description" example:"Pay off credit card"`
+	// StartAt is the first run, or the only one without Recurrence.
+	StartAt    time.Time `json:"start_at" example:"2024-04-25T09:00:00Z"`
+	Recurrence string    `json:"recurrence,omitempty" example:"FREQ=MONTHLY;BYMONTHDAY=25"`
+}
+
+// Validate checks the transfer and returns when it first runs, which is
+// StartAt or, if StartAt does not match Recurrence, its first run after.
+func (c CreateTransfer) Validate(now time.Time) (time.Time, error) {
+	switch {
+	case c.FromWalletID == c.ToWalletID:
+		return time.Time{}, fmt.Errorf("%w: from_wallet_id and to_wallet_id must differ", ErrInvalidTransfer)
+	case c.Amount <= 0 || math.Round(c.Amount*100) != c.Amount*100:
+		return time.Time{}, fmt.Errorf("%w: amount must be positive with at most two decimals", ErrInvalidTransfer)
+	case c.StartAt.Before(now.Add(-time.Minute)):
+		return time.Time{}, fmt.Errorf("%w: start_at must not be in the past", ErrInvalidTransfer)
+	}
+	if c.Recurrence == "" {
+		return c.StartAt.UTC(), nil
+	}
+	rule, err := ParseRule(c.Recurrence)
+	if err != nil {
+		return time.Time{}, err
+	}
+	first, ok := rule.Next(c.StartAt, c.StartAt.Add(-time.Nanosecond))
+	if !ok {
+		return time.Time{}, fmt.Errorf("%w: recurrence has no runs", ErrInvalidTransfer)
+	}
+	return first, nil
+}
+
+// Run is one attempt at a scheduled transfer.
+type Run struct {
+	ID           int       `json:"id" example:"1"`
+	TransferID   int       `json:"transfer_id" example:"1"`
+	ScheduledFor time.Time `json:"scheduled_for" example:"2024-04-25T09:00:00Z"`
+	Attempt      int       `json:"attempt" example:"1"`
+	Succeeded    bool      `json:"succeeded" example:"true"`
+	Error        string    `json:"error,omitempty" example:"insufficient funds"`
+	CreatedAt    time.Time `json:"created_at" example:"2024-04-25T09:00:03Z"`
+}
+
+// Advance returns t after an attempt at its run at NextRunAt, made at
+// now, that failed with err or succeeded if err is nil. Failed runs are
+// retried with exponential backoff up to MaxAttempts, then skipped.
+func (t Transfer) Advance(now time.Time, err error) (Transfer, error) {
+	if err != nil {
+		t.Attempts++
+		t.LastError = err.Error()
+		if t.Attempts < MaxAttempts {
+			retry := now.Add(RetryBackoff << (t.Attempts - 1))
+			t.NextRunAt = &retry
+			return t, nil
+		}
+	} else {
+		t.LastError = ""
+		t.LastRunAt = &now
+	}
+	t.Attempts = 0
+
+	next, ok := time.Time{}, false
+	if t.Recurrence != "" {
+		rule, err := ParseRule(t.Recurrence)
+		if err != nil {
+			return t, err
+		}
+		// Runs missed while the scheduler was down are skipped rather
+		// than made all at once.
+		next, ok = rule.Next(t.StartAt, now)
+	}
+	switch {
+	case ok:
+		t.NextRunAt = &next
+	case err != nil:
+		t.NextRunAt, t.Status = nil, StatusFailed
+	default:
+		t.NextRunAt, t.Status = nil, StatusCompleted
+	}
+	return t, nil
+}
+
+// References returns the ledger references of the two legs of the run
+// scheduled for at, so a run is posted at most once.
+func References(transferID int, at time.Time) (from, to string) {
+	base := fmt.Sprintf("transfer:%d:%d", transferID, at.Unix())
+	return base + ":from", base + ":to"
+}
@@ -0,0 +1,234 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet"
+	"github.com/labstack/echo/v4"
+)
+
+func at(year int, month time.Month, day, hour int) time.Time {
+	return time.Date(year, month, day, hour, 0, 0, 0, time.UTC)
+}
+
+type StubStorer struct {
+	transfers []Transfer
+	runs      []Run
+	fail      error
+}
+
+func (s *StubStorer) WalletTransfers(walletID int) ([]Transfer, error) { return s.transfers, nil }
+
+func (s *StubStorer) Transfer(transferID int) (Transfer, error) {
+	for _, t := range s.transfers {
+		if t.ID == transferID {
+			return t, nil
+		}
+	}
+	return Transfer{}, ErrNotFound
+}
+
+func (s *StubStorer) CreateTransfer(createTransfer CreateTransfer, firstRun time.Time, actor wallet.Actor) (Transfer, error) {
+	t := Transfer{ID: len(s.transfers) + 1, FromWalletID: createTransfer.FromWalletID, ToWalletID: createTransfer.ToWalletID,
+		Amount: createTransfer.Amount, Recurrence: createTransfer.Recurrence, StartAt: createTransfer.StartAt,
+		NextRunAt: &firstRun, Status: StatusActive}
+	s.transfers = append(s.transfers, t)
+	return t, nil
+}
+
+func (s *StubStorer) CancelTransfer(transferID int) (Transfer, error) {
+	for i, t := range s.transfers {
+		if t.ID == transferID {
+			if t.Status != StatusActive {
+				return t, ErrNotActive
+			}
+			s.transfers[i].Status, s.transfers[i].NextRunAt = StatusCancelled, nil
+			return s.transfers[i], nil
+		}
+	}
+	return Transfer{}, ErrNotFound
+}
+
+func (s *StubStorer) TransferRuns(transferID int) ([]Run, error) { return s.runs, nil }
+
+func (s *StubStorer) ExecuteDueTransfer(now time.Time) (Run, error) {
+	for i, t := range s.transfers {
+		if t.Status != StatusActive || t.NextRunAt.After(now) {
+			continue
+		}
+		run := Run{TransferID: t.ID, ScheduledFor: *t.NextRunAt, Attempt: t.Attempts + 1, Succeeded: s.fail == nil}
+		next, err := t.Advance(now, s.fail)
+		if err != nil {
+			return run, err
+		}
+		s.transfers[i] = next
+		s.runs = append(s.runs, run)
+		return run, nil
+	}
+	return Run{}, ErrNoneDue
+}
+
+func TestRuleNext(t *testing.T) {
+	tests := []struct {
+		rule  string
+		start time.Time
+		after time.Time
+		want  []time.Time
+	}{
+		{"FREQ=MONTHLY;BYMONTHDAY=25", at(2024, 1, 25, 9), at(2024, 1, 25, 9),
+			[]time.Time{at(2024, 2, 25, 9), at(2024, 3, 25, 9), at(2024, 4, 25, 9)}},
+		{"FREQ=MONTHLY", at(2024, 1, 31, 9), at(2024, 1, 31, 9),
+			[]time.Time{at(2024, 2, 29, 9), at(2024, 3, 31, 9), at(2024, 4, 30, 9)}},
+		{"FREQ=MONTHLY;BYMONTHDAY=-1;INTERVAL=2", at(2024, 1, 10, 0), at(2024, 1, 10, 0),
+			[]time.Time{at(2024, 1, 31, 0), at(2024, 3, 31, 0), at(2024, 5, 31, 0)}},
+		{"FREQ=WEEKLY;BYDAY=TH,MO", at(2024, 4, 2, 8), at(2024, 4, 2, 8),
+			[]time.Time{at(2024, 4, 4, 8), at(2024, 4, 8, 8), at(2024, 4, 11, 8)}},
+		{"FREQ=DAILY;INTERVAL=3;COUNT=3", at(2024, 4, 1, 0), at(2024, 3, 1, 0),
+			[]time.Time{at(2024, 4, 1, 0), at(2024, 4, 4, 0), at(2024, 4, 7, 0)}},
+		{"FREQ=DAILY;UNTIL=20240402T000000Z", at(2024, 4, 1, 0), at(2024, 3, 1, 0),
+			[]time.Time{at(2024, 4, 1, 0), at(2024, 4, 2, 0)}},
+	}
+	for _, tt := range tests {
+		rule, err := ParseRule(tt.rule)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.rule, err)
+		}
+		var got []time.Time
+		for after := tt.after; ; {
+			next, ok := rule.Next(tt.start, after)
+			if !ok || len(got) == len(tt.want) {
+				break
+			}
+			got = append(got, next)
+			after = next
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: expected %v but got %v", tt.rule, tt.want, got)
+			continue
+		}
+		for i := range got {
+			if !got[i].Equal(tt.want[i]) {
+				t.Errorf("%s: expected %v but got %v", tt.rule, tt.want, got)
+				break
+			}
+		}
+	}
+
+	rule, _ := ParseRule("FREQ=DAILY;COUNT=2")
+	if _, ok := rule.Next(at(2024, 4, 1, 0), at(2024, 4, 2, 0)); ok {
+		t.Errorf("expected no run after COUNT runs")
+	}
+
+	for _, invalid := range []string{"", "FREQ=YEARLY", "FREQ=DAILY;BYDAY=MO", "FREQ=WEEKLY;BYDAY=XX", "FREQ=MONTHLY;BYMONTHDAY=32", "FREQ=DAILY;INTERVAL=0", "FREQ=DAILY;BYHOUR=9"} {
+		if _, err := ParseRule(invalid); !errors.Is(err, ErrInvalidTransfer) {
+			t.Errorf("%q: expected ErrInvalidTransfer but got %v", invalid, err)
+		}
+	}
+}
+
+func TestAdvance(t *testing.T) {
+	start := at(2024, 1, 25, 9)
+	now := at(2024, 2, 25, 9)
+	monthly := Transfer{Recurrence: "FREQ=MONTHLY;BYMONTHDAY=25", StartAt: start, NextRunAt: &now, Status: StatusActive}
+
+	t.Run("given success should move to the next run", func(t *testing.T) {
+		got, _ := monthly.Advance(now, nil)
+		if !got.NextRunAt.Equal(at(2024, 3, 25, 9)) || got.Status != StatusActive || *got.LastRunAt != now {
+			t.Errorf("unexpected %+v", got)
+		}
+	})
+
+	t.Run("given failure should retry with backoff, then skip the run", func(t *testing.T) {
+		got := monthly
+		var retries []time.Duration
+		for i := 0; i < MaxAttempts; i++ {
+			got, _ = got.Advance(now, wallet.ErrInsufficientFunds)
+			retries = append(retries, got.NextRunAt.Sub(now))
+		}
+		want := []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute}
+		for i, d := range want {
+			if retries[i] != d {
+				t.Errorf("retry %d: expected %v but got %v", i+1, d, retries[i])
+			}
+		}
+		if !got.NextRunAt.Equal(at(2024, 3, 25, 9)) || got.Attempts != 0 || got.Status != StatusActive {
+			t.Errorf("expected the run to be skipped after %d attempts, got %+v", MaxAttempts, got)
+		}
+		if got.LastError != wallet.ErrInsufficientFunds.Error() {
+			t.Errorf("expected last error to be reported, got %q", got.LastError)
+		}
+	})
+
+	t.Run("given one-off transfer should complete or fail", func(t *testing.T) {
+		once := Transfer{StartAt: now, NextRunAt: &now, Status: StatusActive}
+		if got, _ := once.Advance(now, nil); got.Status != StatusCompleted || got.NextRunAt != nil {
+			t.Errorf("expected completed, got %+v", got)
+		}
+		once.Attempts = MaxAttempts - 1
+		if got, _ := once.Advance(now, wallet.ErrWalletFrozen); got.Status != StatusFailed {
+			t.Errorf("expected failed, got %+v", got)
+		}
+	})
+}
+
+func TestExecute(t *testing.T) {
+	now := at(2024, 4, 25, 9)
+	due := now.Add(-time.Minute)
+	later := now.Add(time.Hour)
+	store := &StubStorer{transfers: []Transfer{
+		{ID: 1, StartAt: due, NextRunAt: &due, Status: StatusActive},
+		{ID: 2, StartAt: later, NextRunAt: &later, Status: StatusActive},
+	}}
+
+	if err := Execute(context.Background(), store, now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(store.runs) != 1 || store.runs[0].TransferID != 1 {
+		t.Errorf("expected only the due transfer to run, got %+v", store.runs)
+	}
+	if store.transfers[0].Status != StatusCompleted || store.transfers[1].Status != StatusActive {
+		t.Errorf("unexpected transfers %+v", store.transfers)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	do := func(handler echo.HandlerFunc, id, body string) *httptest.ResponseRecorder {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+		_ = handler(c)
+		return rec
+	}
+	h := New(&StubStorer{})
+	start := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+	rec := do(h.CreateTransfer, "", `{"from_wallet_id":1,"to_wallet_id":2,"amount":500,"start_at":"`+start+`","recurrence":"FREQ=MONTHLY;BYMONTHDAY=25"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status 201 but got %d %s", rec.Code, rec.Body)
+	}
+	if rec := do(h.CreateTransfer, "", `{"from_wallet_id":1,"to_wallet_id":1,"amount":500,"start_at":"`+start+`"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for a transfer to the same wallet but got %d", rec.Code)
+	}
+	if rec := do(h.CreateTransfer, "", `{"from_wallet_id":1,"to_wallet_id":2,"amount":500,"start_at":"2020-01-01T00:00:00Z"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for a start in the past but got %d", rec.Code)
+	}
+	if rec := do(h.CancelTransfer, "1", ``); rec.Code != http.StatusOK {
+		t.Errorf("expected status 200 but got %d", rec.Code)
+	}
+	if rec := do(h.CancelTransfer, "1", ``); rec.Code != http.StatusConflict {
+		t.Errorf("expected status 409 but got %d", rec.Code)
+	}
+	if rec := do(h.TransferHandler, "9", ``); rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 but got %d", rec.Code)
+	}
+}
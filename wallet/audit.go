@@ -0,0 +1,78 @@
+package wallet
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/apikey"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionRestore = "restore"
+	ActionPurge   = "purge"
+	ActionStatus  = "status"
+	// ActionTransaction is a balance change posted through the ledger.
+	ActionTransaction = "transaction"
+	// ActionHolding is a change to a Crypto Wallet's asset holdings.
+	ActionHolding = "holding"
+	// ActionHold places, captures, voids or expires an authorization hold.
+	ActionHold = "hold"
+	// ActionTransfer schedules a transfer from the wallet.
+	ActionTransfer = "transfer"
+)
+
+// Actor is who performed a mutation, recorded with it in the audit log.
+type Actor struct {
+	Name      string
+	RequestID string
+}
+
+type AuditEntry struct {
+	ID        int             `json:"id" example:"1"`
+	Actor     string          `json:"actor" example:"api-key:3"`
+	Action    string          `json:"action" example:"update"`
+	WalletID  int             `json:"wallet_id" example:"1"`
+	Before    json.RawMessage `json:"before" swaggertype:"object"`
+	After     json.RawMessage `json:"after" swaggertype:"object"`
+	Reason    string          `json:"reason,omitempty" example:"Suspected fraud, case #4711"`
+	RequestID string          `json:"request_id" example:"3DbPZ0rxmDCJ2dXVuyIYkSeB6sNBWtXk"`
+	CreatedAt time.Time       `json:"created_at" example:"2024-03-25T14:19:00.729237Z"`
+}
+
+type AuditFilter struct {
+	Actor    string
+	Action   string
+	WalletID int
+	From     *time.Time
+	To       *time.Time
+	Limit    int
+}
+
+// ActorFrom identifies the caller by the API key that authenticated the
+// request and the request ID assigned by the RequestID middleware.
+func ActorFrom(c echo.Context) Actor {
+	key, _ := apikey.FromContext(c)
+	requestID := c.Response().Header().Get(echo.HeaderXRequestID)
+	if requestID == "" {
+		requestID = c.Request().Header.Get(echo.HeaderXRequestID)
+	}
+	return ActorOf(key, requestID)
+}
+
+// ActorOf returns the actor of a request authenticated by key, or of an
+// anonymous one when key is the zero APIKey.
+func ActorOf(key apikey.APIKey, requestID string) Actor {
+	actor := Actor{Name: "anonymous", RequestID: requestID}
+	switch {
+	case key.ID != 0:
+		actor.Name = "api-key:" + strconv.Itoa(key.ID)
+	case key.Name != "":
+		actor.Name = key.Name
+	}
+	return actor
+}
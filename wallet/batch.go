@@ -0,0 +1,107 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	BatchCreate = "create"
+	BatchUpdate = "update"
+	BatchDelete = "delete"
+
+	MaxBatchOperations = 100
+)
+
+// BatchOperation is one change of a batch. Op picks the field it uses:
+// Create, Update, or UserID, whose wallets a delete soft-deletes.
+type BatchOperation struct {
+	Op     string        `json:"op" example:"create" enums:"create,update,delete"`
+	Create *CreateWallet `json:"create,omitempty"`
+	Update *UpdateWallet `json:"update,omitempty"`
+	UserID int           `json:"user_id,omitempty" example:"1"`
+}
+
+// Batch is a list of changes that are made together or not at all.
+type Batch struct {
+	Operations []BatchOperation `json:"operations"`
+}
+
+// BatchResult is the outcome of an operation: the wallet created or
+// updated, or the user whose wallets were deleted.
+type BatchResult struct {
+	Op     string  `json:"op" example:"create"`
+	Wallet *Wallet `json:"wallet,omitempty"`
+	UserID int     `json:"user_id,omitempty" example:"1"`
+}
+
+type BatchReport struct {
+	Results []BatchResult `json:"results"`
+}
+
+// Validate checks every operation on its own, before any is applied.
+func (b Batch) Validate() error {
+	if len(b.Operations) == 0 {
+		return fmt.Errorf("%w: batch has no operations", ErrInvalidWallet)
+	}
+	if len(b.Operations) > MaxBatchOperations {
+		return fmt.Errorf("%w: a batch may have at most %d operations", ErrInvalidWallet, MaxBatchOperations)
+	}
+	for i, op := range b.Operations {
+		var err error
+		switch op.Op {
+		case BatchCreate:
+			if op.Create == nil {
+				err = fmt.Errorf("%w: create needs a create object", ErrInvalidWallet)
+			} else {
+				err = op.Create.Validate()
+			}
+		case BatchUpdate:
+			if op.Update == nil {
+				err = fmt.Errorf("%w: update needs an update object", ErrInvalidWallet)
+			} else {
+				err = op.Update.Validate()
+			}
+		case BatchDelete:
+			if op.UserID == 0 {
+				err = fmt.Errorf("%w: delete needs user_id", ErrInvalidWallet)
+			}
+		default:
+			err = fmt.Errorf("%w: op must be one of %s, %s, %s", ErrInvalidWallet, BatchCreate, BatchUpdate, BatchDelete)
+		}
+		if err != nil {
+			return fmt.Errorf("operation %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// Apply makes the changes of the batch through store, in order, and
+// stops at the first that fails. Run it in a transaction, see
+// Transactor, for the batch to be all or nothing.
+func (b Batch) Apply(store Storer, actor Actor) ([]BatchResult, error) {
+	results := make([]BatchResult, 0, len(b.Operations))
+	for i, op := range b.Operations {
+		result := BatchResult{Op: op.Op}
+		var w Wallet
+		var err error
+		switch op.Op {
+		case BatchCreate:
+			w, err = store.CreateWallet(*op.Create, actor)
+			result.Wallet = &w
+		case BatchUpdate:
+			w, err = store.UpdateWallet(*op.Update, actor)
+			result.Wallet = &w
+		case BatchDelete:
+			err = store.DeleteWallet(op.UserID, actor)
+			result.UserID = op.UserID
+		default:
+			err = errors.New("unknown op " + op.Op)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i+1, err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
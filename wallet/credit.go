@@ -0,0 +1,86 @@
+package wallet
+
+import (
+	"errors"
+	"math"
+)
+
+var (
+	ErrInsufficientFunds   = errors.New("insufficient funds")
+	ErrCreditLimitExceeded = errors.New("credit limit exceeded")
+)
+
+// A Credit Card balance is the cardholder's position: negative when they
+// owe money, positive when they have overpaid. It may go down to minus
+// the credit limit. Every other wallet type must stay at or above zero.
+
+// Derive fills in the fields computed from Balance, Held and
+// CreditLimit. Stores call it on every wallet they return.
+func (w *Wallet) Derive() {
+	w.AvailableBalance = round(w.Balance - w.Held)
+	w.AvailableCredit, w.OutstandingBalance = nil, nil
+	if w.WalletType != TypeCreditCard {
+		w.CreditLimit = nil
+		return
+	}
+	limit := w.creditLimit()
+	available := round(limit + w.AvailableBalance)
+	outstanding := round(math.Max(0, -w.Balance))
+	w.CreditLimit = &limit
+	w.AvailableCredit = &available
+	w.OutstandingBalance = &outstanding
+}
+
+func (w Wallet) creditLimit() float64 {
+	if w.CreditLimit == nil {
+		return 0
+	}
+	return *w.CreditLimit
+}
+
+// checkFloor checks that balance, less the funds held, is not below the
+// lowest balance w may reach.
+func (w Wallet) checkFloor(balance float64) error {
+	balance = round(balance - w.Held)
+	if w.WalletType == TypeCreditCard {
+		if balance < -w.creditLimit() {
+			return ErrCreditLimitExceeded
+		}
+		return nil
+	}
+	if balance < 0 {
+		return ErrInsufficientFunds
+	}
+	return nil
+}
+
+// ApplyUpdate checks that before may be changed as described by update,
+// which must already be valid, and returns the changed wallet. A credit
+// limit left out of the update is kept if the type does not change.
+func ApplyUpdate(before Wallet, update UpdateWallet) (Wallet, error) {
+	if err := CheckBalanceChange(before, update.Balance); err != nil {
+		return before, err
+	}
+	after := before
+	after.UserID = update.UserID
+	after.UserName = update.UserName
+	after.WalletName = update.WalletName
+	after.WalletType = update.WalletType
+	after.Balance = update.Balance
+	if update.CreditLimit != nil || update.WalletType != before.WalletType {
+		after.CreditLimit = update.CreditLimit
+	}
+	// A lower limit or a type change must still cover the new balance,
+	// but an unrelated edit of a wallet already over its limit is fine.
+	if after.creditLimit() != before.creditLimit() || after.WalletType != before.WalletType {
+		if err := after.checkFloor(after.Balance); err != nil {
+			return before, err
+		}
+	}
+	after.Derive()
+	return after, nil
+}
+
+func round(f float64) float64 {
+	return math.Round(f*100) / 100
+}
@@ -0,0 +1,151 @@
+package wallet
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+const (
+	ExportCSV     = "csv"
+	ExportNDJSON  = "ndjson"
+	ExportParquet = "parquet"
+
+	// parquetRowGroupSize is how many Parquet rows are buffered before
+	// they are flushed to the output, which bounds the memory of an export.
+	parquetRowGroupSize = 10000
+)
+
+var ErrUnsupportedExport = errors.New("format must be csv, ndjson or parquet")
+
+// Encoder writes wallets one at a time in an export format. Close must
+// be called to complete the output.
+type Encoder interface {
+	Encode(w Wallet) error
+	Close() error
+}
+
+// NewEncoder returns an Encoder writing format to out, and the content
+// type of the output.
+func NewEncoder(format string, out io.Writer) (Encoder, string, error) {
+	switch format {
+	case ExportCSV:
+		e := &csvEncoder{w: csv.NewWriter(out)}
+		return e, "text/csv", e.w.Write(exportColumns)
+	case ExportNDJSON:
+		return &ndjsonEncoder{e: json.NewEncoder(out)}, MIMENDJSON, nil
+	case ExportParquet:
+		return &parquetEncoder{w: parquet.NewGenericWriter[parquetWallet](out)}, "application/vnd.apache.parquet", nil
+	}
+	return nil, "", ErrUnsupportedExport
+}
+
+// Export writes every wallet of the filter to out, as the store reads
+// them, without holding them in memory.
+func Export(store Exporter, filter Filter, enc Encoder) error {
+	if err := store.EachWallet(filter, enc.Encode); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// The CSV columns start with those of an import, so an export can be
+// imported again.
+var exportColumns = []string{"user_id", "user_name", "wallet_name", "wallet_type", "balance", "credit_limit",
+	"id", "status", "created_at", "deleted_at"}
+
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func (e *csvEncoder) Encode(w Wallet) error {
+	return e.w.Write([]string{
+		strconv.Itoa(w.UserID), w.UserName, w.WalletName, w.WalletType,
+		strconv.FormatFloat(w.Balance, 'f', 2, 64), formatOptional(w.CreditLimit),
+		strconv.Itoa(w.ID), string(w.Status), w.CreatedAt.Format(time.RFC3339Nano), formatTime(w.DeletedAt),
+	})
+}
+
+func (e *csvEncoder) Close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func formatOptional(f *float64) string {
+	if f == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*f, 'f', 2, 64)
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+type ndjsonEncoder struct {
+	e *json.Encoder
+}
+
+func (e *ndjsonEncoder) Encode(w Wallet) error {
+	return e.e.Encode(w)
+}
+
+func (e *ndjsonEncoder) Close() error {
+	return nil
+}
+
+type parquetWallet struct {
+	ID          int64     `parquet:"id"`
+	UserID      int64     `parquet:"user_id"`
+	UserName    string    `parquet:"user_name"`
+	WalletName  string    `parquet:"wallet_name"`
+	WalletType  string    `parquet:"wallet_type,dict"`
+	Balance     float64   `parquet:"balance"`
+	CreditLimit *float64  `parquet:"credit_limit,optional"`
+	Status      string    `parquet:"status,dict"`
+	CreatedAt   time.Time `parquet:"created_at,timestamp(microsecond)"`
+	// Zero values of optional fields are written as nulls.
+	DeletedAt time.Time `parquet:"deleted_at,optional,timestamp(microsecond)"`
+}
+
+type parquetEncoder struct {
+	w    *parquet.GenericWriter[parquetWallet]
+	rows int
+}
+
+func (e *parquetEncoder) Encode(w Wallet) error {
+	row := parquetWallet{
+		ID:          int64(w.ID),
+		UserID:      int64(w.UserID),
+		UserName:    w.UserName,
+		WalletName:  w.WalletName,
+		WalletType:  w.WalletType,
+		Balance:     w.Balance,
+		CreditLimit: w.CreditLimit,
+		Status:      string(w.Status),
+		CreatedAt:   w.CreatedAt,
+	}
+	if w.DeletedAt != nil {
+		row.DeletedAt = *w.DeletedAt
+	}
+	_, err := e.w.Write([]parquetWallet{row})
+	if err != nil {
+		return err
+	}
+	if e.rows++; e.rows%parquetRowGroupSize == 0 {
+		return e.w.Flush()
+	}
+	return nil
+}
+
+func (e *parquetEncoder) Close() error {
+	return e.w.Close()
+}
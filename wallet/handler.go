@@ -1,9 +1,14 @@
 package wallet
 
 import (
+	"context"
+	"errors"
+	"log"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/KKGo-Software-engineering/fun-exercise-api/apikey"
 	"github.com/labstack/echo/v4"
 )
 
@@ -11,13 +16,61 @@ type Handler struct {
 	store Storer
 }
 
+// Storer is implemented by the stores of wallets; storertest checks
+// that they keep its contract. Listings are in id order and empty rather
+// than nil when nothing matches, and errors are returned, never swallowed.
 type Storer interface {
-	Wallets() ([]Wallet, error)
-	WalletsByType(walletType string) ([]Wallet, error)
+	Wallets(filter Filter) ([]Wallet, error)
+	Exporter
+	// WalletByUser returns the newest wallet of the user that is not
+	// deleted, or ErrNotFound.
 	WalletByUser(userID int) (Wallet, error)
-	CreateWallet(createWallet CreateWallet) (Wallet, error)
-	DeleteWallet(userID int) error
-	UpdateWallet(updateWallet UpdateWallet) (Wallet, error)
+	CreateWallet(createWallet CreateWallet, actor Actor) (Wallet, error)
+	// ImportWallets creates all of the wallets, in order, or none.
+	ImportWallets(createWallets []CreateWallet, actor Actor) ([]Wallet, error)
+	DeleteWallet(userID int, actor Actor) error
+	UpdateWallet(updateWallet UpdateWallet, actor Actor) (Wallet, error)
+	RestoreWallet(walletID int, actor Actor) (Wallet, error)
+	ChangeWalletStatus(walletID int, changeStatus ChangeStatus, actor Actor) (Wallet, error)
+	WalletAudit(walletID int) ([]AuditEntry, error)
+	AuditEntries(filter AuditFilter) ([]AuditEntry, error)
+}
+
+// Exporter streams wallets without loading them all at once. fn may call
+// the store.
+type Exporter interface {
+	EachWallet(filter Filter, fn func(Wallet) error) error
+}
+
+// Transactor is implemented by the stores that can run several calls as
+// one unit of work. WithTx runs fn with a Storer bound to a transaction,
+// committing if fn returns nil and rolling back otherwise, so that either
+// every change fn makes is kept or none. fn may run more than once, when
+// the store retries a transaction that conflicted with another, so it
+// must not have side effects outside tx. fn must make its calls through
+// tx, since calls to the store itself may wait for the transaction to
+// end. Calling WithTx on tx joins its transaction.
+type Transactor interface {
+	WithTx(ctx context.Context, fn func(tx Storer) error) error
+}
+
+// Purger hard-deletes wallets that were soft-deleted before a cut-off.
+type Purger interface {
+	PurgeDeletedWallets(before time.Time, actor Actor) (int, error)
+}
+
+// PurgeJob returns a job that purges wallets deleted more than retention ago.
+func PurgeJob(purger Purger, retention time.Duration) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		n, err := purger.PurgeDeletedWallets(time.Now().Add(-retention), Actor{Name: "system:purge"})
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			log.Printf("purged %d wallets deleted more than %v ago", n, retention)
+		}
+		return nil
+	}
 }
 
 func New(db Storer) *Handler {
@@ -28,42 +81,127 @@ type Err struct {
 	Message string `json:"message"`
 }
 
+// errStatus maps errors from the store to an HTTP status code.
+func errStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrInvalidWallet):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrInvalidTransition),
+		errors.Is(err, ErrBalanceNotZero),
+		errors.Is(err, ErrWalletFrozen),
+		errors.Is(err, ErrWalletClosed),
+		errors.Is(err, ErrInsufficientFunds),
+		errors.Is(err, ErrCreditLimitExceeded):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
 // WalletHandler
 //
 //		@Summary		Get all wallets
-//		@Description	Get all wallets
+//		@Description	Get all wallets. Soft-deleted wallets are only listed for admins passing include_deleted. The Accept header picks JSON (default), XML, CSV or MessagePack.
 //		@Tags			wallet
 //		@Accept			json
 //		@Produce		json
+//		@Produce		xml
+//		@Produce		text/csv
+//		@Produce		application/msgpack
 //		@Success		200	{object}	Wallet
 //		@Router			/api/v1/wallets [get]
+//		@Failure		403	{object}	Err
 //		@Failure		500	{object}	Err
+//		@Failure		406	{object}	Err
+//		@Security		ApiKeyAuth
 //	 	@Param          wallet_type query string false "wallet type"
+//	 	@Param          include_deleted query bool false "include soft-deleted wallets (admin only)"
 func (h *Handler) WalletHandler(c echo.Context) error {
-	var wallets []Wallet
-	var err error
-	walletType := c.QueryParam("wallet_type")
-	if walletType != "" {
-		wallets, err = h.store.WalletsByType(walletType)
-	} else {
-		wallets, err = h.store.Wallets()
+	filter, ok := listFilter(c)
+	if !ok {
+		return c.JSON(http.StatusForbidden, Err{Message: "include_deleted requires admin scope"})
 	}
+	wallets, err := h.store.Wallets(filter)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, Err{Message: err.Error()})
 	}
-	return c.JSON(http.StatusOK, wallets)
+	return render(c, http.StatusOK, wallets)
+}
+
+// listFilter reads the filter of a wallet listing from the query. It
+// reports false if the caller may not see soft-deleted wallets but asked
+// for them.
+func listFilter(c echo.Context) (Filter, bool) {
+	filter := Filter{WalletType: c.QueryParam("wallet_type")}
+	if c.QueryParam("include_deleted") == "true" {
+		if key, ok := apikey.FromContext(c); !ok || !key.HasScope(apikey.ScopeAdmin) {
+			return filter, false
+		}
+		filter.IncludeDeleted = true
+	}
+	return filter, true
+}
+
+// ExportWallets
+//
+//	@Summary		Export wallets
+//	@Description	Stream all wallets matching the same filters as the listing, as CSV, NDJSON or Parquet. Rows are read through a database cursor, so exports of any size use constant memory.
+//	@Tags			wallet
+//	@Produce		text/csv
+//	@Produce		application/x-ndjson
+//	@Produce		application/vnd.apache.parquet
+//	@Param			format			query	string	false	"csv (default), ndjson or parquet"	Enums(csv, ndjson, parquet)
+//	@Param			wallet_type		query	string	false	"wallet type"
+//	@Param			include_deleted	query	bool	false	"include soft-deleted wallets (admin only)"
+//	@Success		200				{file}	file
+//	@Router			/api/v1/wallets:export [get]
+//	@Failure		400				{object}	Err
+//	@Failure		403				{object}	Err
+//	@Security		ApiKeyAuth
+func (h *Handler) ExportWallets(c echo.Context) error {
+	filter, ok := listFilter(c)
+	if !ok {
+		return c.JSON(http.StatusForbidden, Err{Message: "include_deleted requires admin scope"})
+	}
+	format := c.QueryParam("format")
+	if format == "" {
+		format = ExportCSV
+	}
+	res := c.Response()
+	enc, contentType, err := NewEncoder(format, res)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+	res.Header().Set(echo.HeaderContentType, contentType)
+	res.Header().Set(echo.HeaderContentDisposition, "attachment; filename=wallets."+format)
+	// The status is sent with the first row, so an error after that can
+	// only cut the export short; it is logged instead.
+	if err := Export(h.store, filter, enc); err != nil {
+		if !res.Committed {
+			return c.JSON(http.StatusInternalServerError, Err{Message: err.Error()})
+		}
+		c.Logger().Errorf("wallet export failed: %v", err)
+	}
+	return nil
 }
 
 // WalletHandlerByUser
 //
 //		@Summary		Get wallet by user Id
-//		@Description	Get wallet by user Id
+//		@Description	Get wallet by user Id, as JSON (default), XML, CSV or MessagePack following the Accept header
 //		@Tags			wallet
 //		@Accept			json
 //		@Produce		json
+//		@Produce		xml
+//		@Produce		text/csv
+//		@Produce		application/msgpack
 //		@Success		200	{object}	Wallet
 //		@Router			/api/v1/users/{id}/wallets [get]
+//		@Failure		404	{object}	Err
 //		@Failure		500	{object}	Err
+//		@Failure		406	{object}	Err
+//		@Security		ApiKeyAuth
 //	 	@Param          id path int true "User ID"
 func (h *Handler) WalletHandlerByUser(c echo.Context) error {
 	userId, err := strconv.Atoi(c.Param("id"))
@@ -71,13 +209,16 @@ func (h *Handler) WalletHandlerByUser(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, Err{Message: "Unable to find wallet!"})
 	}
 	result, err := h.store.WalletByUser(userId)
+	if errors.Is(err, ErrNotFound) {
+		return c.JSON(http.StatusNotFound, Err{Message: "Unable to find wallet!"})
+	}
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, Err{Message: "Unable to find wallet!"})
 	}
 	if result.UserID != userId {
 		return c.JSON(http.StatusNotFound, Err{Message: "Unable to find wallet!"})
 	}
-	return c.JSON(http.StatusOK, result)
+	return render(c, http.StatusOK, result)
 }
 
 // CreateWallet
@@ -89,37 +230,144 @@ func (h *Handler) WalletHandlerByUser(c echo.Context) error {
 //		@Produce		json
 //		@Success		200	{object}	Wallet
 //		@Router			/api/v1/wallets [post]
+//		@Failure		400	{object}	Err
 //		@Failure		500	{object}	Err
+//		@Security		ApiKeyAuth
 //	 	@Param 			CreateWallet body CreateWallet true "Body for create wallet"
 func (h *Handler) CreateWallet(c echo.Context) error {
 	var createWallet CreateWallet
 	if err := c.Bind(&createWallet); err != nil {
 		return err
 	}
-	result, err := h.store.CreateWallet(createWallet)
+	if err := createWallet.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+	result, err := h.store.CreateWallet(createWallet, ActorFrom(c))
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, err)
 	}
 	return c.JSON(http.StatusOK, result)
 }
 
+// ImportWallets
+//
+//	@Summary		Import wallets
+//	@Description	Create many wallets from a CSV file with a header (user_id,user_name,wallet_name,wallet_type,balance,credit_limit) or from NDJSON, one wallet object per line. Every row is validated; in atomic mode (the default) one invalid row fails the whole import with 422, in best_effort mode the valid rows are created. The report has a result per row, numbered from 1 by data row for CSV and by line for NDJSON.
+//	@Tags			wallet
+//	@Accept			text/csv
+//	@Accept			application/x-ndjson
+//	@Produce		json
+//	@Param			mode	query		string	false	"atomic (default) or best_effort"	Enums(atomic, best_effort)
+//	@Success		201		{object}	ImportReport	"Every row was imported"
+//	@Success		200		{object}	ImportReport	"Some rows were imported, see the report"
+//	@Router			/api/v1/wallets:import [post]
+//	@Failure		400		{object}	Err
+//	@Failure		413		{object}	Err
+//	@Failure		415		{object}	Err
+//	@Failure		422		{object}	ImportReport	"An atomic import had invalid rows; nothing was imported"
+//	@Failure		500		{object}	Err
+//	@Security		ApiKeyAuth
+func (h *Handler) ImportWallets(c echo.Context) error {
+	mode := c.QueryParam("mode")
+	if mode == "" {
+		mode = ImportAtomic
+	}
+	if mode != ImportAtomic && mode != ImportBestEffort {
+		return c.JSON(http.StatusBadRequest, Err{Message: "mode must be atomic or best_effort"})
+	}
+	rows, err := ParseImport(c.Request().Header.Get(echo.HeaderContentType), c.Request().Body)
+	switch {
+	case errors.Is(err, ErrUnsupportedImport):
+		return c.JSON(http.StatusUnsupportedMediaType, Err{Message: err.Error()})
+	case errors.Is(err, ErrTooManyRows):
+		return c.JSON(http.StatusRequestEntityTooLarge, Err{Message: err.Error()})
+	case err != nil:
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	case len(rows) == 0:
+		return c.JSON(http.StatusBadRequest, Err{Message: "import has no rows"})
+	}
+
+	var valid []CreateWallet
+	for _, row := range rows {
+		if row.Err == nil {
+			valid = append(valid, row.Wallet)
+		}
+	}
+	if mode == ImportAtomic && len(valid) < len(rows) {
+		return c.JSON(http.StatusUnprocessableEntity, Report(mode, rows, nil))
+	}
+	var created []Wallet
+	if len(valid) > 0 {
+		created, err = h.store.ImportWallets(valid, ActorFrom(c))
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, Err{Message: err.Error()})
+		}
+	}
+	report := Report(mode, rows, created)
+	if report.Failed > 0 {
+		return c.JSON(http.StatusOK, report)
+	}
+	return c.JSON(http.StatusCreated, report)
+}
+
+// BatchWallets
+//
+//	@Summary		Change wallets in one transaction
+//	@Description	Create, update and delete wallets in one transaction: the operations are applied in order and, if any fails, none is kept. The error names the operation that failed.
+//	@Tags			wallet
+//	@Accept			json
+//	@Produce		json
+//	@Param			Batch	body		Batch	true	"Operations, at most 100"
+//	@Success		200		{object}	BatchReport
+//	@Router			/api/v1/wallets:batch [post]
+//	@Failure		400		{object}	Err
+//	@Failure		404		{object}	Err
+//	@Failure		409		{object}	Err
+//	@Failure		500		{object}	Err
+//	@Failure		501		{object}	Err	"The store has no transactions"
+//	@Security		ApiKeyAuth
+func (h *Handler) BatchWallets(c echo.Context) error {
+	var batch Batch
+	if err := c.Bind(&batch); err != nil {
+		return err
+	}
+	if err := batch.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+	transactor, ok := h.store.(Transactor)
+	if !ok {
+		return c.JSON(http.StatusNotImplemented, Err{Message: "the store has no transactions"})
+	}
+	var results []BatchResult
+	err := transactor.WithTx(c.Request().Context(), func(tx Storer) error {
+		var err error
+		results, err = batch.Apply(tx, ActorFrom(c))
+		return err
+	})
+	if err != nil {
+		return c.JSON(errStatus(err), Err{Message: err.Error()})
+	}
+	return c.JSON(http.StatusOK, BatchReport{Results: results})
+}
+
 // DeleteWallet
 //
 //		@Summary		Delete wallet by user Id
-//		@Description	Delete wallet by user Id
+//		@Description	Soft-delete the wallets of a user. They can be restored until purged after the retention period.
 //		@Tags			wallet
 //		@Accept			json
 //		@Produce		plain
 //		@Success		200	{object}	Wallet
 //		@Router			/api/v1/users/{id}/wallets [delete]
 //		@Failure		500	{object}	Err
+//		@Security		ApiKeyAuth
 //	 	@Param          id path int true "User ID"
 func (h *Handler) DeleteWallet(c echo.Context) error {
 	userId, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, Err{Message: "Unable to find wallet!"})
 	}
-	err = h.store.DeleteWallet(userId)
+	err = h.store.DeleteWallet(userId, ActorFrom(c))
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, Err{Message: err.Error()})
 	}
@@ -129,22 +377,184 @@ func (h *Handler) DeleteWallet(c echo.Context) error {
 // UpdateWallet
 //
 //		@Summary		Update wallet
-//		@Description	Update wallet
+//		@Description	Update wallet. Debits must not take the balance below zero, or below minus the credit limit for Credit Card wallets.
 //		@Tags			wallet
 //		@Accept			json
 //		@Produce		json
 //		@Success		200	{object}	Wallet
 //		@Router			/api/v1/wallets [patch]
+//		@Failure		400	{object}	Err
+//		@Failure		409	{object}	Err
 //		@Failure		500	{object}	Err
+//		@Security		ApiKeyAuth
 //	 	@Param 			UpdateWallet body UpdateWallet true "Body for update wallet"
 func (h *Handler) UpdateWallet(c echo.Context) error {
 	var updateWallet UpdateWallet
 	if err := c.Bind(&updateWallet); err != nil {
 		return err
 	}
-	result, err := h.store.UpdateWallet(updateWallet)
+	if err := updateWallet.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+	result, err := h.store.UpdateWallet(updateWallet, ActorFrom(c))
+	if err != nil {
+		return c.JSON(errStatus(err), Err{Message: err.Error()})
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+// RestoreWallet
+//
+//	@Summary		Restore wallet
+//	@Description	Undo the soft delete of a wallet
+//	@Tags			wallet
+//	@Produce		json
+//	@Success		200	{object}	Wallet
+//	@Router			/api/v1/wallets/{id}/restore [post]
+//	@Failure		400	{object}	Err
+//	@Failure		404	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Param			id path int true "Wallet ID"
+//	@Security		ApiKeyAuth
+func (h *Handler) RestoreWallet(c echo.Context) error {
+	walletID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: "invalid wallet id"})
+	}
+	result, err := h.store.RestoreWallet(walletID, ActorFrom(c))
+	if errors.Is(err, ErrNotFound) {
+		return c.JSON(http.StatusNotFound, Err{Message: "Unable to find deleted wallet!"})
+	}
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, Err{Message: err.Error()})
 	}
 	return c.JSON(http.StatusOK, result)
 }
+
+// ChangeWalletStatus
+//
+//	@Summary		Change wallet status
+//	@Description	Freeze, close or reopen a wallet. Frozen wallets accept credits only, closed wallets accept no balance changes and can only be closed at zero balance.
+//	@Tags			wallet
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	Wallet
+//	@Router			/api/v1/wallets/{id}/status [post]
+//	@Failure		400	{object}	Err
+//	@Failure		404	{object}	Err
+//	@Failure		409	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Param			id path int true "Wallet ID"
+//	@Param			ChangeStatus body ChangeStatus true "New status and the reason for the change"
+//	@Security		ApiKeyAuth
+func (h *Handler) ChangeWalletStatus(c echo.Context) error {
+	walletID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: "invalid wallet id"})
+	}
+	var changeStatus ChangeStatus
+	if err := c.Bind(&changeStatus); err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+	if _, ok := transitions[changeStatus.Status]; !ok {
+		return c.JSON(http.StatusBadRequest, Err{Message: "status must be one of active, frozen, closed"})
+	}
+	if changeStatus.Reason == "" {
+		return c.JSON(http.StatusBadRequest, Err{Message: "reason is required"})
+	}
+	result, err := h.store.ChangeWalletStatus(walletID, changeStatus, ActorFrom(c))
+	if err != nil {
+		return c.JSON(errStatus(err), Err{Message: err.Error()})
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+// WalletAuditHandler
+//
+//	@Summary		Get wallet audit trail
+//	@Description	Get every recorded mutation of a wallet, oldest first
+//	@Tags			audit
+//	@Produce		json
+//	@Success		200	{array}		AuditEntry
+//	@Router			/api/v1/wallets/{id}/audit [get]
+//	@Failure		400	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Param			id path int true "Wallet ID"
+//	@Security		ApiKeyAuth
+func (h *Handler) WalletAuditHandler(c echo.Context) error {
+	walletID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: "invalid wallet id"})
+	}
+	entries, err := h.store.WalletAudit(walletID)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, Err{Message: err.Error()})
+	}
+	return c.JSON(http.StatusOK, entries)
+}
+
+// AuditHandler
+//
+//	@Summary		Query audit log
+//	@Description	Query the audit log across all wallets, newest first
+//	@Tags			audit
+//	@Produce		json
+//	@Success		200	{array}		AuditEntry
+//	@Router			/api/v1/admin/audit [get]
+//	@Failure		400	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Param			actor query string false "Actor, e.g. api-key:3"
+//	@Param			action query string false "Action" Enums(create, update, delete)
+//	@Param			wallet_id query int false "Wallet ID"
+//	@Param			from query string false "Entries at or after this RFC 3339 time"
+//	@Param			to query string false "Entries before this RFC 3339 time"
+//	@Param			limit query int false "Maximum number of entries, default 100, at most 1000"
+//	@Security		ApiKeyAuth
+func (h *Handler) AuditHandler(c echo.Context) error {
+	filter, err := parseAuditFilter(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+	entries, err := h.store.AuditEntries(filter)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, Err{Message: err.Error()})
+	}
+	return c.JSON(http.StatusOK, entries)
+}
+
+func parseAuditFilter(c echo.Context) (AuditFilter, error) {
+	filter := AuditFilter{
+		Actor:  c.QueryParam("actor"),
+		Action: c.QueryParam("action"),
+		Limit:  100,
+	}
+	err := echo.QueryParamsBinder(c).
+		Int("wallet_id", &filter.WalletID).
+		Int("limit", &filter.Limit).
+		CustomFunc("from", func(values []string) []error {
+			return parseTime(values, &filter.From)
+		}).
+		CustomFunc("to", func(values []string) []error {
+			return parseTime(values, &filter.To)
+		}).
+		BindError()
+	if err != nil {
+		return filter, err
+	}
+	switch {
+	case filter.Limit <= 0:
+		filter.Limit = 100
+	case filter.Limit > 1000:
+		filter.Limit = 1000
+	}
+	return filter, nil
+}
+
+func parseTime(values []string, dst **time.Time) []error {
+	t, err := time.Parse(time.RFC3339, values[0])
+	if err != nil {
+		return []error{err}
+	}
+	*dst = &t
+	return nil
+}
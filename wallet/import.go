@@ -0,0 +1,217 @@
+package wallet
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ImportAtomic imports every row or, if any row is invalid, none.
+	ImportAtomic = "atomic"
+	// ImportBestEffort imports the valid rows and reports the others.
+	ImportBestEffort = "best_effort"
+
+	MaxImportRows = 10000
+
+	MIMETextCSV = "text/csv"
+	MIMENDJSON  = "application/x-ndjson"
+)
+
+var (
+	ErrUnsupportedImport = errors.New("import must be text/csv or application/x-ndjson")
+	ErrTooManyRows       = fmt.Errorf("an import may have at most %d rows", MaxImportRows)
+)
+
+// importColumns are the columns of a CSV import, which must start with
+// a header naming them. credit_limit may be left out or empty.
+var importColumns = []string{"user_id", "user_name", "wallet_name", "wallet_type", "balance", "credit_limit"}
+
+// ImportRow is a wallet read from an import, or the reason it could not
+// be read. Row counts the data rows of a CSV from 1, not including its
+// header, and is the line of an NDJSON row, blank lines included.
+type ImportRow struct {
+	Row    int
+	Wallet CreateWallet
+	Err    error
+}
+
+type ImportResult struct {
+	Row      int    `json:"row" example:"1"`
+	Status   string `json:"status" example:"created"`
+	WalletID int    `json:"wallet_id,omitempty" example:"42"`
+	Error    string `json:"error,omitempty" example:"invalid wallet: balance must not be negative"`
+}
+
+const (
+	ImportCreated = "created"
+	ImportFailed  = "failed"
+	// ImportSkipped rows were valid but not imported because another
+	// row of an atomic import failed.
+	ImportSkipped = "skipped"
+)
+
+type ImportReport struct {
+	Mode    string         `json:"mode" example:"atomic"`
+	Total   int            `json:"total" example:"2"`
+	Created int            `json:"created" example:"2"`
+	Failed  int            `json:"failed" example:"0"`
+	Results []ImportResult `json:"results"`
+}
+
+// ParseImport reads the wallets of an import in the format named by
+// contentType and validates each of them.
+func ParseImport(contentType string, body io.Reader) ([]ImportRow, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	var rows []ImportRow
+	var err error
+	switch mediaType {
+	case MIMETextCSV:
+		rows, err = parseCSV(body)
+	case MIMENDJSON, "application/jsonl":
+		rows, err = parseNDJSON(body)
+	default:
+		return nil, ErrUnsupportedImport
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range rows {
+		if rows[i].Err == nil {
+			rows[i].Err = validateImport(rows[i].Wallet)
+		}
+	}
+	return rows, nil
+}
+
+func validateImport(c CreateWallet) error {
+	switch {
+	case c.UserID <= 0:
+		return fmt.Errorf("%w: user_id is required", ErrInvalidWallet)
+	case strings.TrimSpace(c.UserName) == "":
+		return fmt.Errorf("%w: user_name is required", ErrInvalidWallet)
+	case strings.TrimSpace(c.WalletName) == "":
+		return fmt.Errorf("%w: wallet_name is required", ErrInvalidWallet)
+	}
+	return c.Validate()
+}
+
+func parseCSV(body io.Reader) ([]ImportRow, error) {
+	r := csv.NewReader(body)
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing CSV header", ErrInvalidWallet)
+	}
+	index := map[string]int{}
+	for i, name := range header {
+		index[strings.TrimSpace(strings.ToLower(name))] = i
+	}
+	for _, name := range importColumns[:5] {
+		if _, ok := index[name]; !ok {
+			return nil, fmt.Errorf("%w: CSV header must have %s", ErrInvalidWallet, strings.Join(importColumns, ","))
+		}
+	}
+
+	var rows []ImportRow
+	for n := 1; ; n++ {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if len(rows) == MaxImportRows {
+			return nil, ErrTooManyRows
+		}
+		row := ImportRow{Row: n}
+		if err != nil {
+			row.Err = err
+		} else {
+			row.Wallet, row.Err = csvWallet(index, record)
+		}
+		rows = append(rows, row)
+	}
+}
+
+func csvWallet(index map[string]int, record []string) (CreateWallet, error) {
+	field := func(name string) string {
+		i, ok := index[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+	var c CreateWallet
+	var err error
+	if c.UserID, err = strconv.Atoi(field("user_id")); err != nil {
+		return c, fmt.Errorf("%w: user_id must be a number", ErrInvalidWallet)
+	}
+	c.UserName = field("user_name")
+	c.WalletName = field("wallet_name")
+	c.WalletType = field("wallet_type")
+	if c.Balance, err = strconv.ParseFloat(field("balance"), 64); err != nil {
+		return c, fmt.Errorf("%w: balance must be a number", ErrInvalidWallet)
+	}
+	if s := field("credit_limit"); s != "" {
+		limit, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return c, fmt.Errorf("%w: credit_limit must be a number", ErrInvalidWallet)
+		}
+		c.CreditLimit = &limit
+	}
+	return c, nil
+}
+
+func parseNDJSON(body io.Reader) ([]ImportRow, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var rows []ImportRow
+	for n := 1; scanner.Scan(); n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if len(rows) == MaxImportRows {
+			return nil, ErrTooManyRows
+		}
+		row := ImportRow{Row: n}
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&row.Wallet); err != nil {
+			row.Err = fmt.Errorf("%w: %v", ErrInvalidWallet, err)
+		}
+		rows = append(rows, row)
+	}
+	return rows, scanner.Err()
+}
+
+// Report pairs the rows of an import with the wallets created for its
+// valid rows, in order. An atomic import with an invalid row creates
+// nothing, so created is then empty.
+func Report(mode string, rows []ImportRow, created []Wallet) ImportReport {
+	report := ImportReport{Mode: mode, Total: len(rows), Results: make([]ImportResult, len(rows))}
+	next := 0
+	for i, row := range rows {
+		result := ImportResult{Row: row.Row}
+		switch {
+		case row.Err != nil:
+			result.Status, result.Error = ImportFailed, row.Err.Error()
+			report.Failed++
+		case next < len(created):
+			result.Status, result.WalletID = ImportCreated, created[next].ID
+			report.Created++
+			next++
+		default:
+			result.Status = ImportSkipped
+		}
+		report.Results[i] = result
+	}
+	return report
+}
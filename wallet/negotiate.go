@@ -0,0 +1,124 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	MIMEXML     = "application/xml"
+	MIMEMsgpack = "application/msgpack"
+)
+
+var ErrNotAcceptable = errors.New("Accept must allow application/json, application/xml, text/csv or application/msgpack")
+
+// renderers maps each media type a wallet listing can be rendered as to
+// the media type of the response.
+var renderers = map[string]string{
+	echo.MIMEApplicationJSON:  echo.MIMEApplicationJSON,
+	MIMEXML:                   MIMEXML,
+	"text/xml":                MIMEXML,
+	"text/csv":                "text/csv",
+	MIMEMsgpack:               MIMEMsgpack,
+	"application/x-msgpack":   MIMEMsgpack,
+	"application/vnd.msgpack": MIMEMsgpack,
+}
+
+// Negotiate picks the media type of a response from an Accept header,
+// preferring JSON when the client accepts anything.
+func Negotiate(accept string) (string, error) {
+	if strings.TrimSpace(accept) == "" {
+		return echo.MIMEApplicationJSON, nil
+	}
+	type candidate struct {
+		mediaType string
+		q         float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{mediaType, q})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	for _, c := range candidates {
+		switch c.mediaType {
+		case "*/*", "application/*":
+			return echo.MIMEApplicationJSON, nil
+		case "text/*":
+			return "text/csv", nil
+		}
+		if contentType, ok := renderers[c.mediaType]; ok {
+			return contentType, nil
+		}
+	}
+	return "", ErrNotAcceptable
+}
+
+// walletList is the XML document of a wallet listing.
+type walletList struct {
+	XMLName xml.Name `xml:"wallets"`
+	Wallets []Wallet `xml:"wallet"`
+}
+
+// render writes v, a Wallet or a []Wallet, in the media type the client
+// accepts, or 406 if it accepts none of them.
+func render(c echo.Context, code int, v any) error {
+	contentType, err := Negotiate(c.Request().Header.Get(echo.HeaderAccept))
+	if err != nil {
+		return c.JSON(http.StatusNotAcceptable, Err{Message: err.Error()})
+	}
+	c.Response().Header().Add(echo.HeaderVary, echo.HeaderAccept)
+	switch contentType {
+	case MIMEXML:
+		if wallets, ok := v.([]Wallet); ok {
+			return c.XML(code, walletList{Wallets: wallets})
+		}
+		return c.XML(code, v)
+	case "text/csv":
+		wallets, ok := v.([]Wallet)
+		if !ok {
+			wallets = []Wallet{v.(Wallet)}
+		}
+		var buf bytes.Buffer
+		enc, _, _ := NewEncoder(ExportCSV, &buf)
+		for _, w := range wallets {
+			if err := enc.Encode(w); err != nil {
+				return err
+			}
+		}
+		if err := enc.Close(); err != nil {
+			return err
+		}
+		return c.Blob(code, "text/csv", buf.Bytes())
+	case MIMEMsgpack:
+		var buf bytes.Buffer
+		enc := msgpack.NewEncoder(&buf)
+		// The keys are those of the JSON rendering.
+		enc.SetCustomStructTag("json")
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		return c.Blob(code, MIMEMsgpack, buf.Bytes())
+	}
+	return c.JSON(code, v)
+}
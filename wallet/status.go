@@ -0,0 +1,78 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+)
+
+type Status string
+
+const (
+	StatusActive Status = "active"
+	// StatusFrozen wallets accept credits but refuse debits.
+	StatusFrozen Status = "frozen"
+	// StatusClosed wallets refuse every balance change until reopened.
+	StatusClosed Status = "closed"
+)
+
+var (
+	ErrInvalidTransition = errors.New("invalid wallet status transition")
+	ErrBalanceNotZero    = errors.New("wallet balance must be zero to close")
+	ErrWalletFrozen      = errors.New("wallet is frozen")
+	ErrWalletClosed      = errors.New("wallet is closed")
+)
+
+type ChangeStatus struct {
+	Status Status `json:"status" example:"frozen"`
+	Reason string `json:"reason" example:"Suspected fraud, case #4711"`
+}
+
+var transitions = map[Status][]Status{
+	StatusActive: {StatusFrozen, StatusClosed},
+	StatusFrozen: {StatusActive, StatusClosed},
+	StatusClosed: {StatusActive},
+}
+
+// Transition checks that w may move to the status to.
+func Transition(w Wallet, to Status) error {
+	allowed := false
+	for _, s := range transitions[w.Status] {
+		allowed = allowed || s == to
+	}
+	if !allowed {
+		return fmt.Errorf("%w from %s to %s", ErrInvalidTransition, w.Status, to)
+	}
+	if to == StatusClosed && w.Balance != 0 {
+		return ErrBalanceNotZero
+	}
+	return nil
+}
+
+// CheckBalanceChange checks that the balance of w may change to balance:
+// the status must allow it and a debit must not take the balance below
+// zero, or below the credit limit for Credit Card wallets.
+// Every operation that moves money must call it with the wallet row
+// locked, so the status cannot change underneath it.
+func CheckBalanceChange(w Wallet, balance float64) error {
+	if err := CheckStatus(w, balance); err != nil {
+		return err
+	}
+	if balance < w.Balance {
+		return w.checkFloor(balance)
+	}
+	return nil
+}
+
+// CheckStatus checks only that the status of w allows its balance to
+// change to balance.
+func CheckStatus(w Wallet, balance float64) error {
+	switch {
+	case balance == w.Balance:
+		return nil
+	case w.Status == StatusClosed:
+		return ErrWalletClosed
+	case w.Status == StatusFrozen && balance < w.Balance:
+		return ErrWalletFrozen
+	}
+	return nil
+}
@@ -0,0 +1,602 @@
+// Package storertest checks that implementations of wallet.Storer keep
+// its contract, so that the handlers behave the same over any of them.
+// A store runs the suite from its own tests:
+//
+//	func TestConformance(t *testing.T) {
+//		storertest.Run(t, func(t *testing.T) wallet.Storer { return memory.New() })
+//	}
+package storertest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet"
+)
+
+// Run checks the store returned by newStore, which is called once per
+// subtest and must hold no wallets. The transactions of stores that are
+// a wallet.Transactor are checked too. Audit entries left from before are
+// fine; the suite only looks at those of its own wallets.
+func Run(t *testing.T, newStore func(t *testing.T) wallet.Storer) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, s wallet.Storer)
+	}{
+		{"CreateWallet", testCreateWallet},
+		{"CreateInvalidWallet", testCreateInvalidWallet},
+		{"Wallets", testWallets},
+		{"WalletByUser", testWalletByUser},
+		{"ImportWallets", testImportWallets},
+		{"DeleteWallet", testDeleteWallet},
+		{"UpdateWallet", testUpdateWallet},
+		{"RestoreWallet", testRestoreWallet},
+		{"ChangeWalletStatus", testChangeWalletStatus},
+		{"Audit", testAudit},
+		{"EachWallet", testEachWallet},
+		{"Concurrency", testConcurrency},
+		{"WithTx", testWithTx},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStore(t))
+		})
+	}
+}
+
+// RunErrors checks that every method of the store returned by newBroken
+// returns an error, rather than swallowing it and returning no wallets.
+// All operations of the store must fail, such as when its database is
+// closed.
+func RunErrors(t *testing.T, newBroken func(t *testing.T) wallet.Storer) {
+	s := newBroken(t)
+	a := actor(t)
+	calls := map[string]func() error{
+		"Wallets": func() error { _, err := s.Wallets(wallet.Filter{}); return err },
+		"EachWallet": func() error {
+			return s.EachWallet(wallet.Filter{}, func(wallet.Wallet) error { return nil })
+		},
+		"WalletByUser": func() error { _, err := s.WalletByUser(1); return err },
+		"CreateWallet": func() error { _, err := s.CreateWallet(savings(1), a); return err },
+		"ImportWallets": func() error {
+			_, err := s.ImportWallets([]wallet.CreateWallet{savings(1)}, a)
+			return err
+		},
+		"DeleteWallet": func() error { return s.DeleteWallet(1, a) },
+		"UpdateWallet": func() error {
+			_, err := s.UpdateWallet(wallet.UpdateWallet{ID: 1, UserID: 1, WalletType: wallet.TypeSavings}, a)
+			return err
+		},
+		"RestoreWallet": func() error { _, err := s.RestoreWallet(1, a); return err },
+		"ChangeWalletStatus": func() error {
+			_, err := s.ChangeWalletStatus(1, wallet.ChangeStatus{Status: wallet.StatusFrozen}, a)
+			return err
+		},
+		"WalletAudit":  func() error { _, err := s.WalletAudit(1); return err },
+		"AuditEntries": func() error { _, err := s.AuditEntries(wallet.AuditFilter{Limit: 10}); return err },
+	}
+	for name, call := range calls {
+		if err := call(); err == nil {
+			t.Errorf("expected %s of a failing store to return an error", name)
+		}
+	}
+}
+
+// actor is unique to the test, so that its audit entries can be told
+// apart from any left in the store.
+func actor(t *testing.T) wallet.Actor {
+	return wallet.Actor{Name: fmt.Sprintf("storertest-%d", time.Now().UnixNano()), RequestID: t.Name()}
+}
+
+func savings(userID int) wallet.CreateWallet {
+	return wallet.CreateWallet{UserID: userID, UserName: fmt.Sprintf("User %d", userID),
+		WalletName: "Savings", WalletType: wallet.TypeSavings, Balance: 100}
+}
+
+func create(t *testing.T, s wallet.Storer, c wallet.CreateWallet) wallet.Wallet {
+	t.Helper()
+	w, err := s.CreateWallet(c, actor(t))
+	if err != nil {
+		t.Fatalf("unable to create wallet %+v: %v", c, err)
+	}
+	return w
+}
+
+func ids(wallets []wallet.Wallet) []int {
+	ids := []int{}
+	for _, w := range wallets {
+		ids = append(ids, w.ID)
+	}
+	return ids
+}
+
+func sameIDs(got []wallet.Wallet, want ...wallet.Wallet) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i].ID != want[i].ID {
+			return false
+		}
+	}
+	return true
+}
+
+func testCreateWallet(t *testing.T, s wallet.Storer) {
+	limit := 1000.004
+	start := time.Now().Add(-time.Minute)
+	w := create(t, s, wallet.CreateWallet{UserID: 1, UserName: "John Doe", WalletName: "John's Card",
+		WalletType: wallet.TypeCreditCard, Balance: 99.999, CreditLimit: &limit})
+
+	if w.ID == 0 || w.UserID != 1 || w.UserName != "John Doe" || w.WalletName != "John's Card" || w.WalletType != wallet.TypeCreditCard {
+		t.Errorf("expected the created wallet but got %+v", w)
+	}
+	if w.Status != wallet.StatusActive {
+		t.Errorf("expected a new wallet to be active but got %q", w.Status)
+	}
+	if w.CreatedAt.Before(start) || w.CreatedAt.After(time.Now().Add(time.Minute)) || w.DeletedAt != nil {
+		t.Errorf("expected the creation time to be now but got %v, deleted at %v", w.CreatedAt, w.DeletedAt)
+	}
+	if w.Balance != 100 || w.CreditLimit == nil || *w.CreditLimit != 1000 {
+		t.Errorf("expected amounts to be rounded to cents but got balance %v, credit limit %v", w.Balance, w.CreditLimit)
+	}
+	if w.AvailableBalance != 100 || w.AvailableCredit == nil || *w.AvailableCredit != 1100 {
+		t.Errorf("expected derived fields to be set but got %+v", w)
+	}
+
+	got, err := s.WalletByUser(1)
+	if err != nil || got.ID != w.ID || got.Balance != w.Balance || !got.CreatedAt.Equal(w.CreatedAt) {
+		t.Errorf("expected the created wallet to be stored but got %+v, %v", got, err)
+	}
+}
+
+func testCreateInvalidWallet(t *testing.T, s wallet.Storer) {
+	limit, negative := 100.0, -1.0
+	invalid := map[string]wallet.CreateWallet{
+		"unknown type":            {UserID: 1, WalletType: "Piggy Bank"},
+		"credit limit on savings": {UserID: 1, WalletType: wallet.TypeSavings, CreditLimit: &limit},
+		"negative credit limit":   {UserID: 1, WalletType: wallet.TypeCreditCard, CreditLimit: &negative},
+	}
+	for name, c := range invalid {
+		if _, err := s.CreateWallet(c, actor(t)); err == nil {
+			t.Errorf("expected a wallet with %s to be rejected", name)
+		}
+	}
+	if wallets, err := s.Wallets(wallet.Filter{IncludeDeleted: true}); err != nil || len(wallets) != 0 {
+		t.Errorf("expected no invalid wallet to be stored but got %+v, %v", wallets, err)
+	}
+}
+
+func testWallets(t *testing.T, s wallet.Storer) {
+	wallets, err := s.Wallets(wallet.Filter{})
+	if err != nil || wallets == nil || len(wallets) != 0 {
+		t.Errorf("expected an empty, non-nil listing but got %#v, %v", wallets, err)
+	}
+
+	limit := 500.0
+	john := create(t, s, savings(1))
+	card := create(t, s, wallet.CreateWallet{UserID: 1, UserName: "User 1", WalletName: "Card",
+		WalletType: wallet.TypeCreditCard, CreditLimit: &limit})
+	jane := create(t, s, savings(2))
+	jim := create(t, s, savings(3))
+
+	if wallets, _ := s.Wallets(wallet.Filter{}); !sameIDs(wallets, john, card, jane, jim) {
+		t.Errorf("expected all wallets in id order but got %v", ids(wallets))
+	}
+	if wallets, _ := s.Wallets(wallet.Filter{WalletType: wallet.TypeSavings}); !sameIDs(wallets, john, jane, jim) {
+		t.Errorf("expected the savings wallets but got %v", ids(wallets))
+	}
+	if wallets, _ := s.Wallets(wallet.Filter{WalletType: wallet.TypeCrypto}); wallets == nil || len(wallets) != 0 {
+		t.Errorf("expected an empty, non-nil listing of a type without wallets but got %#v", wallets)
+	}
+	if wallets, _ := s.Wallets(wallet.Filter{Limit: 2}); !sameIDs(wallets, john, card) {
+		t.Errorf("expected the first page but got %v", ids(wallets))
+	}
+	if wallets, _ := s.Wallets(wallet.Filter{AfterID: card.ID, Limit: 2}); !sameIDs(wallets, jane, jim) {
+		t.Errorf("expected the page after %d but got %v", card.ID, ids(wallets))
+	}
+	if wallets, _ := s.Wallets(wallet.Filter{AfterID: jim.ID}); wallets == nil || len(wallets) != 0 {
+		t.Errorf("expected an empty, non-nil page after the last wallet but got %#v", wallets)
+	}
+}
+
+func testWalletByUser(t *testing.T, s wallet.Storer) {
+	if _, err := s.WalletByUser(1); !errors.Is(err, wallet.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a user without wallets but got %v", err)
+	}
+
+	create(t, s, savings(1))
+	newest := create(t, s, wallet.CreateWallet{UserID: 1, WalletType: wallet.TypeCrypto})
+	create(t, s, savings(2))
+	if w, err := s.WalletByUser(1); err != nil || w.ID != newest.ID {
+		t.Errorf("expected the newest wallet of the user, %d, but got %+v, %v", newest.ID, w, err)
+	}
+
+	if err := s.DeleteWallet(1, actor(t)); err != nil {
+		t.Fatalf("unable to delete wallet: %v", err)
+	}
+	if _, err := s.WalletByUser(1); !errors.Is(err, wallet.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a user whose wallets are deleted but got %v", err)
+	}
+}
+
+func testImportWallets(t *testing.T, s wallet.Storer) {
+	a := actor(t)
+	_, err := s.ImportWallets([]wallet.CreateWallet{savings(1), {UserID: 2, WalletType: "Piggy Bank"}}, a)
+	if err == nil {
+		t.Errorf("expected an import with an invalid wallet to fail")
+	}
+	if wallets, _ := s.Wallets(wallet.Filter{IncludeDeleted: true}); len(wallets) != 0 {
+		t.Errorf("expected a failed import to create no wallets but got %v", ids(wallets))
+	}
+
+	created, err := s.ImportWallets([]wallet.CreateWallet{savings(3), savings(1), savings(2)}, a)
+	if err != nil || len(created) != 3 {
+		t.Fatalf("expected 3 wallets to be imported but got %+v, %v", created, err)
+	}
+	for i, userID := range []int{3, 1, 2} {
+		if created[i].UserID != userID || created[i].Status != wallet.StatusActive {
+			t.Errorf("expected wallet %d to be of user %d but got %+v", i, userID, created[i])
+		}
+	}
+	if wallets, _ := s.Wallets(wallet.Filter{}); !sameIDs(wallets, created...) {
+		t.Errorf("expected the imported wallets in the order of the import but got %v", ids(wallets))
+	}
+	if entries, _ := s.AuditEntries(wallet.AuditFilter{Actor: a.Name, Action: wallet.ActionCreate, Limit: 10}); len(entries) != 3 {
+		t.Errorf("expected every imported wallet to be audited but got %+v", entries)
+	}
+}
+
+func testDeleteWallet(t *testing.T, s wallet.Storer) {
+	first := create(t, s, savings(1))
+	second := create(t, s, wallet.CreateWallet{UserID: 1, WalletType: wallet.TypeCrypto})
+	other := create(t, s, savings(2))
+
+	if err := s.DeleteWallet(1, actor(t)); err != nil {
+		t.Fatalf("unable to delete wallet: %v", err)
+	}
+	if err := s.DeleteWallet(99, actor(t)); err != nil {
+		t.Errorf("expected deleting the wallets of a user without any to succeed but got %v", err)
+	}
+
+	if wallets, _ := s.Wallets(wallet.Filter{}); !sameIDs(wallets, other) {
+		t.Errorf("expected deleted wallets to be hidden but got %v", ids(wallets))
+	}
+	if wallets, _ := s.Wallets(wallet.Filter{WalletType: wallet.TypeSavings}); !sameIDs(wallets, other) {
+		t.Errorf("expected deleted wallets to be hidden by type but got %v", ids(wallets))
+	}
+	wallets, _ := s.Wallets(wallet.Filter{IncludeDeleted: true})
+	if !sameIDs(wallets, first, second, other) {
+		t.Fatalf("expected deleted wallets to be listed on request but got %v", ids(wallets))
+	}
+	if wallets[0].DeletedAt == nil || wallets[1].DeletedAt == nil || wallets[2].DeletedAt != nil {
+		t.Errorf("expected only the wallets of user 1 to be deleted but got %+v", wallets)
+	}
+
+	entries, _ := s.WalletAudit(first.ID)
+	if len(entries) != 2 || entries[1].Action != wallet.ActionDelete {
+		t.Errorf("expected the deletion to be audited but got %+v", entries)
+	}
+}
+
+func testUpdateWallet(t *testing.T, s wallet.Storer) {
+	w := create(t, s, savings(1))
+	a := actor(t)
+	update := wallet.UpdateWallet{ID: w.ID, UserID: 2, UserName: "User 2", WalletName: "Moved",
+		WalletType: wallet.TypeSavings, Balance: 150.004}
+
+	got, err := s.UpdateWallet(update, a)
+	if err != nil || got.ID != w.ID || got.UserID != 2 || got.WalletName != "Moved" || got.Balance != 150 {
+		t.Errorf("expected the updated, rounded wallet but got %+v, %v", got, err)
+	}
+	if got, err := s.WalletByUser(2); err != nil || got.ID != w.ID || got.Balance != 150 {
+		t.Errorf("expected the update to be stored but got %+v, %v", got, err)
+	}
+	if _, err := s.WalletByUser(1); !errors.Is(err, wallet.ErrNotFound) {
+		t.Errorf("expected the wallet to leave user 1 but got %v", err)
+	}
+
+	update.ID = w.ID + 1000
+	if _, err := s.UpdateWallet(update, a); !errors.Is(err, wallet.ErrNotFound) {
+		t.Errorf("expected ErrNotFound updating a missing wallet but got %v", err)
+	}
+
+	if _, err := s.ChangeWalletStatus(w.ID, wallet.ChangeStatus{Status: wallet.StatusFrozen}, a); err != nil {
+		t.Fatalf("unable to freeze wallet: %v", err)
+	}
+	update.ID, update.Balance = w.ID, 50
+	if _, err := s.UpdateWallet(update, a); !errors.Is(err, wallet.ErrWalletFrozen) {
+		t.Errorf("expected ErrWalletFrozen debiting a frozen wallet but got %v", err)
+	}
+
+	if err := s.DeleteWallet(2, a); err != nil {
+		t.Fatalf("unable to delete wallet: %v", err)
+	}
+	if _, err := s.UpdateWallet(update, a); !errors.Is(err, wallet.ErrNotFound) {
+		t.Errorf("expected ErrNotFound updating a deleted wallet but got %v", err)
+	}
+
+	entries, _ := s.AuditEntries(wallet.AuditFilter{Actor: a.Name, Action: wallet.ActionUpdate, Limit: 10})
+	if len(entries) != 1 || entries[0].WalletID != w.ID || entries[0].Before == nil || entries[0].After == nil {
+		t.Errorf("expected only the successful update to be audited but got %+v", entries)
+	}
+}
+
+func testRestoreWallet(t *testing.T, s wallet.Storer) {
+	w := create(t, s, savings(1))
+	a := actor(t)
+	if _, err := s.RestoreWallet(w.ID, a); !errors.Is(err, wallet.ErrNotFound) {
+		t.Errorf("expected ErrNotFound restoring a live wallet but got %v", err)
+	}
+	if _, err := s.RestoreWallet(w.ID+1000, a); !errors.Is(err, wallet.ErrNotFound) {
+		t.Errorf("expected ErrNotFound restoring a missing wallet but got %v", err)
+	}
+
+	if err := s.DeleteWallet(1, a); err != nil {
+		t.Fatalf("unable to delete wallet: %v", err)
+	}
+	got, err := s.RestoreWallet(w.ID, a)
+	if err != nil || got.ID != w.ID || got.DeletedAt != nil {
+		t.Errorf("expected the restored wallet but got %+v, %v", got, err)
+	}
+	if got, err := s.WalletByUser(1); err != nil || got.ID != w.ID {
+		t.Errorf("expected the restored wallet to be read again but got %+v, %v", got, err)
+	}
+}
+
+func testChangeWalletStatus(t *testing.T, s wallet.Storer) {
+	w := create(t, s, savings(1))
+	a := actor(t)
+
+	got, err := s.ChangeWalletStatus(w.ID, wallet.ChangeStatus{Status: wallet.StatusFrozen, Reason: "fraud"}, a)
+	if err != nil || got.Status != wallet.StatusFrozen {
+		t.Errorf("expected the frozen wallet but got %+v, %v", got, err)
+	}
+	if _, err := s.ChangeWalletStatus(w.ID, wallet.ChangeStatus{Status: wallet.StatusClosed}, a); !errors.Is(err, wallet.ErrBalanceNotZero) {
+		t.Errorf("expected ErrBalanceNotZero closing a wallet with a balance but got %v", err)
+	}
+	if _, err := s.ChangeWalletStatus(w.ID, wallet.ChangeStatus{Status: wallet.StatusFrozen}, a); !errors.Is(err, wallet.ErrInvalidTransition) {
+		t.Errorf("expected ErrInvalidTransition freezing a frozen wallet but got %v", err)
+	}
+	if _, err := s.ChangeWalletStatus(w.ID+1000, wallet.ChangeStatus{Status: wallet.StatusFrozen}, a); !errors.Is(err, wallet.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a missing wallet but got %v", err)
+	}
+	if got, _ := s.WalletByUser(1); got.Status != wallet.StatusFrozen {
+		t.Errorf("expected the status to be stored but got %q", got.Status)
+	}
+
+	if err := s.DeleteWallet(1, a); err != nil {
+		t.Fatalf("unable to delete wallet: %v", err)
+	}
+	if _, err := s.ChangeWalletStatus(w.ID, wallet.ChangeStatus{Status: wallet.StatusActive}, a); !errors.Is(err, wallet.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a deleted wallet but got %v", err)
+	}
+
+	entries, _ := s.AuditEntries(wallet.AuditFilter{Actor: a.Name, Action: wallet.ActionStatus, Limit: 10})
+	if len(entries) != 1 || entries[0].Reason != "fraud" {
+		t.Errorf("expected the status change to be audited with its reason but got %+v", entries)
+	}
+}
+
+func testAudit(t *testing.T, s wallet.Storer) {
+	if entries, err := s.WalletAudit(1 << 30); err != nil || entries == nil || len(entries) != 0 {
+		t.Errorf("expected an empty, non-nil audit of a missing wallet but got %#v, %v", entries, err)
+	}
+
+	a := actor(t)
+	start := time.Now().Add(-time.Minute)
+	w, err := s.CreateWallet(savings(1), a)
+	if err != nil {
+		t.Fatalf("unable to create wallet: %v", err)
+	}
+	other := create(t, s, savings(2))
+	s.ChangeWalletStatus(w.ID, wallet.ChangeStatus{Status: wallet.StatusFrozen, Reason: "fraud"}, a)
+	s.DeleteWallet(1, a)
+	s.RestoreWallet(w.ID, a)
+
+	entries, err := s.WalletAudit(w.ID)
+	want := []string{wallet.ActionCreate, wallet.ActionStatus, wallet.ActionDelete, wallet.ActionRestore}
+	if err != nil || len(entries) != len(want) {
+		t.Fatalf("expected %d audit entries but got %+v, %v", len(want), entries, err)
+	}
+	for i, e := range entries {
+		if e.Action != want[i] || e.WalletID != w.ID || e.Actor != a.Name || e.RequestID != a.RequestID {
+			t.Errorf("expected audit entry %d to be %s of wallet %d by %s but got %+v", i, want[i], w.ID, a.Name, e)
+		}
+		if i > 0 && e.ID <= entries[i-1].ID {
+			t.Errorf("expected the audit in id order but got %+v", entries)
+		}
+	}
+	if entries[0].Before != nil || entries[0].After == nil || entries[1].Before == nil {
+		t.Errorf("expected the wallet before and after each change but got %+v", entries)
+	}
+
+	latest, err := s.AuditEntries(wallet.AuditFilter{Actor: a.Name, Limit: 2})
+	if err != nil || len(latest) != 2 || latest[0].Action != wallet.ActionRestore || latest[1].Action != wallet.ActionDelete {
+		t.Errorf("expected the 2 newest entries of the actor, newest first, but got %+v, %v", latest, err)
+	}
+	if none, _ := s.AuditEntries(wallet.AuditFilter{Actor: a.Name}); none == nil || len(none) != 0 {
+		t.Errorf("expected no entries with a zero limit but got %#v", none)
+	}
+	if got, _ := s.AuditEntries(wallet.AuditFilter{WalletID: other.ID, Limit: 10}); len(got) != 1 || got[0].WalletID != other.ID {
+		t.Errorf("expected the entries of wallet %d but got %+v", other.ID, got)
+	}
+	end := time.Now().Add(time.Minute)
+	if got, _ := s.AuditEntries(wallet.AuditFilter{Actor: a.Name, From: &start, To: &end, Limit: 10}); len(got) != 4 {
+		t.Errorf("expected the entries within the period but got %+v", got)
+	}
+	if got, _ := s.AuditEntries(wallet.AuditFilter{Actor: a.Name, From: &end, Limit: 10}); len(got) != 0 {
+		t.Errorf("expected no entries after the period but got %+v", got)
+	}
+}
+
+func testEachWallet(t *testing.T, s wallet.Storer) {
+	var created []wallet.Wallet
+	for userID := 1; userID <= 4; userID++ {
+		created = append(created, create(t, s, savings(userID)))
+	}
+
+	var seen []wallet.Wallet
+	a := actor(t)
+	err := s.EachWallet(wallet.Filter{AfterID: created[0].ID}, func(w wallet.Wallet) error {
+		seen = append(seen, w)
+		// fn may use the store, for reads and writes, without deadlocking.
+		if _, err := s.WalletByUser(w.UserID); err != nil {
+			return err
+		}
+		_, err := s.ChangeWalletStatus(w.ID, wallet.ChangeStatus{Status: wallet.StatusFrozen}, a)
+		return err
+	})
+	if err != nil || !sameIDs(seen, created[1:]...) {
+		t.Errorf("expected the wallets after the first in id order but got %v, %v", ids(seen), err)
+	}
+
+	stop := errors.New("stop")
+	seen = nil
+	err = s.EachWallet(wallet.Filter{}, func(w wallet.Wallet) error {
+		seen = append(seen, w)
+		if len(seen) == 2 {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) || len(seen) != 2 {
+		t.Errorf("expected the error of fn after 2 wallets but got %v after %v", err, ids(seen))
+	}
+}
+
+func testConcurrency(t *testing.T, s wallet.Storer) {
+	const n = 20
+	a := actor(t)
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*n)
+	for userID := 1; userID <= n; userID++ {
+		wg.Add(1)
+		go func(userID int) {
+			defer wg.Done()
+			_, err := s.CreateWallet(savings(userID), a)
+			errs <- err
+		}(userID)
+	}
+	wg.Wait()
+
+	wallets, err := s.Wallets(wallet.Filter{})
+	if err != nil || len(wallets) != n {
+		t.Fatalf("expected %d wallets but got %d, %v", n, len(wallets), err)
+	}
+	seen := map[int]bool{}
+	for _, w := range wallets {
+		if seen[w.ID] {
+			t.Errorf("expected distinct ids but got %d twice", w.ID)
+		}
+		seen[w.ID] = true
+	}
+
+	w := wallets[0]
+	for i := 1; i <= n; i++ {
+		wg.Add(1)
+		go func(balance float64) {
+			defer wg.Done()
+			_, err := s.UpdateWallet(wallet.UpdateWallet{ID: w.ID, UserID: w.UserID, UserName: w.UserName,
+				WalletName: w.WalletName, WalletType: w.WalletType, Balance: balance}, a)
+			errs <- err
+		}(float64(100 + i))
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("expected concurrent changes to succeed but got %v", err)
+		}
+	}
+	entries, _ := s.AuditEntries(wallet.AuditFilter{Actor: a.Name, Action: wallet.ActionUpdate, Limit: 2 * n})
+	if len(entries) != n {
+		t.Errorf("expected all %d updates to be audited but got %d", n, len(entries))
+	}
+}
+
+func testWithTx(t *testing.T, s wallet.Storer) {
+	transactor, ok := s.(wallet.Transactor)
+	if !ok {
+		t.Skip("the store has no transactions")
+	}
+	ctx := context.Background()
+	a := actor(t)
+
+	var created wallet.Wallet
+	err := transactor.WithTx(ctx, func(tx wallet.Storer) error {
+		var err error
+		if created, err = tx.CreateWallet(savings(1), a); err != nil {
+			return err
+		}
+		if _, err := tx.UpdateWallet(wallet.UpdateWallet{ID: -1, UserID: 1, WalletType: wallet.TypeSavings}, a); !errors.Is(err, wallet.ErrNotFound) {
+			return fmt.Errorf("expected a missing wallet not to be updated but got %v", err)
+		}
+		// A failed call leaves the transaction usable.
+		if w, err := tx.WalletByUser(1); err != nil || w.ID != created.ID {
+			return fmt.Errorf("expected the transaction to see its own wallet but got %+v, %v", w, err)
+		}
+		for i := 0; i < 2; i++ {
+			n := 0
+			if err := tx.EachWallet(wallet.Filter{}, func(wallet.Wallet) error { n++; return nil }); err != nil || n != 1 {
+				return fmt.Errorf("expected to export 1 wallet in the transaction but got %d, %v", n, err)
+			}
+		}
+		return tx.DeleteWallet(2, a)
+	})
+	if err != nil {
+		t.Fatalf("expected the transaction to commit but got %v", err)
+	}
+	if w, err := s.WalletByUser(1); err != nil || w.ID != created.ID {
+		t.Errorf("expected the committed wallet but got %+v, %v", w, err)
+	}
+
+	rollback := errors.New("rollback")
+	err = transactor.WithTx(ctx, func(tx wallet.Storer) error {
+		if _, err := tx.CreateWallet(savings(2), a); err != nil {
+			return err
+		}
+		if err := tx.DeleteWallet(1, a); err != nil {
+			return err
+		}
+		return rollback
+	})
+	if !errors.Is(err, rollback) {
+		t.Errorf("expected the error of fn but got %v", err)
+	}
+	if wallets, _ := s.Wallets(wallet.Filter{IncludeDeleted: true}); !sameIDs(wallets, created) || wallets[0].DeletedAt != nil {
+		t.Errorf("expected the rolled back changes to be undone but got %+v", wallets)
+	}
+	if entries, _ := s.AuditEntries(wallet.AuditFilter{Actor: a.Name, Limit: 10}); len(entries) != 1 {
+		t.Errorf("expected only the committed create to be audited but got %+v", entries)
+	}
+
+	err = transactor.WithTx(ctx, func(tx wallet.Storer) error {
+		if _, err := tx.CreateWallet(savings(3), a); err != nil {
+			return err
+		}
+		nested := tx.(wallet.Transactor).WithTx(ctx, func(tx wallet.Storer) error {
+			if _, err := tx.CreateWallet(savings(4), a); err != nil {
+				return err
+			}
+			return rollback
+		})
+		if !errors.Is(nested, rollback) {
+			return fmt.Errorf("expected the error of the nested fn but got %v", nested)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected the outer transaction to commit but got %v", err)
+	}
+	if _, err := s.WalletByUser(3); err != nil {
+		t.Errorf("expected the wallet of the outer transaction but got %v", err)
+	}
+	if _, err := s.WalletByUser(4); !errors.Is(err, wallet.ErrNotFound) {
+		t.Errorf("expected the nested transaction to be rolled back but got %v", err)
+	}
+}
@@ -1,30 +1,100 @@
 package wallet
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	TypeSavings    = "Savings"
+	TypeCreditCard = "Credit Card"
+	TypeCrypto     = "Crypto Wallet"
+)
+
+var (
+	ErrNotFound      = errors.New("wallet not found")
+	ErrInvalidWallet = errors.New("invalid wallet")
+)
 
 type Wallet struct {
-	ID         int       `json:"id" example:"1"`
-	UserID     int       `json:"user_id" example:"1"`
-	UserName   string    `json:"user_name" example:"John Doe"`
-	WalletName string    `json:"wallet_name" example:"John's Wallet"`
-	WalletType string    `json:"wallet_type" example:"Create Card"`
-	Balance    float64   `json:"balance" example:"100.00"`
-	CreatedAt  time.Time `json:"created_at" example:"2024-03-25T14:19:00.729237Z"`
+	ID         int     `json:"id" xml:"id" example:"1"`
+	UserID     int     `json:"user_id" xml:"user_id" example:"1"`
+	UserName   string  `json:"user_name" xml:"user_name" example:"John Doe"`
+	WalletName string  `json:"wallet_name" xml:"wallet_name" example:"John's Wallet"`
+	WalletType string  `json:"wallet_type" xml:"wallet_type" example:"Create Card"`
+	Balance    float64 `json:"balance" xml:"balance" example:"100.00"`
+	// AvailableBalance is Balance less the active authorization holds,
+	// Held; see Derive.
+	AvailableBalance float64 `json:"available_balance" xml:"available_balance" example:"75.00"`
+	Held             float64 `json:"-" xml:"-"`
+	Status           Status  `json:"status" xml:"status" example:"active"`
+	// CreditLimit, AvailableCredit and OutstandingBalance are only set
+	// for Credit Card wallets; see Derive.
+	CreditLimit        *float64   `json:"credit_limit,omitempty" xml:"credit_limit,omitempty" example:"5000.00"`
+	AvailableCredit    *float64   `json:"available_credit,omitempty" xml:"available_credit,omitempty" example:"4750.00"`
+	OutstandingBalance *float64   `json:"outstanding_balance,omitempty" xml:"outstanding_balance,omitempty" example:"250.00"`
+	CreatedAt          time.Time  `json:"created_at" xml:"created_at" example:"2024-03-25T14:19:00.729237Z"`
+	DeletedAt          *time.Time `json:"deleted_at,omitempty" xml:"deleted_at,omitempty" example:"2024-03-26T09:00:00Z"`
+}
+
+// Filter narrows a wallet listing. Soft-deleted wallets are left out
+// unless IncludeDeleted is set.
+type Filter struct {
+	WalletType     string
+	IncludeDeleted bool
+	// AfterID and Limit page through a listing in id order; with a zero
+	// Limit all wallets after AfterID are listed.
+	AfterID int
+	Limit   int
 }
 
 type CreateWallet struct {
-	UserID     int     `json:"user_id"`
-	UserName   string  `json:"user_name"`
-	WalletName string  `json:"wallet_name"`
-	WalletType string  `json:"wallet_type"`
-	Balance    float64 `json:"balance"`
+	UserID      int      `json:"user_id"`
+	UserName    string   `json:"user_name"`
+	WalletName  string   `json:"wallet_name"`
+	WalletType  string   `json:"wallet_type"`
+	Balance     float64  `json:"balance"`
+	CreditLimit *float64 `json:"credit_limit,omitempty"`
+}
+
+func (c CreateWallet) Validate() error {
+	return validate(c.WalletType, c.CreditLimit, c.Balance)
 }
 
 type UpdateWallet struct {
-	ID         int     `json:"id"`
-	UserID     int     `json:"user_id"`
-	UserName   string  `json:"user_name"`
-	WalletName string  `json:"wallet_name"`
-	WalletType string  `json:"wallet_type"`
-	Balance    float64 `json:"balance"`
+	ID          int      `json:"id"`
+	UserID      int      `json:"user_id"`
+	UserName    string   `json:"user_name"`
+	WalletName  string   `json:"wallet_name"`
+	WalletType  string   `json:"wallet_type"`
+	Balance     float64  `json:"balance"`
+	CreditLimit *float64 `json:"credit_limit,omitempty"`
+}
+
+// Validate checks the update on its own; ApplyUpdate checks it against
+// the stored wallet.
+func (u UpdateWallet) Validate() error {
+	return validate(u.WalletType, u.CreditLimit, 0)
+}
+
+func validate(walletType string, creditLimit *float64, balance float64) error {
+	switch walletType {
+	case TypeSavings, TypeCreditCard, TypeCrypto:
+	default:
+		return fmt.Errorf("%w: wallet_type must be one of %s, %s, %s", ErrInvalidWallet, TypeSavings, TypeCreditCard, TypeCrypto)
+	}
+	if creditLimit != nil {
+		if walletType != TypeCreditCard {
+			return fmt.Errorf("%w: credit_limit is only allowed for %s wallets", ErrInvalidWallet, TypeCreditCard)
+		}
+		if *creditLimit < 0 {
+			return fmt.Errorf("%w: credit_limit must not be negative", ErrInvalidWallet)
+		}
+	}
+	w := Wallet{WalletType: walletType, CreditLimit: creditLimit}
+	if err := w.checkFloor(balance); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidWallet, err)
+	}
+	return nil
 }
@@ -2,24 +2,32 @@ package wallet
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"encoding/xml"
 	"errors"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/KKGo-Software-engineering/fun-exercise-api/apikey"
 	"github.com/labstack/echo/v4"
+	"github.com/parquet-go/parquet-go"
+	"github.com/vmihailenco/msgpack/v5"
 )
 
 type StubStorer struct {
 	wallets []Wallet
+	audit   []AuditEntry
+	filter  AuditFilter
 	err     error
 }
 
 // DeleteWallet implements Storer.
-func (s *StubStorer) DeleteWallet(userID int) error {
+func (s *StubStorer) DeleteWallet(userID int, actor Actor) error {
 	var result []Wallet
 	count := 0
 	for _, wallet := range s.wallets {
@@ -35,9 +43,9 @@ func (s *StubStorer) DeleteWallet(userID int) error {
 	return nil
 }
 
-func (s StubStorer) CreateWallet(createWallet CreateWallet) (Wallet, error) {
+func (s *StubStorer) CreateWallet(createWallet CreateWallet, actor Actor) (Wallet, error) {
 	result := Wallet{
-		ID:         1,
+		ID:         len(s.wallets) + 1,
 		UserID:     createWallet.UserID,
 		UserName:   createWallet.UserName,
 		WalletName: createWallet.WalletName,
@@ -45,51 +53,132 @@ func (s StubStorer) CreateWallet(createWallet CreateWallet) (Wallet, error) {
 		Balance:    createWallet.Balance,
 		CreatedAt:  time.Date(2024, 04, 12, 10, 45, 16, 0, time.UTC),
 	}
-	_ = append(s.wallets, result)
+	s.wallets = append(s.wallets, result)
 	return result, nil
 }
 
-func (s StubStorer) UpdateWallet(updateWallet UpdateWallet) (Wallet, error) {
+func (s *StubStorer) EachWallet(filter Filter, fn func(Wallet) error) error {
+	wallets, err := s.Wallets(filter)
+	if err != nil {
+		return err
+	}
+	for _, w := range wallets {
+		if err := fn(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	for _, scanWallet := range s.wallets {
-		if scanWallet.ID == updateWallet.ID {
+func (s *StubStorer) ImportWallets(createWallets []CreateWallet, actor Actor) ([]Wallet, error) {
+	var result []Wallet
+	for _, c := range createWallets {
+		w := Wallet{ID: len(s.wallets) + 1, UserID: c.UserID, UserName: c.UserName, WalletName: c.WalletName,
+			WalletType: c.WalletType, Balance: c.Balance, CreditLimit: c.CreditLimit}
+		s.wallets = append(s.wallets, w)
+		result = append(result, w)
+	}
+	return result, s.err
+}
+
+func (s *StubStorer) UpdateWallet(updateWallet UpdateWallet, actor Actor) (Wallet, error) {
+
+	for i := range s.wallets {
+		if scanWallet := &s.wallets[i]; scanWallet.ID == updateWallet.ID {
 			scanWallet.UserID = updateWallet.UserID
 			scanWallet.UserName = updateWallet.UserName
 			scanWallet.WalletName = updateWallet.WalletName
 			scanWallet.WalletType = updateWallet.WalletType
 			scanWallet.Balance = updateWallet.Balance
 			scanWallet.CreatedAt = time.Now()
-			return scanWallet, nil
+			return *scanWallet, nil
 		}
 	}
 
 	return Wallet{}, errors.New("Unable to find update row!")
 }
 
-func (s StubStorer) Wallets() ([]Wallet, error) {
-	return s.wallets, s.err
-}
-
-func (s StubStorer) WalletsByType(walletType string) ([]Wallet, error) {
+func (s *StubStorer) Wallets(filter Filter) ([]Wallet, error) {
 	var result []Wallet
 	for _, wallet := range s.wallets {
-		if wallet.WalletType == walletType {
-			result = append(result, wallet)
+		if filter.WalletType != "" && wallet.WalletType != filter.WalletType {
+			continue
+		}
+		if wallet.DeletedAt != nil && !filter.IncludeDeleted {
+			continue
 		}
+		result = append(result, wallet)
 	}
 	return result, s.err
 }
 
-func (s StubStorer) WalletByUser(userId int) (Wallet, error) {
+func (s *StubStorer) RestoreWallet(walletID int, actor Actor) (Wallet, error) {
+	for i, wallet := range s.wallets {
+		if wallet.ID == walletID && wallet.DeletedAt != nil {
+			s.wallets[i].DeletedAt = nil
+			return s.wallets[i], nil
+		}
+	}
+	return Wallet{}, ErrNotFound
+}
+
+func (s *StubStorer) WalletByUser(userId int) (Wallet, error) {
 	var result Wallet
 	for _, wallet := range s.wallets {
 		if wallet.UserID == userId {
 			result = wallet
 		}
 	}
+	if s.err == nil && result.ID == 0 {
+		return result, ErrNotFound
+	}
 	return result, s.err
 }
 
+func (s *StubStorer) WalletAudit(walletID int) ([]AuditEntry, error) {
+	var result []AuditEntry
+	for _, entry := range s.audit {
+		if entry.WalletID == walletID {
+			result = append(result, entry)
+		}
+	}
+	return result, s.err
+}
+
+func (s *StubStorer) AuditEntries(filter AuditFilter) ([]AuditEntry, error) {
+	s.filter = filter
+	return s.audit, s.err
+}
+
+func (s *StubStorer) ChangeWalletStatus(walletID int, changeStatus ChangeStatus, actor Actor) (Wallet, error) {
+	for i, wallet := range s.wallets {
+		if wallet.ID == walletID {
+			if err := Transition(wallet, changeStatus.Status); err != nil {
+				return Wallet{}, err
+			}
+			s.wallets[i].Status = changeStatus.Status
+			return s.wallets[i], nil
+		}
+	}
+	return Wallet{}, ErrNotFound
+}
+
+// TxStubStorer rolls back the wallets of its StubStorer when fn fails.
+type TxStubStorer struct {
+	*StubStorer
+	txs int
+}
+
+func (s *TxStubStorer) WithTx(ctx context.Context, fn func(tx Storer) error) error {
+	s.txs++
+	saved := append([]Wallet(nil), s.wallets...)
+	if err := fn(s.StubStorer); err != nil {
+		s.wallets = saved
+		return err
+	}
+	return nil
+}
+
 type ErrorMessage struct {
 	Message string
 }
@@ -305,6 +394,23 @@ func TestWallet(t *testing.T) {
 		}
 	})
 
+	t.Run("given user without wallets should return 404", func(t *testing.T) {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		res := httptest.NewRecorder()
+		c := e.NewContext(req, res)
+		c.SetPath("/users/:id/wallets")
+		c.SetParamNames("id")
+		c.SetParamValues("3")
+		w := New(&StubStorer{wallets: []Wallet{{ID: 1, UserID: 1}}})
+
+		w.WalletHandlerByUser(c)
+
+		if res.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d but got %d", http.StatusNotFound, res.Code)
+		}
+	})
+
 	t.Run("given user able to delete wallet by user id should return success message", func(t *testing.T) {
 		e := echo.New()
 		req := httptest.NewRequest(http.MethodDelete, "/", nil)
@@ -394,4 +500,570 @@ func TestWallet(t *testing.T) {
 			t.Errorf("CreatedAt is not changed old=%v, new=%v", want, got)
 		}
 	})
+
+	t.Run("given wallet id should return its audit trail", func(t *testing.T) {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		res := httptest.NewRecorder()
+		c := e.NewContext(req, res)
+		c.SetPath("/wallets/:id/audit")
+		c.SetParamNames("id")
+		c.SetParamValues("2")
+		audit := []AuditEntry{
+			{ID: 1, Actor: "api-key:1", Action: ActionCreate, WalletID: 1, After: json.RawMessage(`{"id":1}`)},
+			{ID: 2, Actor: "api-key:1", Action: ActionCreate, WalletID: 2, After: json.RawMessage(`{"id":2}`)},
+			{ID: 3, Actor: "api-key:3", Action: ActionUpdate, WalletID: 2, Before: json.RawMessage(`{"id":2}`), After: json.RawMessage(`{"id":2}`)},
+		}
+		w := New(&StubStorer{audit: audit})
+
+		w.WalletAuditHandler(c)
+
+		var got []AuditEntry
+		if err := json.Unmarshal(res.Body.Bytes(), &got); err != nil {
+			t.Errorf("Unable to unmarshal json: %v", err)
+		}
+		if len(got) != 2 || got[0].ID != 2 || got[1].ID != 3 {
+			t.Errorf("expected audit entries 2 and 3 but got %v", got)
+		}
+	})
+
+	t.Run("given audit query should pass filter to store", func(t *testing.T) {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/?actor=api-key:3&action=update&wallet_id=2&from=2024-04-12T00:00:00Z&limit=5000", nil)
+		res := httptest.NewRecorder()
+		c := e.NewContext(req, res)
+		store := &StubStorer{}
+		w := New(store)
+
+		w.AuditHandler(c)
+
+		if res.Code != http.StatusOK {
+			t.Fatalf("expected status code %d but got %d", http.StatusOK, res.Code)
+		}
+		from := time.Date(2024, 04, 12, 0, 0, 0, 0, time.UTC)
+		want := AuditFilter{Actor: "api-key:3", Action: ActionUpdate, WalletID: 2, From: &from, Limit: 1000}
+		if !reflect.DeepEqual(store.filter, want) {
+			t.Errorf("expected %+v but got %+v", want, store.filter)
+		}
+	})
+
+	t.Run("given invalid audit time should return 400", func(t *testing.T) {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/?from=yesterday", nil)
+		res := httptest.NewRecorder()
+		c := e.NewContext(req, res)
+		w := New(&StubStorer{})
+
+		w.AuditHandler(c)
+
+		if res.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d but got %d", http.StatusBadRequest, res.Code)
+		}
+	})
+
+	t.Run("given authenticated request should attribute changes to api key and request id", func(t *testing.T) {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPatch, "/", nil)
+		req.Header.Set(echo.HeaderXRequestID, "req-1")
+		res := httptest.NewRecorder()
+		c := e.NewContext(req, res)
+		apikey.SetKey(c, apikey.APIKey{ID: 3, Name: "batch"})
+
+		got := ActorFrom(c)
+
+		want := Actor{Name: "api-key:3", RequestID: "req-1"}
+		if got != want {
+			t.Errorf("expected %v but got %v", want, got)
+		}
+	})
+
+	t.Run("given include_deleted without admin scope should return 403", func(t *testing.T) {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/?include_deleted=true", nil)
+		res := httptest.NewRecorder()
+		c := e.NewContext(req, res)
+		apikey.SetKey(c, apikey.APIKey{ID: 1, Scopes: []string{apikey.ScopeWalletsRead}})
+		w := New(&StubStorer{})
+
+		w.WalletHandler(c)
+
+		if res.Code != http.StatusForbidden {
+			t.Errorf("expected status code %d but got %d", http.StatusForbidden, res.Code)
+		}
+	})
+
+	t.Run("given include_deleted as admin should list soft-deleted wallets", func(t *testing.T) {
+		deletedAt := time.Date(2024, 04, 13, 10, 45, 16, 0, time.UTC)
+		body := []Wallet{
+			{ID: 1, UserID: 1, WalletType: "Savings"},
+			{ID: 2, UserID: 2, WalletType: "Savings", DeletedAt: &deletedAt},
+		}
+		for query, want := range map[string]int{"/": 1, "/?include_deleted=true": 2} {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, query, nil)
+			res := httptest.NewRecorder()
+			c := e.NewContext(req, res)
+			apikey.SetKey(c, apikey.APIKey{ID: 1, Scopes: []string{apikey.ScopeAdmin}})
+			w := New(&StubStorer{wallets: body})
+
+			w.WalletHandler(c)
+
+			var got []Wallet
+			if err := json.Unmarshal(res.Body.Bytes(), &got); err != nil {
+				t.Errorf("Unable to unmarshal json: %v", err)
+			}
+			if len(got) != want {
+				t.Errorf("%s: expected %d wallets but got %d", query, want, len(got))
+			}
+		}
+	})
+
+	t.Run("given soft-deleted wallet should restore it", func(t *testing.T) {
+		deletedAt := time.Date(2024, 04, 13, 10, 45, 16, 0, time.UTC)
+		store := &StubStorer{wallets: []Wallet{{ID: 2, UserID: 2, DeletedAt: &deletedAt}}}
+		for _, want := range []int{http.StatusOK, http.StatusNotFound} {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			res := httptest.NewRecorder()
+			c := e.NewContext(req, res)
+			c.SetPath("/wallets/:id/restore")
+			c.SetParamNames("id")
+			c.SetParamValues("2")
+
+			New(store).RestoreWallet(c)
+
+			if res.Code != want {
+				t.Errorf("expected status code %d but got %d", want, res.Code)
+			}
+		}
+		if store.wallets[0].DeletedAt != nil {
+			t.Errorf("expected wallet to be restored")
+		}
+	})
+
+	t.Run("given status change should enforce wallet lifecycle", func(t *testing.T) {
+		store := &StubStorer{wallets: []Wallet{
+			{ID: 1, Status: StatusActive, Balance: 100},
+			{ID: 2, Status: StatusActive},
+		}}
+		tests := []struct {
+			id     string
+			change ChangeStatus
+			want   int
+		}{
+			{"1", ChangeStatus{Status: StatusFrozen, Reason: "fraud check"}, http.StatusOK},
+			{"1", ChangeStatus{Status: StatusFrozen, Reason: "again"}, http.StatusConflict},
+			{"1", ChangeStatus{Status: StatusClosed, Reason: "customer request"}, http.StatusConflict},
+			{"1", ChangeStatus{Status: StatusActive}, http.StatusBadRequest},
+			{"1", ChangeStatus{Status: "deleted", Reason: "typo"}, http.StatusBadRequest},
+			{"2", ChangeStatus{Status: StatusClosed, Reason: "customer request"}, http.StatusOK},
+			{"2", ChangeStatus{Status: StatusActive, Reason: "reopened"}, http.StatusOK},
+			{"3", ChangeStatus{Status: StatusFrozen, Reason: "fraud check"}, http.StatusNotFound},
+		}
+		for _, tt := range tests {
+			body, _ := json.Marshal(tt.change)
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			res := httptest.NewRecorder()
+			c := echo.New().NewContext(req, res)
+			c.SetParamNames("id")
+			c.SetParamValues(tt.id)
+
+			New(store).ChangeWalletStatus(c)
+
+			if res.Code != tt.want {
+				t.Errorf("wallet %s to %v: expected status code %d but got %d", tt.id, tt.change, tt.want, res.Code)
+			}
+		}
+	})
+}
+
+func TestCheckBalanceChange(t *testing.T) {
+	tests := []struct {
+		status  Status
+		balance float64
+		want    error
+	}{
+		{StatusActive, 50, nil},
+		{StatusActive, 150, nil},
+		{StatusFrozen, 150, nil},
+		{StatusFrozen, 50, ErrWalletFrozen},
+		{StatusClosed, 150, ErrWalletClosed},
+		{StatusClosed, 100, nil},
+	}
+	for _, tt := range tests {
+		got := CheckBalanceChange(Wallet{Status: tt.status, Balance: 100}, tt.balance)
+		if !errors.Is(got, tt.want) {
+			t.Errorf("%s wallet to %v: expected %v but got %v", tt.status, tt.balance, tt.want, got)
+		}
+	}
+}
+
+func TestCreditCard(t *testing.T) {
+	limit := 1000.0
+	card := Wallet{ID: 1, WalletType: TypeCreditCard, Status: StatusActive, Balance: -250, CreditLimit: &limit}
+
+	t.Run("given credit card should derive available credit and outstanding balance", func(t *testing.T) {
+		w := card
+		w.Derive()
+
+		if *w.AvailableCredit != 750 || *w.OutstandingBalance != 250 {
+			t.Errorf("expected available 750 and outstanding 250 but got %v and %v", *w.AvailableCredit, *w.OutstandingBalance)
+		}
+		body, _ := json.Marshal(Wallet{WalletType: TypeSavings, Balance: 10})
+		if bytes.Contains(body, []byte("credit")) {
+			t.Errorf("expected credit fields to be left out for savings wallets, got %s", body)
+		}
+	})
+
+	t.Run("given debit should allow negative balance down to the credit limit", func(t *testing.T) {
+		tests := []struct {
+			update UpdateWallet
+			want   error
+		}{
+			{UpdateWallet{ID: 1, WalletType: TypeCreditCard, Balance: -1000}, nil},
+			{UpdateWallet{ID: 1, WalletType: TypeCreditCard, Balance: -1000.01}, ErrCreditLimitExceeded},
+			{UpdateWallet{ID: 1, WalletType: TypeCreditCard, Balance: -250, CreditLimit: ptr(100.0)}, ErrCreditLimitExceeded},
+			{UpdateWallet{ID: 1, WalletType: TypeSavings, Balance: -250}, ErrInsufficientFunds},
+		}
+		for _, tt := range tests {
+			got, err := ApplyUpdate(card, tt.update)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("%+v: expected %v but got %v", tt.update, tt.want, err)
+			}
+			if err == nil && *got.CreditLimit != limit {
+				t.Errorf("expected credit limit to be kept but got %v", *got.CreditLimit)
+			}
+		}
+	})
+
+	t.Run("given credit limit on savings wallet should be invalid", func(t *testing.T) {
+		err := CreateWallet{WalletType: TypeSavings, CreditLimit: ptr(100.0)}.Validate()
+		if !errors.Is(err, ErrInvalidWallet) {
+			t.Errorf("expected %v but got %v", ErrInvalidWallet, err)
+		}
+		err = CreateWallet{WalletType: TypeCreditCard, Balance: -50, CreditLimit: ptr(100.0)}.Validate()
+		if err != nil {
+			t.Errorf("expected opening balance within the limit to be valid, got %v", err)
+		}
+	})
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestHeld(t *testing.T) {
+	savings := Wallet{WalletType: TypeSavings, Status: StatusActive, Balance: 100, Held: 60}
+	savings.Derive()
+	if savings.AvailableBalance != 40 {
+		t.Errorf("expected available balance 40 but got %v", savings.AvailableBalance)
+	}
+	if err := CheckBalanceChange(savings, 60); err != nil {
+		t.Errorf("expected a debit within the available balance to be allowed, got %v", err)
+	}
+	if err := CheckBalanceChange(savings, 59.99); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("expected held funds not to be spendable, got %v", err)
+	}
+
+	card := Wallet{WalletType: TypeCreditCard, Status: StatusActive, Balance: -100, Held: 50, CreditLimit: ptr(500.0)}
+	card.Derive()
+	if *card.AvailableCredit != 350 {
+		t.Errorf("expected holds to reduce available credit to 350 but got %v", *card.AvailableCredit)
+	}
+	if err := CheckBalanceChange(card, -450.01); !errors.Is(err, ErrCreditLimitExceeded) {
+		t.Errorf("expected held credit not to be spendable, got %v", err)
+	}
+}
+
+func TestImportWallets(t *testing.T) {
+	const csvBody = "user_id,user_name,wallet_name,wallet_type,balance,credit_limit\n" +
+		"1,Ann,Ann's Savings,Savings,100.50,\n" +
+		"2,Bob,Bob's Card,Credit Card,-20,1000\n" +
+		"3,Cid,Cid's Savings,Savings,-5,\n"
+	const ndjsonBody = `{"user_id":1,"user_name":"Ann","wallet_name":"Ann's Savings","wallet_type":"Savings","balance":100.5}` + "\n\n" +
+		`{"user_id":2,"user_name":"Bob","wallet_name":"Bob's Card","wallet_type":"Credit Card","balance":-20,"credit_limit":1000}` + "\n" +
+		`{"user_id":"3"}` + "\n"
+
+	importWallets := func(store *StubStorer, contentType, body, mode string) (*httptest.ResponseRecorder, ImportReport) {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/?mode="+mode, bytes.NewBufferString(body))
+		req.Header.Set(echo.HeaderContentType, contentType)
+		rec := httptest.NewRecorder()
+		_ = New(store).ImportWallets(e.NewContext(req, rec))
+		var report ImportReport
+		_ = json.Unmarshal(rec.Body.Bytes(), &report)
+		return rec, report
+	}
+
+	// NDJSON rows are numbered by line, blank ones included.
+	for contentType, format := range map[string]struct {
+		body string
+		rows []int
+	}{
+		MIMETextCSV: {csvBody, []int{1, 2, 3}},
+		MIMENDJSON:  {ndjsonBody, []int{1, 3, 4}},
+	} {
+		body, rows := format.body, format.rows
+		t.Run("given "+contentType+" with an invalid row in atomic mode should import nothing", func(t *testing.T) {
+			store := &StubStorer{}
+			rec, report := importWallets(store, contentType, body, "")
+			if rec.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("expected status 422 but got %d %s", rec.Code, rec.Body)
+			}
+			if len(store.wallets) != 0 || report.Failed != 1 || report.Results[0].Status != ImportSkipped || report.Results[2].Row != rows[2] {
+				t.Errorf("unexpected report %+v", report)
+			}
+		})
+
+		t.Run("given "+contentType+" in best effort mode should import the valid rows", func(t *testing.T) {
+			store := &StubStorer{}
+			rec, report := importWallets(store, contentType, body, ImportBestEffort)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status 200 but got %d %s", rec.Code, rec.Body)
+			}
+			want := []ImportResult{
+				{Row: rows[0], Status: ImportCreated, WalletID: 1},
+				{Row: rows[1], Status: ImportCreated, WalletID: 2},
+				{Row: rows[2], Status: ImportFailed},
+			}
+			for i := range want {
+				report.Results[i].Error = ""
+			}
+			if !reflect.DeepEqual(report.Results, want) || report.Created != 2 || report.Failed != 1 {
+				t.Errorf("expected %+v but got %+v", want, report)
+			}
+			if *store.wallets[1].CreditLimit != 1000 || store.wallets[0].Balance != 100.5 {
+				t.Errorf("unexpected wallets %+v", store.wallets)
+			}
+		})
+	}
+
+	t.Run("given only valid rows should respond 201", func(t *testing.T) {
+		rec, report := importWallets(&StubStorer{}, MIMETextCSV+"; charset=utf-8", strings.Join(strings.Split(csvBody, "\n")[:3], "\n"), "")
+		if rec.Code != http.StatusCreated || report.Created != 2 {
+			t.Errorf("expected status 201 and 2 wallets but got %d %s", rec.Code, rec.Body)
+		}
+	})
+
+	t.Run("given unsupported content type should respond 415", func(t *testing.T) {
+		if rec, _ := importWallets(&StubStorer{}, echo.MIMEApplicationXML, "<wallets/>", ""); rec.Code != http.StatusUnsupportedMediaType {
+			t.Errorf("expected status 415 but got %d", rec.Code)
+		}
+	})
+
+	t.Run("given CSV without required columns should respond 400", func(t *testing.T) {
+		if rec, _ := importWallets(&StubStorer{}, MIMETextCSV, "user_id,balance\n1,10\n", ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400 but got %d", rec.Code)
+		}
+	})
+}
+
+func TestExportWallets(t *testing.T) {
+	deleted := time.Date(2024, 4, 13, 0, 0, 0, 0, time.UTC)
+	store := &StubStorer{wallets: []Wallet{
+		{ID: 1, UserID: 1, UserName: "Ann", WalletName: "Ann's Savings", WalletType: TypeSavings, Balance: 100.5, Status: StatusActive,
+			CreatedAt: time.Date(2024, 4, 12, 10, 45, 16, 0, time.UTC)},
+		{ID: 2, UserID: 2, UserName: "Bob", WalletName: "Bob's Card", WalletType: TypeCreditCard, Balance: -20, CreditLimit: ptr(1000.0),
+			Status: StatusActive, CreatedAt: time.Date(2024, 4, 12, 10, 45, 16, 0, time.UTC)},
+		{ID: 3, UserID: 3, WalletType: TypeSavings, DeletedAt: &deleted},
+	}}
+	export := func(query string) *httptest.ResponseRecorder {
+		e := echo.New()
+		rec := httptest.NewRecorder()
+		_ = New(store).ExportWallets(e.NewContext(httptest.NewRequest(http.MethodGet, "/?"+query, nil), rec))
+		return rec
+	}
+
+	t.Run("given csv should export the listed wallets so they can be imported again", func(t *testing.T) {
+		rec := export("wallet_type=" + TypeSavings)
+		want := "user_id,user_name,wallet_name,wallet_type,balance,credit_limit,id,status,created_at,deleted_at\n" +
+			"1,Ann,Ann's Savings,Savings,100.50,,1,active,2024-04-12T10:45:16Z,\n"
+		if rec.Code != http.StatusOK || rec.Body.String() != want {
+			t.Fatalf("expected %q but got %d %q", want, rec.Code, rec.Body)
+		}
+		rows, err := ParseImport(MIMETextCSV, rec.Body)
+		if err != nil || len(rows) != 1 || rows[0].Err != nil || rows[0].Wallet.Balance != 100.5 {
+			t.Errorf("expected the export to import again, got %+v %v", rows, err)
+		}
+	})
+
+	t.Run("given ndjson should write a wallet per line", func(t *testing.T) {
+		rec := export("format=ndjson")
+		lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+		if rec.Header().Get(echo.HeaderContentType) != MIMENDJSON || len(lines) != 2 {
+			t.Errorf("expected 2 lines of NDJSON but got %q", rec.Body)
+		}
+	})
+
+	t.Run("given parquet should write a readable file", func(t *testing.T) {
+		rec := export("format=parquet")
+		rows, err := parquet.Read[parquetWallet](bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+		if err != nil {
+			t.Fatalf("unable to read parquet: %v", err)
+		}
+		if len(rows) != 2 || rows[1].UserName != "Bob" || *rows[1].CreditLimit != 1000 || rows[0].CreditLimit != nil {
+			t.Errorf("unexpected rows %+v", rows)
+		}
+		if !rows[0].CreatedAt.Equal(store.wallets[0].CreatedAt) {
+			t.Errorf("expected created_at %v but got %v", store.wallets[0].CreatedAt, rows[0].CreatedAt)
+		}
+	})
+
+	t.Run("given unknown format should respond 400", func(t *testing.T) {
+		if rec := export("format=xlsx"); rec.Code != http.StatusBadRequest {
+			t.Errorf("expected status 400 but got %d", rec.Code)
+		}
+	})
+
+	t.Run("given include_deleted without admin scope should respond 403", func(t *testing.T) {
+		if rec := export("include_deleted=true"); rec.Code != http.StatusForbidden {
+			t.Errorf("expected status 403 but got %d", rec.Code)
+		}
+	})
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", echo.MIMEApplicationJSON},
+		{"*/*", echo.MIMEApplicationJSON},
+		{"text/xml", MIMEXML},
+		{"application/x-msgpack", MIMEMsgpack},
+		{"application/json;q=0.5, text/csv", "text/csv"},
+		{"image/png, application/xml;q=0.1", MIMEXML},
+		{"text/csv;q=0, */*;q=0.1", echo.MIMEApplicationJSON},
+	}
+	for _, tt := range tests {
+		got, err := Negotiate(tt.accept)
+		if err != nil || got != tt.want {
+			t.Errorf("Negotiate(%q) = %q, %v; want %q", tt.accept, got, err, tt.want)
+		}
+	}
+	if _, err := Negotiate("image/png, text/csv;q=0"); err != ErrNotAcceptable {
+		t.Errorf("expected ErrNotAcceptable but got %v", err)
+	}
+}
+
+func TestWalletHandlerAccept(t *testing.T) {
+	store := &StubStorer{wallets: []Wallet{
+		{ID: 1, UserID: 1, UserName: "Ann", WalletName: "Ann's Savings", WalletType: TypeSavings, Balance: 100.5, Status: StatusActive,
+			CreatedAt: time.Date(2024, 4, 12, 10, 45, 16, 0, time.UTC)},
+	}}
+	list := func(accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(echo.HeaderAccept, accept)
+		rec := httptest.NewRecorder()
+		_ = New(store).WalletHandler(echo.New().NewContext(req, rec))
+		return rec
+	}
+
+	t.Run("given xml should render a wallets document", func(t *testing.T) {
+		rec := list("application/xml")
+		if !strings.HasPrefix(rec.Header().Get(echo.HeaderContentType), MIMEXML) {
+			t.Errorf("expected content type %q but got %q", MIMEXML, rec.Header().Get(echo.HeaderContentType))
+		}
+		var got walletList
+		if err := xml.Unmarshal(rec.Body.Bytes(), &got); err != nil || len(got.Wallets) != 1 || got.Wallets[0].WalletName != "Ann's Savings" {
+			t.Errorf("unexpected xml %q: %v", rec.Body, err)
+		}
+	})
+
+	t.Run("given csv should render the export columns", func(t *testing.T) {
+		rec := list("text/csv")
+		want := "user_id,user_name,wallet_name,wallet_type,balance,credit_limit,id,status,created_at,deleted_at\n" +
+			"1,Ann,Ann's Savings,Savings,100.50,,1,active,2024-04-12T10:45:16Z,\n"
+		if rec.Body.String() != want {
+			t.Errorf("expected %q but got %q", want, rec.Body)
+		}
+	})
+
+	t.Run("given msgpack should use the json keys", func(t *testing.T) {
+		rec := list(MIMEMsgpack)
+		var got []map[string]any
+		if err := msgpack.Unmarshal(rec.Body.Bytes(), &got); err != nil || len(got) != 1 || got[0]["wallet_name"] != "Ann's Savings" {
+			t.Errorf("unexpected msgpack %v: %v", got, err)
+		}
+		if _, ok := got[0]["Held"]; ok {
+			t.Errorf("expected Held to be left out")
+		}
+	})
+
+	t.Run("given unsupported type should respond 406", func(t *testing.T) {
+		if rec := list("image/png"); rec.Code != http.StatusNotAcceptable {
+			t.Errorf("expected status 406 but got %d", rec.Code)
+		}
+	})
+}
+
+func TestBatchWallets(t *testing.T) {
+	batchWallets := func(store Storer, body string) *httptest.ResponseRecorder {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		_ = New(store).BatchWallets(e.NewContext(req, rec))
+		return rec
+	}
+	john := Wallet{ID: 1, UserID: 1, UserName: "John Doe", WalletName: "John's Savings", WalletType: TypeSavings, Balance: 100}
+
+	t.Run("given valid operations should apply them in one transaction", func(t *testing.T) {
+		store := &TxStubStorer{StubStorer: &StubStorer{wallets: []Wallet{john}}}
+		rec := batchWallets(store, `{"operations":[
+			{"op":"create","create":{"user_id":2,"user_name":"Jane","wallet_name":"Jane's Savings","wallet_type":"Savings","balance":50}},
+			{"op":"update","update":{"id":1,"user_id":1,"user_name":"John Doe","wallet_name":"John's Savings","wallet_type":"Savings","balance":75}},
+			{"op":"delete","user_id":2}]}`)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200 but got %d %s", rec.Code, rec.Body)
+		}
+		var report BatchReport
+		if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+			t.Fatalf("unable to unmarshal report: %v", err)
+		}
+		if len(report.Results) != 3 || report.Results[0].Wallet.ID != 2 || report.Results[1].Wallet.Balance != 75 || report.Results[2].UserID != 2 {
+			t.Errorf("unexpected report %+v", report)
+		}
+		if store.txs != 1 || len(store.wallets) != 1 || store.wallets[0].Balance != 75 {
+			t.Errorf("expected 1 transaction leaving the updated wallet but got %d, %+v", store.txs, store.wallets)
+		}
+	})
+
+	t.Run("given a failing operation should keep none of them", func(t *testing.T) {
+		store := &TxStubStorer{StubStorer: &StubStorer{wallets: []Wallet{john}}}
+		rec := batchWallets(store, `{"operations":[
+			{"op":"create","create":{"user_id":2,"wallet_type":"Savings"}},
+			{"op":"update","update":{"id":9,"user_id":9,"wallet_type":"Savings"}}]}`)
+		if rec.Code != http.StatusInternalServerError || !strings.Contains(rec.Body.String(), "operation 2") {
+			t.Errorf("expected status 500 naming operation 2 but got %d %s", rec.Code, rec.Body)
+		}
+		if !reflect.DeepEqual(store.wallets, []Wallet{john}) {
+			t.Errorf("expected the batch to be rolled back but got %+v", store.wallets)
+		}
+	})
+
+	t.Run("given an invalid operation should respond 400 before applying any", func(t *testing.T) {
+		store := &TxStubStorer{StubStorer: &StubStorer{}}
+		for _, body := range []string{
+			`{"operations":[]}`,
+			`{"operations":[{"op":"create","create":{"user_id":2,"wallet_type":"Savings"}},{"op":"delete"}]}`,
+			`{"operations":[{"op":"update"}]}`,
+			`{"operations":[{"op":"restore","user_id":1}]}`,
+		} {
+			if rec := batchWallets(store, body); rec.Code != http.StatusBadRequest {
+				t.Errorf("expected status 400 for %s but got %d %s", body, rec.Code, rec.Body)
+			}
+		}
+		if store.txs != 0 || len(store.wallets) != 0 {
+			t.Errorf("expected nothing to be applied but got %d transactions, %+v", store.txs, store.wallets)
+		}
+	})
+
+	t.Run("given a store without transactions should respond 501", func(t *testing.T) {
+		rec := batchWallets(&StubStorer{}, `{"operations":[{"op":"delete","user_id":1}]}`)
+		if rec.Code != http.StatusNotImplemented {
+			t.Errorf("expected status 501 but got %d %s", rec.Code, rec.Body)
+		}
+	})
 }
@@ -0,0 +1,104 @@
+package walletgrpc
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/apikey"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/walletpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	metadataAPIKey    = "x-api-key"
+	metadataRequestID = "x-request-id"
+)
+
+// scopes are the API key scopes of the methods, as of their REST
+// routes. Methods missing here require the admin scope.
+var scopes = map[string]string{
+	walletpb.WalletService_ListWallets_FullMethodName:  apikey.ScopeWalletsRead,
+	walletpb.WalletService_GetWallet_FullMethodName:    apikey.ScopeWalletsRead,
+	walletpb.WalletService_CreateWallet_FullMethodName: apikey.ScopeWalletsWrite,
+	walletpb.WalletService_UpdateWallet_FullMethodName: apikey.ScopeWalletsWrite,
+	walletpb.WalletService_DeleteWallet_FullMethodName: apikey.ScopeWalletsWrite,
+	walletpb.WalletService_Transfer_FullMethodName:     apikey.ScopeWalletsWrite,
+}
+
+type keyContext struct{}
+
+type authenticator struct {
+	store        apikey.Storer
+	bootstrapKey string
+}
+
+// authenticate checks the API key in the metadata of a call to method
+// and returns ctx carrying the key.
+func (a authenticator) authenticate(ctx context.Context, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	key, err := apikey.Authenticate(a.store, a.bootstrapKey, first(md, metadataAPIKey), log.Printf)
+	switch {
+	case errors.Is(err, apikey.ErrMissingKey), errors.Is(err, apikey.ErrInvalidKey), errors.Is(err, apikey.ErrInactiveKey):
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	case err != nil:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	scope, ok := scopes[method]
+	if !ok {
+		scope = apikey.ScopeAdmin
+	}
+	if !key.HasScope(scope) {
+		return nil, status.Error(codes.PermissionDenied, "API key lacks scope "+scope)
+	}
+	return context.WithValue(ctx, keyContext{}, key), nil
+}
+
+func (a authenticator) unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := a.authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a authenticator) stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := a.authenticate(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, serverStream{ServerStream: ss, ctx: ctx})
+}
+
+// serverStream is a grpc.ServerStream with the context of authenticate.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func first(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// keyFrom returns the API key that authenticated the call.
+func keyFrom(ctx context.Context) apikey.APIKey {
+	key, _ := ctx.Value(keyContext{}).(apikey.APIKey)
+	return key
+}
+
+// actorFrom identifies the caller for the audit log like wallet.ActorFrom.
+func actorFrom(ctx context.Context) wallet.Actor {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return wallet.ActorOf(keyFrom(ctx), first(md, metadataRequestID))
+}
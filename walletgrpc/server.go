@@ -0,0 +1,190 @@
+// Package walletgrpc serves the wallet service of walletpb over gRPC,
+// from the same storage as the REST API.
+package walletgrpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/apikey"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/ledger"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/walletpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type Storer interface {
+	wallet.Exporter
+	WalletByUser(userID int) (wallet.Wallet, error)
+	CreateWallet(createWallet wallet.CreateWallet, actor wallet.Actor) (wallet.Wallet, error)
+	UpdateWallet(updateWallet wallet.UpdateWallet, actor wallet.Actor) (wallet.Wallet, error)
+	DeleteWallet(userID int, actor wallet.Actor) error
+	TransferFunds(transfer ledger.Transfer, actor wallet.Actor) ([]ledger.Transaction, error)
+}
+
+type Server struct {
+	walletpb.UnimplementedWalletServiceServer
+	store Storer
+}
+
+func New(db Storer) *Server {
+	return &Server{store: db}
+}
+
+// NewServer returns a gRPC server of the wallet service whose calls are
+// authenticated against keys like those of the REST API.
+func NewServer(db Storer, keys apikey.Storer, bootstrapKey string) *grpc.Server {
+	auth := authenticator{store: keys, bootstrapKey: bootstrapKey}
+	srv := grpc.NewServer(grpc.UnaryInterceptor(auth.unary), grpc.StreamInterceptor(auth.stream))
+	walletpb.RegisterWalletServiceServer(srv, New(db))
+	return srv
+}
+
+// errStatus maps errors from the store to a gRPC status.
+func errStatus(err error) error {
+	code := codes.Internal
+	switch {
+	case errors.Is(err, wallet.ErrNotFound):
+		code = codes.NotFound
+	case errors.Is(err, wallet.ErrInvalidWallet), errors.Is(err, ledger.ErrInvalidTransfer):
+		code = codes.InvalidArgument
+	case errors.Is(err, ledger.ErrDuplicate):
+		code = codes.AlreadyExists
+	case errors.Is(err, wallet.ErrInvalidTransition),
+		errors.Is(err, wallet.ErrBalanceNotZero),
+		errors.Is(err, wallet.ErrWalletFrozen),
+		errors.Is(err, wallet.ErrWalletClosed),
+		errors.Is(err, wallet.ErrInsufficientFunds),
+		errors.Is(err, wallet.ErrCreditLimitExceeded):
+		code = codes.FailedPrecondition
+	}
+	return status.Error(code, err.Error())
+}
+
+func (s *Server) ListWallets(req *walletpb.ListWalletsRequest, stream walletpb.WalletService_ListWalletsServer) error {
+	filter := wallet.Filter{WalletType: req.WalletType, IncludeDeleted: req.IncludeDeleted}
+	if filter.IncludeDeleted && !keyFrom(stream.Context()).HasScope(apikey.ScopeAdmin) {
+		return status.Error(codes.PermissionDenied, "include_deleted requires admin scope")
+	}
+	err := s.store.EachWallet(filter, func(w wallet.Wallet) error {
+		return stream.Send(toWallet(w))
+	})
+	if _, ok := status.FromError(err); ok {
+		// nil, or Send failing because the client went away.
+		return err
+	}
+	return errStatus(err)
+}
+
+func (s *Server) GetWallet(ctx context.Context, req *walletpb.GetWalletRequest) (*walletpb.Wallet, error) {
+	w, err := s.store.WalletByUser(int(req.UserId))
+	if err != nil {
+		return nil, errStatus(err)
+	}
+	if w.UserID != int(req.UserId) {
+		return nil, errStatus(wallet.ErrNotFound)
+	}
+	return toWallet(w), nil
+}
+
+func (s *Server) CreateWallet(ctx context.Context, req *walletpb.CreateWalletRequest) (*walletpb.Wallet, error) {
+	createWallet := wallet.CreateWallet{
+		UserID:      int(req.UserId),
+		UserName:    req.UserName,
+		WalletName:  req.WalletName,
+		WalletType:  req.WalletType,
+		Balance:     req.Balance,
+		CreditLimit: req.CreditLimit,
+	}
+	if err := createWallet.Validate(); err != nil {
+		return nil, errStatus(err)
+	}
+	w, err := s.store.CreateWallet(createWallet, actorFrom(ctx))
+	if err != nil {
+		return nil, errStatus(err)
+	}
+	return toWallet(w), nil
+}
+
+func (s *Server) UpdateWallet(ctx context.Context, req *walletpb.UpdateWalletRequest) (*walletpb.Wallet, error) {
+	updateWallet := wallet.UpdateWallet{
+		ID:          int(req.Id),
+		UserID:      int(req.UserId),
+		UserName:    req.UserName,
+		WalletName:  req.WalletName,
+		WalletType:  req.WalletType,
+		Balance:     req.Balance,
+		CreditLimit: req.CreditLimit,
+	}
+	if err := updateWallet.Validate(); err != nil {
+		return nil, errStatus(err)
+	}
+	w, err := s.store.UpdateWallet(updateWallet, actorFrom(ctx))
+	if err != nil {
+		return nil, errStatus(err)
+	}
+	return toWallet(w), nil
+}
+
+func (s *Server) DeleteWallet(ctx context.Context, req *walletpb.DeleteWalletRequest) (*emptypb.Empty, error) {
+	if err := s.store.DeleteWallet(int(req.UserId), actorFrom(ctx)); err != nil {
+		return nil, errStatus(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) Transfer(ctx context.Context, req *walletpb.TransferRequest) (*walletpb.TransferResponse, error) {
+	transfer := ledger.Transfer{
+		FromWalletID: int(req.FromWalletId),
+		ToWalletID:   int(req.ToWalletId),
+		Amount:       req.Amount,
+		Description:  req.Description,
+		Reference:    req.Reference,
+	}
+	if err := transfer.Validate(); err != nil {
+		return nil, errStatus(err)
+	}
+	transactions, err := s.store.TransferFunds(transfer, actorFrom(ctx))
+	if err != nil {
+		return nil, errStatus(err)
+	}
+	res := &walletpb.TransferResponse{}
+	for _, t := range transactions {
+		res.Transactions = append(res.Transactions, &walletpb.Transaction{
+			Id:           int64(t.ID),
+			WalletId:     int64(t.WalletID),
+			Amount:       t.Amount,
+			BalanceAfter: t.BalanceAfter,
+			Kind:         t.Kind,
+			Description:  t.Description,
+			Reference:    t.Reference,
+			CreatedAt:    timestamppb.New(t.CreatedAt),
+		})
+	}
+	return res, nil
+}
+
+func toWallet(w wallet.Wallet) *walletpb.Wallet {
+	pb := &walletpb.Wallet{
+		Id:                 int64(w.ID),
+		UserId:             int64(w.UserID),
+		UserName:           w.UserName,
+		WalletName:         w.WalletName,
+		WalletType:         w.WalletType,
+		Balance:            w.Balance,
+		AvailableBalance:   w.AvailableBalance,
+		Status:             string(w.Status),
+		CreditLimit:        w.CreditLimit,
+		AvailableCredit:    w.AvailableCredit,
+		OutstandingBalance: w.OutstandingBalance,
+		CreatedAt:          timestamppb.New(w.CreatedAt),
+	}
+	if w.DeletedAt != nil {
+		pb.DeletedAt = timestamppb.New(*w.DeletedAt)
+	}
+	return pb
+}
@@ -0,0 +1,225 @@
+package walletgrpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/apikey"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/ledger"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/walletpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type StubStorer struct {
+	wallets   []wallet.Wallet
+	transfers []ledger.Transfer
+	actor     wallet.Actor
+	err       error
+}
+
+func (s *StubStorer) EachWallet(filter wallet.Filter, fn func(wallet.Wallet) error) error {
+	for _, w := range s.wallets {
+		if filter.WalletType != "" && w.WalletType != filter.WalletType {
+			continue
+		}
+		if err := fn(w); err != nil {
+			return err
+		}
+	}
+	return s.err
+}
+
+func (s *StubStorer) WalletByUser(userID int) (wallet.Wallet, error) {
+	for _, w := range s.wallets {
+		if w.UserID == userID {
+			return w, nil
+		}
+	}
+	return wallet.Wallet{}, s.err
+}
+
+func (s *StubStorer) CreateWallet(createWallet wallet.CreateWallet, actor wallet.Actor) (wallet.Wallet, error) {
+	s.actor = actor
+	w := wallet.Wallet{ID: len(s.wallets) + 1, UserID: createWallet.UserID, WalletType: createWallet.WalletType}
+	s.wallets = append(s.wallets, w)
+	return w, s.err
+}
+
+func (s *StubStorer) UpdateWallet(updateWallet wallet.UpdateWallet, actor wallet.Actor) (wallet.Wallet, error) {
+	return wallet.Wallet{}, s.err
+}
+
+func (s *StubStorer) DeleteWallet(userID int, actor wallet.Actor) error {
+	return s.err
+}
+
+func (s *StubStorer) TransferFunds(transfer ledger.Transfer, actor wallet.Actor) ([]ledger.Transaction, error) {
+	for _, t := range s.transfers {
+		if t.Reference == transfer.Reference {
+			return nil, ledger.ErrDuplicate
+		}
+	}
+	s.transfers = append(s.transfers, transfer)
+	debit, credit := transfer.Legs()
+	return []ledger.Transaction{
+		{ID: 1, WalletID: debit.WalletID, Amount: debit.Amount, Kind: debit.Kind, Reference: debit.Reference},
+		{ID: 2, WalletID: credit.WalletID, Amount: credit.Amount, Kind: credit.Kind, Reference: credit.Reference},
+	}, s.err
+}
+
+type StubKeys struct {
+	apikey.Storer
+	keys map[string]apikey.APIKey
+}
+
+func (s StubKeys) APIKeyByHash(hash string) (apikey.APIKey, error) {
+	for raw, key := range s.keys {
+		if apikey.Hash(raw) == hash {
+			return key, nil
+		}
+	}
+	return apikey.APIKey{}, apikey.ErrNotFound
+}
+
+func (s StubKeys) TouchAPIKey(id int, usedAt time.Time) error {
+	return nil
+}
+
+func dial(t *testing.T, store Storer) walletpb.WalletServiceClient {
+	t.Helper()
+	now := time.Now()
+	keys := StubKeys{keys: map[string]apikey.APIKey{
+		"reader": {ID: 1, Name: "reader", Scopes: []string{apikey.ScopeWalletsRead}, LastUsedAt: &now},
+		"writer": {ID: 2, Name: "writer", Scopes: []string{apikey.ScopeWalletsWrite}, LastUsedAt: &now},
+	}}
+	lis := bufconn.Listen(1 << 20)
+	srv := NewServer(store, keys, "")
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return walletpb.NewWalletServiceClient(conn)
+}
+
+func withKey(key string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), metadataAPIKey, key, metadataRequestID, "req-1")
+}
+
+func TestListWallets(t *testing.T) {
+	store := &StubStorer{wallets: []wallet.Wallet{
+		{ID: 1, UserID: 1, WalletType: wallet.TypeSavings, CreatedAt: time.Date(2024, 4, 12, 0, 0, 0, 0, time.UTC)},
+		{ID: 2, UserID: 2, WalletType: wallet.TypeCreditCard},
+		{ID: 3, UserID: 3, WalletType: wallet.TypeSavings},
+	}}
+	client := dial(t, store)
+
+	stream, err := client.ListWallets(withKey("reader"), &walletpb.ListWalletsRequest{WalletType: wallet.TypeSavings})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int64
+	for {
+		w, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, w.Id)
+	}
+	if len(ids) != 2 || ids[0] != 1 || ids[1] != 3 {
+		t.Errorf("expected wallets 1 and 3 but got %v", ids)
+	}
+
+	stream, _ = client.ListWallets(withKey("reader"), &walletpb.ListWalletsRequest{IncludeDeleted: true})
+	if _, err := stream.Recv(); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("expected PermissionDenied for include_deleted but got %v", err)
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	client := dial(t, &StubStorer{})
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want codes.Code
+	}{
+		{"missing key", context.Background(), codes.Unauthenticated},
+		{"unknown key", withKey("nope"), codes.Unauthenticated},
+		{"read key writing", withKey("reader"), codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := client.CreateWallet(tt.ctx, &walletpb.CreateWalletRequest{UserId: 1, WalletType: wallet.TypeSavings})
+			if status.Code(err) != tt.want {
+				t.Errorf("expected %v but got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestCreateWallet(t *testing.T) {
+	store := &StubStorer{}
+	client := dial(t, store)
+
+	w, err := client.CreateWallet(withKey("writer"), &walletpb.CreateWalletRequest{UserId: 7, WalletType: wallet.TypeSavings})
+	if err != nil || w.UserId != 7 {
+		t.Fatalf("unexpected wallet %v: %v", w, err)
+	}
+	if want := (wallet.Actor{Name: "api-key:2", RequestID: "req-1"}); store.actor != want {
+		t.Errorf("expected actor %+v but got %+v", want, store.actor)
+	}
+
+	_, err = client.CreateWallet(withKey("writer"), &walletpb.CreateWalletRequest{UserId: 7, WalletType: "Piggy Bank"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument but got %v", err)
+	}
+}
+
+func TestGetWallet(t *testing.T) {
+	client := dial(t, &StubStorer{wallets: []wallet.Wallet{{ID: 1, UserID: 1}}})
+
+	if w, err := client.GetWallet(withKey("reader"), &walletpb.GetWalletRequest{UserId: 1}); err != nil || w.Id != 1 {
+		t.Errorf("unexpected wallet %v: %v", w, err)
+	}
+	if _, err := client.GetWallet(withKey("reader"), &walletpb.GetWalletRequest{UserId: 2}); status.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound but got %v", err)
+	}
+}
+
+func TestTransfer(t *testing.T) {
+	client := dial(t, &StubStorer{})
+	req := &walletpb.TransferRequest{FromWalletId: 1, ToWalletId: 2, Amount: 25, Reference: "invoice-9"}
+
+	res, err := client.Transfer(withKey("writer"), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Transactions) != 2 || res.Transactions[0].Amount != -25 || res.Transactions[1].Reference != "invoice-9:credit" {
+		t.Errorf("unexpected transactions %v", res.Transactions)
+	}
+	if _, err := client.Transfer(withKey("writer"), req); status.Code(err) != codes.AlreadyExists {
+		t.Errorf("expected AlreadyExists for a retried transfer but got %v", err)
+	}
+
+	req = &walletpb.TransferRequest{FromWalletId: 1, ToWalletId: 1, Amount: 25}
+	if _, err := client.Transfer(withKey("writer"), req); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument but got %v", err)
+	}
+}
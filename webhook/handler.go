@@ -0,0 +1,166 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+type Handler struct {
+	store Storer
+}
+
+type Storer interface {
+	Subscriptions() ([]Subscription, error)
+	CreateSubscription(createSubscription CreateSubscription) (Subscription, error)
+	DeleteSubscription(id int) error
+	// Deliveries lists the latest deliveries with the status, or of any
+	// status when it is empty, newest first.
+	Deliveries(status string) ([]Delivery, error)
+	// Redeliver makes a delivery pending again, due now, with its attempts
+	// reset.
+	Redeliver(id int) (Delivery, error)
+}
+
+func New(db Storer) *Handler {
+	return &Handler{store: db}
+}
+
+type Err struct {
+	Message string `json:"message"`
+}
+
+// SubscriptionsHandler
+//
+//	@Summary		List webhook subscriptions
+//	@Description	List webhook subscriptions. Secrets are never returned.
+//	@Tags			webhook
+//	@Produce		json
+//	@Success		200	{array}		Subscription
+//	@Router			/api/v1/admin/webhooks [get]
+//	@Failure		500	{object}	Err
+//	@Security		ApiKeyAuth
+func (h *Handler) SubscriptionsHandler(c echo.Context) error {
+	subscriptions, err := h.store.Subscriptions()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, Err{Message: err.Error()})
+	}
+	return c.JSON(http.StatusOK, subscriptions)
+}
+
+// CreateSubscription
+//
+//	@Summary		Create webhook subscription
+//	@Description	Subscribe a URL to wallet events. Deliveries are signed with HMAC-SHA256 of the secret in the Webhook-Signature header; a secret is generated unless one is given, and is only returned in this response.
+//	@Tags			webhook
+//	@Accept			json
+//	@Produce		json
+//	@Success		201	{object}	IssuedSubscription
+//	@Router			/api/v1/admin/webhooks [post]
+//	@Failure		400	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Param			CreateSubscription body CreateSubscription true "Body for create webhook subscription"
+//	@Security		ApiKeyAuth
+func (h *Handler) CreateSubscription(c echo.Context) error {
+	var createSubscription CreateSubscription
+	if err := c.Bind(&createSubscription); err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+	if err := createSubscription.Validate(); err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: err.Error()})
+	}
+	if createSubscription.Secret == "" {
+		secret, err := NewSecret()
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, Err{Message: err.Error()})
+		}
+		createSubscription.Secret = secret
+	}
+	result, err := h.store.CreateSubscription(createSubscription)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, Err{Message: err.Error()})
+	}
+	return c.JSON(http.StatusCreated, IssuedSubscription{Subscription: result, Secret: createSubscription.Secret})
+}
+
+// DeleteSubscription
+//
+//	@Summary		Delete webhook subscription
+//	@Description	Delete webhook subscription, along with its pending and dead deliveries.
+//	@Tags			webhook
+//	@Produce		plain
+//	@Success		200	{string}	string
+//	@Router			/api/v1/admin/webhooks/{id} [delete]
+//	@Failure		404	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Param			id path int true "Subscription ID"
+//	@Security		ApiKeyAuth
+func (h *Handler) DeleteSubscription(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: "invalid subscription id"})
+	}
+	err = h.store.DeleteSubscription(id)
+	if errors.Is(err, ErrNotFound) {
+		return c.JSON(http.StatusNotFound, Err{Message: err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, Err{Message: err.Error()})
+	}
+	return c.String(http.StatusOK, "Delete Success")
+}
+
+// DeliveriesHandler
+//
+//	@Summary		List webhook deliveries
+//	@Description	List the latest 100 webhook deliveries, newest first. status=dead lists the dead-letter deliveries that gave up after all retries.
+//	@Tags			webhook
+//	@Produce		json
+//	@Param			status	query	string	false	"pending, delivered or dead"	Enums(pending, delivered, dead)
+//	@Success		200		{array}		Delivery
+//	@Router			/api/v1/admin/webhooks/deliveries [get]
+//	@Failure		400		{object}	Err
+//	@Failure		500		{object}	Err
+//	@Security		ApiKeyAuth
+func (h *Handler) DeliveriesHandler(c echo.Context) error {
+	status := c.QueryParam("status")
+	switch status {
+	case "", StatusPending, StatusDelivered, StatusDead:
+	default:
+		return c.JSON(http.StatusBadRequest, Err{Message: "status must be one of pending, delivered, dead"})
+	}
+	deliveries, err := h.store.Deliveries(status)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, Err{Message: err.Error()})
+	}
+	return c.JSON(http.StatusOK, deliveries)
+}
+
+// Redeliver
+//
+//	@Summary		Redeliver webhook
+//	@Description	Send a delivery again, such as a dead-letter one once the receiver is fixed. It is retried as usual if it fails again.
+//	@Tags			webhook
+//	@Produce		json
+//	@Success		200	{object}	Delivery
+//	@Router			/api/v1/admin/webhooks/deliveries/{id}/redeliver [post]
+//	@Failure		404	{object}	Err
+//	@Failure		500	{object}	Err
+//	@Param			id path int true "Delivery ID"
+//	@Security		ApiKeyAuth
+func (h *Handler) Redeliver(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, Err{Message: "invalid delivery id"})
+	}
+	result, err := h.store.Redeliver(id)
+	if errors.Is(err, ErrNotFound) {
+		return c.JSON(http.StatusNotFound, Err{Message: err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, Err{Message: err.Error()})
+	}
+	return c.JSON(http.StatusOK, result)
+}
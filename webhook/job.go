@@ -0,0 +1,90 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// claimBatch is how many deliveries a run sends at once.
+	claimBatch = 20
+	// claimLease is how long a claimed delivery is left to the replica
+	// that claimed it before another may send it again.
+	claimLease = time.Minute
+	// sendTimeout bounds how long a receiver can take to respond.
+	sendTimeout = 10 * time.Second
+)
+
+// Dispatcher claims due deliveries and records their attempts.
+type Dispatcher interface {
+	// ClaimDeliveries returns up to limit pending deliveries due at now
+	// and pushes their next attempt lease past now, so that several
+	// replicas can dispatch at once.
+	ClaimDeliveries(now time.Time, limit int, lease time.Duration) ([]Delivery, error)
+	RecordDelivery(delivery Delivery) error
+}
+
+// DeliveryJob returns a job that sends every due delivery.
+func DeliveryJob(store Dispatcher) func(ctx context.Context) error {
+	client := &http.Client{Timeout: sendTimeout}
+	return func(ctx context.Context) error {
+		return Dispatch(ctx, store, client, time.Now)
+	}
+}
+
+// Dispatch sends due deliveries in batches until none is left. Failed
+// deliveries are recorded to be retried rather than returned.
+func Dispatch(ctx context.Context, store Dispatcher, client *http.Client, now func() time.Time) error {
+	for ctx.Err() == nil {
+		deliveries, err := store.ClaimDeliveries(now(), claimBatch, claimLease)
+		if err != nil || len(deliveries) == 0 {
+			return err
+		}
+		var wg sync.WaitGroup
+		errs := make([]error, len(deliveries))
+		for i, d := range deliveries {
+			wg.Add(1)
+			go func(i int, d Delivery) {
+				defer wg.Done()
+				d = Send(ctx, client, d, now)
+				if d.Status != StatusDelivered {
+					log.Printf("webhook delivery %d attempt %d failed: %s", d.ID, d.Attempts, d.LastError)
+				}
+				errs[i] = store.RecordDelivery(d)
+			}(i, d)
+		}
+		wg.Wait()
+		for _, err := range errs {
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return ctx.Err()
+}
+
+// Send posts d to its subscription's URL, signed with its secret, and
+// returns d with the attempt recorded.
+func Send(ctx context.Context, client *http.Client, d Delivery, now func() time.Time) Delivery {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return d.Record(now(), 0, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderID, d.EventID)
+	req.Header.Set(HeaderEvent, d.EventType)
+	req.Header.Set(HeaderSignature, Sign(d.Secret, now(), d.Payload))
+	res, err := client.Do(req)
+	if err != nil {
+		return d.Record(now(), 0, err)
+	}
+	// Draining the body lets the connection be reused.
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+	res.Body.Close()
+	return d.Record(now(), res.StatusCode, nil)
+}
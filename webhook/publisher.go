@@ -0,0 +1,23 @@
+package webhook
+
+import (
+	"context"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/outbox"
+)
+
+// Enqueuer queues the deliveries of events.
+type Enqueuer interface {
+	// EnqueueDeliveries queues a delivery of the message, whose payload
+	// is an Event, to every subscription to its type. A subscription gets
+	// one delivery per event however often it is called.
+	EnqueueDeliveries(message outbox.Message) error
+}
+
+// Publisher publishes outbox messages as webhooks, by queueing their
+// deliveries for DeliveryJob.
+func Publisher(store Enqueuer) outbox.Publisher {
+	return outbox.PublisherFunc(func(ctx context.Context, message outbox.Message) error {
+		return store.EnqueueDeliveries(message)
+	})
+}
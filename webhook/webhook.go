@@ -0,0 +1,216 @@
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/asset"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet"
+)
+
+const (
+	EventWalletCreated        = "wallet.created"
+	EventWalletUpdated        = "wallet.updated"
+	EventWalletBalanceChanged = "wallet.balance_changed"
+	EventWalletDeleted        = "wallet.deleted"
+	EventWalletHoldingChanged = "wallet.holding_changed"
+
+	StatusPending   = "pending"
+	StatusDelivered = "delivered"
+	// StatusDead deliveries gave up after MaxAttempts; they make up the
+	// dead-letter list and can be redelivered by hand.
+	StatusDead = "dead"
+
+	// A failed delivery is retried after RetryBackoff, doubling with
+	// every attempt, until MaxAttempts.
+	MaxAttempts  = 8
+	RetryBackoff = 30 * time.Second
+
+	secretPrefix = "whsec_"
+	eventPrefix  = "evt_"
+)
+
+// Events are the event types a subscription can ask for.
+var Events = []string{EventWalletCreated, EventWalletUpdated, EventWalletBalanceChanged, EventWalletDeleted, EventWalletHoldingChanged}
+
+var (
+	ErrNotFound            = errors.New("webhook not found")
+	ErrInvalidSubscription = errors.New("invalid webhook subscription")
+)
+
+type Subscription struct {
+	ID        int       `json:"id" example:"1"`
+	URL       string    `json:"url" example:"https://example.com/hooks/wallets"`
+	Events    []string  `json:"events" example:"wallet.balance_changed"`
+	CreatedAt time.Time `json:"created_at" example:"2024-03-25T14:19:00.729237Z"`
+}
+
+// IssuedSubscription is returned only when a subscription is created;
+// the secret is not listed again.
+type IssuedSubscription struct {
+	Subscription
+	Secret string `json:"secret" example:"whsec_3f9a1c2e..."`
+}
+
+// CreateSubscription subscribes URL to the events. A secret is generated
+// when Secret is empty.
+type CreateSubscription struct {
+	URL    string   `json:"url" example:"https://example.com/hooks/wallets"`
+	Events []string `json:"events" example:"wallet.balance_changed"`
+	Secret string   `json:"secret,omitempty" example:"a-long-shared-secret"`
+}
+
+func (c CreateSubscription) Validate() error {
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidSubscription)
+	}
+	if len(c.Events) == 0 {
+		return fmt.Errorf("%w: events must not be empty", ErrInvalidSubscription)
+	}
+	for _, event := range c.Events {
+		if !slices.Contains(Events, event) {
+			return fmt.Errorf("%w: unknown event %q", ErrInvalidSubscription, event)
+		}
+	}
+	if c.Secret != "" && len(c.Secret) < 16 {
+		return fmt.Errorf("%w: secret must be at least 16 characters", ErrInvalidSubscription)
+	}
+	return nil
+}
+
+func NewSecret() (string, error) {
+	return randomID(secretPrefix, 24)
+}
+
+func randomID(prefix string, n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return prefix + hex.EncodeToString(b), nil
+}
+
+// Event is the body of a webhook delivery.
+type Event struct {
+	ID        string    `json:"id" example:"evt_5c1e0f2a9b7d4e3c8a6f1b2d"`
+	Type      string    `json:"type" example:"wallet.balance_changed"`
+	CreatedAt time.Time `json:"created_at" example:"2024-03-25T14:19:00.729237Z"`
+	Data      EventData `json:"data"`
+}
+
+type EventData struct {
+	Wallet wallet.Wallet `json:"wallet"`
+	// Previous is the wallet before the change, for updates.
+	Previous *wallet.Wallet `json:"previous,omitempty"`
+	// Holding and PreviousHolding are the crypto holding of the wallet
+	// after and before a holding_changed event.
+	Holding         *asset.Holding `json:"holding,omitempty"`
+	PreviousHolding *asset.Holding `json:"previous_holding,omitempty"`
+}
+
+// WalletEvents returns the events of a wallet changing from before to
+// after: created when before is nil, deleted when after is deleted, and
+// otherwise balance_changed and/or updated depending on what changed.
+// Placing or releasing a hold changes the available balance, which is a
+// balance_changed too.
+func WalletEvents(before, after *wallet.Wallet, now time.Time) ([]Event, error) {
+	var types []string
+	data := EventData{Wallet: *after, Previous: before}
+	switch {
+	case before == nil:
+		types, data.Previous = []string{EventWalletCreated}, nil
+	case after.DeletedAt != nil && before.DeletedAt == nil:
+		types, data.Previous = []string{EventWalletDeleted}, nil
+	default:
+		if after.Balance != before.Balance || after.AvailableBalance != before.AvailableBalance {
+			types = append(types, EventWalletBalanceChanged)
+		}
+		if updated(*before, *after) {
+			types = append(types, EventWalletUpdated)
+		}
+	}
+
+	events := make([]Event, len(types))
+	for i, eventType := range types {
+		id, err := randomID(eventPrefix, 12)
+		if err != nil {
+			return nil, err
+		}
+		events[i] = Event{ID: id, Type: eventType, CreatedAt: now.UTC(), Data: data}
+	}
+	return events, nil
+}
+
+// HoldingEvent returns the holding_changed event of a crypto holding of
+// w changing from before, nil when it was just added, to after.
+func HoldingEvent(w wallet.Wallet, before *asset.Holding, after asset.Holding, now time.Time) (Event, error) {
+	id, err := randomID(eventPrefix, 12)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{ID: id, Type: EventWalletHoldingChanged, CreatedAt: now.UTC(),
+		Data: EventData{Wallet: w, Holding: &after, PreviousHolding: before}}, nil
+}
+
+// updated reports whether anything but the balance, and the amounts
+// derived from it, changed; restoring a wallet also counts.
+func updated(before, after wallet.Wallet) bool {
+	return before.UserName != after.UserName ||
+		before.WalletName != after.WalletName ||
+		before.WalletType != after.WalletType ||
+		before.Status != after.Status ||
+		(before.CreditLimit == nil) != (after.CreditLimit == nil) ||
+		(before.CreditLimit != nil && *before.CreditLimit != *after.CreditLimit) ||
+		(before.DeletedAt == nil) != (after.DeletedAt == nil)
+}
+
+// Delivery is an event on its way to a subscription.
+type Delivery struct {
+	ID             int             `json:"id" example:"1"`
+	SubscriptionID int             `json:"subscription_id" example:"1"`
+	EventID        string          `json:"event_id" example:"evt_5c1e0f2a9b7d4e3c8a6f1b2d"`
+	EventType      string          `json:"event_type" example:"wallet.balance_changed"`
+	Payload        json.RawMessage `json:"payload" swaggertype:"object"`
+	Status         string          `json:"status" example:"dead"`
+	Attempts       int             `json:"attempts" example:"8"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty" example:"2024-03-25T14:19:30Z"`
+	LastError      string          `json:"last_error,omitempty" example:"receiver responded 503"`
+	LastStatusCode int             `json:"last_status_code,omitempty" example:"503"`
+	CreatedAt      time.Time       `json:"created_at" example:"2024-03-25T14:19:00.729237Z"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty" example:"2024-03-25T14:19:01Z"`
+	// URL and Secret are those of the subscription, set on deliveries
+	// claimed to be sent.
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
+
+// Record returns d after an attempt made at now, which got statusCode
+// from the receiver or failed with err. Failed deliveries are retried
+// with exponential backoff up to MaxAttempts, then dead-lettered.
+func (d Delivery) Record(now time.Time, statusCode int, err error) Delivery {
+	d.Attempts++
+	d.LastStatusCode = statusCode
+	if err == nil && statusCode >= 200 && statusCode < 300 {
+		d.Status, d.LastError, d.NextAttemptAt, d.DeliveredAt = StatusDelivered, "", nil, &now
+		return d
+	}
+	if err != nil {
+		d.LastError = err.Error()
+	} else {
+		d.LastError = fmt.Sprintf("receiver responded %d", statusCode)
+	}
+	if d.Attempts >= MaxAttempts {
+		d.Status, d.NextAttemptAt = StatusDead, nil
+		return d
+	}
+	retry := now.Add(RetryBackoff << (d.Attempts - 1))
+	d.Status, d.NextAttemptAt = StatusPending, &retry
+	return d
+}
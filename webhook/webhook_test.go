@@ -0,0 +1,363 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/KKGo-Software-engineering/fun-exercise-api/asset"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/outbox"
+	"github.com/KKGo-Software-engineering/fun-exercise-api/wallet"
+	"github.com/labstack/echo/v4"
+)
+
+type StubStorer struct {
+	subscriptions []Subscription
+	secrets       []string
+	deliveries    []Delivery
+	status        string
+	err           error
+}
+
+func (s *StubStorer) Subscriptions() ([]Subscription, error) {
+	return s.subscriptions, s.err
+}
+
+func (s *StubStorer) CreateSubscription(createSubscription CreateSubscription) (Subscription, error) {
+	sub := Subscription{
+		ID:        len(s.subscriptions) + 1,
+		URL:       createSubscription.URL,
+		Events:    createSubscription.Events,
+		CreatedAt: time.Date(2024, 04, 12, 10, 45, 16, 0, time.UTC),
+	}
+	s.subscriptions = append(s.subscriptions, sub)
+	s.secrets = append(s.secrets, createSubscription.Secret)
+	return sub, s.err
+}
+
+func (s *StubStorer) DeleteSubscription(id int) error {
+	for i, sub := range s.subscriptions {
+		if sub.ID == id {
+			s.subscriptions = append(s.subscriptions[:i], s.subscriptions[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *StubStorer) Deliveries(status string) ([]Delivery, error) {
+	s.status = status
+	return s.deliveries, s.err
+}
+
+func (s *StubStorer) EnqueueDeliveries(message outbox.Message) error {
+	s.deliveries = append(s.deliveries, Delivery{ID: len(s.deliveries) + 1, EventID: message.EventID,
+		EventType: message.Type, Payload: message.Payload, Status: StatusPending})
+	return s.err
+}
+
+func (s *StubStorer) Redeliver(id int) (Delivery, error) {
+	for _, d := range s.deliveries {
+		if d.ID == id {
+			d.Status, d.Attempts = StatusPending, 0
+			return d, nil
+		}
+	}
+	return Delivery{}, ErrNotFound
+}
+
+// StubDispatcher hands out its deliveries once.
+type StubDispatcher struct {
+	mu       sync.Mutex
+	pending  []Delivery
+	recorded []Delivery
+}
+
+func (s *StubDispatcher) ClaimDeliveries(now time.Time, limit int, lease time.Duration) ([]Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := min(limit, len(s.pending))
+	claimed := s.pending[:n]
+	s.pending = s.pending[n:]
+	return claimed, nil
+}
+
+func (s *StubDispatcher) RecordDelivery(d Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recorded = append(s.recorded, d)
+	return nil
+}
+
+func TestSignature(t *testing.T) {
+	now := time.Date(2024, 04, 12, 10, 45, 16, 0, time.UTC)
+	body := []byte(`{"id":"evt_1"}`)
+	header := Sign("a-long-shared-secret", now, body)
+
+	tests := []struct {
+		name   string
+		secret string
+		body   []byte
+		now    time.Time
+		want   error
+	}{
+		{"given matching secret and body should verify", "a-long-shared-secret", body, now, nil},
+		{"given another secret should fail", "another-long-secret", body, now, ErrInvalidSignature},
+		{"given tampered body should fail", "a-long-shared-secret", []byte(`{"id":"evt_2"}`), now, ErrInvalidSignature},
+		{"given stale signature should fail", "a-long-shared-secret", body, now.Add(10 * time.Minute), ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Verify(tt.secret, header, tt.body, tt.now, 5*time.Minute); !errors.Is(err, tt.want) {
+				t.Errorf("expected %v but got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestRecord(t *testing.T) {
+	now := time.Date(2024, 04, 12, 10, 45, 16, 0, time.UTC)
+
+	t.Run("given 2xx should be delivered", func(t *testing.T) {
+		d := Delivery{Status: StatusPending, LastError: "receiver responded 503"}.Record(now, http.StatusNoContent, nil)
+		if d.Status != StatusDelivered || d.DeliveredAt == nil || d.NextAttemptAt != nil || d.LastError != "" {
+			t.Errorf("expected delivered delivery but got %+v", d)
+		}
+	})
+
+	t.Run("given failures should back off exponentially", func(t *testing.T) {
+		d := Delivery{Status: StatusPending}
+		for attempt, want := range []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute} {
+			d = d.Record(now, http.StatusServiceUnavailable, nil)
+			if d.Status != StatusPending || d.NextAttemptAt == nil || d.NextAttemptAt.Sub(now) != want {
+				t.Fatalf("attempt %d: expected retry in %v but got %+v", attempt+1, want, d)
+			}
+		}
+		if d.LastError != "receiver responded 503" || d.LastStatusCode != http.StatusServiceUnavailable {
+			t.Errorf("expected last error to be recorded but got %+v", d)
+		}
+	})
+
+	t.Run("given last attempt fails should be dead", func(t *testing.T) {
+		d := Delivery{Status: StatusPending, Attempts: MaxAttempts - 1}.Record(now, 0, errors.New("connection refused"))
+		if d.Status != StatusDead || d.NextAttemptAt != nil || d.LastError != "connection refused" {
+			t.Errorf("expected dead delivery but got %+v", d)
+		}
+	})
+}
+
+func TestWalletEvents(t *testing.T) {
+	now := time.Now()
+	before := wallet.Wallet{ID: 1, WalletName: "John's Savings", Balance: 100, Status: wallet.StatusActive}
+	renamed := before
+	renamed.WalletName = "Rainy day"
+	credited := before
+	credited.Balance = 150
+	deleted := before
+	deleted.DeletedAt = &now
+	frozen := credited
+	frozen.Status = wallet.StatusFrozen
+	held := before
+	held.AvailableBalance = 75
+
+	tests := []struct {
+		name   string
+		before *wallet.Wallet
+		after  wallet.Wallet
+		want   []string
+	}{
+		{"given no before should be created", nil, before, []string{EventWalletCreated}},
+		{"given renamed should be updated", &before, renamed, []string{EventWalletUpdated}},
+		{"given credited should be balance changed", &before, credited, []string{EventWalletBalanceChanged}},
+		{"given deleted should be deleted", &before, deleted, []string{EventWalletDeleted}},
+		{"given restored should be updated", &deleted, before, []string{EventWalletUpdated}},
+		{"given credited and frozen should be both", &before, frozen, []string{EventWalletBalanceChanged, EventWalletUpdated}},
+		{"given a hold placed should be balance changed", &before, held, []string{EventWalletBalanceChanged}},
+		{"given nothing changed should be none", &before, before, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := WalletEvents(tt.before, &tt.after, now)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var got []string
+			for _, event := range events {
+				got = append(got, event.Type)
+				if event.Data.Wallet.ID != tt.after.ID {
+					t.Errorf("expected event for wallet %d but got %d", tt.after.ID, event.Data.Wallet.ID)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected events %v but got %v", tt.want, got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("expected events %v but got %v", tt.want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestHoldingEvent(t *testing.T) {
+	w := wallet.Wallet{ID: 3, UserID: 3, WalletType: wallet.TypeCrypto}
+	before := asset.Holding{WalletID: 3, Asset: "BTC", Balance: "0"}
+	after := before
+	after.Balance = "0.00012345"
+	event, err := HoldingEvent(w, &before, after, time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if event.Type != EventWalletHoldingChanged || event.Data.Wallet.ID != 3 ||
+		event.Data.Holding.Balance != after.Balance || event.Data.PreviousHolding.Balance != "0" {
+		t.Errorf("unexpected event %+v", event)
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	const secret = "a-long-shared-secret"
+	var mu sync.Mutex
+	received := map[string]string{}
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := Verify(secret, r.Header.Get(HeaderSignature), body, time.Now(), time.Minute); err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mu.Lock()
+		received[r.Header.Get(HeaderID)] = r.Header.Get(HeaderEvent)
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	payload := json.RawMessage(`{"id":"evt_1","type":"wallet.created"}`)
+	store := &StubDispatcher{pending: []Delivery{
+		{ID: 1, EventID: "evt_1", EventType: EventWalletCreated, Payload: payload, Status: StatusPending, URL: receiver.URL, Secret: secret},
+		{ID: 2, EventID: "evt_2", EventType: EventWalletCreated, Payload: payload, Status: StatusPending, URL: receiver.URL, Secret: "a-wrong-shared-secret"},
+	}}
+
+	if err := Dispatch(context.Background(), store, receiver.Client(), time.Now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(received) != 1 || received["evt_1"] != EventWalletCreated {
+		t.Errorf("expected only evt_1 to be received but got %v", received)
+	}
+	if len(store.recorded) != 2 {
+		t.Fatalf("expected 2 recorded deliveries but got %d", len(store.recorded))
+	}
+	for _, d := range store.recorded {
+		want := StatusDelivered
+		if d.ID == 2 {
+			want = StatusPending
+		}
+		if d.Status != want || d.Attempts != 1 {
+			t.Errorf("expected delivery %d to be %s after 1 attempt but got %+v", d.ID, want, d)
+		}
+	}
+}
+
+func TestPublisher(t *testing.T) {
+	store := &StubStorer{}
+	message := outbox.Message{EventID: "evt_1", Type: EventWalletCreated, Payload: json.RawMessage(`{"id":"evt_1"}`)}
+
+	if err := Publisher(store).Publish(context.Background(), message); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(store.deliveries) != 1 || store.deliveries[0].EventID != "evt_1" || store.deliveries[0].Status != StatusPending {
+		t.Errorf("expected a pending delivery of evt_1 but got %+v", store.deliveries)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	t.Run("given valid request should generate a secret", func(t *testing.T) {
+		store := &StubStorer{}
+		body, _ := json.Marshal(CreateSubscription{URL: "https://example.com/hooks", Events: []string{EventWalletCreated}})
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		res := httptest.NewRecorder()
+		c := echo.New().NewContext(req, res)
+
+		New(store).CreateSubscription(c)
+
+		if res.Code != http.StatusCreated {
+			t.Fatalf("expected status code %d but got %d", http.StatusCreated, res.Code)
+		}
+		var got IssuedSubscription
+		if err := json.Unmarshal(res.Body.Bytes(), &got); err != nil {
+			t.Fatalf("Unable to unmarshal json: %v", err)
+		}
+		if got.Secret == "" || store.secrets[0] != got.Secret {
+			t.Errorf("expected generated secret %q to be stored but got %q", got.Secret, store.secrets[0])
+		}
+	})
+
+	t.Run("given invalid subscription should return 400", func(t *testing.T) {
+		for _, create := range []CreateSubscription{
+			{URL: "ftp://example.com", Events: []string{EventWalletCreated}},
+			{URL: "https://example.com/hooks", Events: []string{"wallet.exploded"}},
+			{URL: "https://example.com/hooks", Events: []string{EventWalletCreated}, Secret: "short"},
+		} {
+			body, _ := json.Marshal(create)
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			res := httptest.NewRecorder()
+			c := echo.New().NewContext(req, res)
+
+			New(&StubStorer{}).CreateSubscription(c)
+
+			if res.Code != http.StatusBadRequest {
+				t.Errorf("expected status code %d for %+v but got %d", http.StatusBadRequest, create, res.Code)
+			}
+		}
+	})
+
+	t.Run("given status dead should list dead letters", func(t *testing.T) {
+		store := &StubStorer{deliveries: []Delivery{{ID: 3, Status: StatusDead}}}
+		req := httptest.NewRequest(http.MethodGet, "/?status=dead", nil)
+		res := httptest.NewRecorder()
+		c := echo.New().NewContext(req, res)
+
+		New(store).DeliveriesHandler(c)
+
+		if res.Code != http.StatusOK || store.status != StatusDead {
+			t.Errorf("expected status %q to be listed but got %d, %q", StatusDead, res.Code, store.status)
+		}
+	})
+
+	t.Run("given unknown status should return 400", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/?status=lost", nil)
+		res := httptest.NewRecorder()
+		c := echo.New().NewContext(req, res)
+
+		New(&StubStorer{}).DeliveriesHandler(c)
+
+		if res.Code != http.StatusBadRequest {
+			t.Errorf("expected status code %d but got %d", http.StatusBadRequest, res.Code)
+		}
+	})
+
+	t.Run("given unknown delivery should return 404", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		res := httptest.NewRecorder()
+		c := echo.New().NewContext(req, res)
+		c.SetParamNames("id")
+		c.SetParamValues("9")
+
+		New(&StubStorer{}).Redeliver(c)
+
+		if res.Code != http.StatusNotFound {
+			t.Errorf("expected status code %d but got %d", http.StatusNotFound, res.Code)
+		}
+	})
+}